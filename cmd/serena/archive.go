@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/unixsysdev/serena-cli-go/internal/orchestrator"
+	"github.com/unixsysdev/serena-cli-go/internal/session"
+)
+
+const (
+	archiveUsage       = "/archive [show <n>|restore <n>|summarize <n>]"
+	defaultRecallCount = 20
+	maxRecalledMessage = 2000
+	maxArchivedPreview = 4000
+	archiveTimeLayout  = "2006-01-02 15:04"
+)
+
+// handleArchiveCommand implements /archive: without arguments it lists the
+// archived epochs of the current session; show prints one, restore puts its
+// messages back into the conversation and summarize re-summarizes it.
+func handleArchiveCommand(ctx context.Context, args []string, orch *orchestrator.Orchestrator, sessions *SessionState) error {
+	epochs, err := sessions.ArchiveEpochs()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		printEpochs(epochs)
+		return nil
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("usage: %s", archiveUsage)
+	}
+	epoch, err := findEpoch(epochs, args[1])
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "show":
+		fmt.Printf("Epoch %d: %s\n\n", epoch.Number, epochTitle(epoch))
+		fmt.Println(formatArchivedMessages(epoch.Messages, maxArchivedPreview))
+		return nil
+	case "restore":
+		return restoreEpoch(epoch, orch, sessions)
+	case "summarize", "summarise":
+		transcript := buildTranscript(archivedOpenAIMessages(epoch.Messages))
+		if transcript == "" {
+			return fmt.Errorf("epoch %d has nothing to summarize", epoch.Number)
+		}
+		summary, err := orch.Summarize(ctx, truncateSummaryInput(transcript))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Epoch %d: %s\n\n%s\n", epoch.Number, epochTitle(epoch), strings.TrimSpace(summary))
+		return nil
+	default:
+		return fmt.Errorf("usage: %s", archiveUsage)
+	}
+}
+
+func printEpochs(epochs []session.Epoch) {
+	if len(epochs) == 0 {
+		fmt.Println("The archive is empty.")
+		return
+	}
+	fmt.Println("Archived epochs:")
+	for _, epoch := range epochs {
+		fmt.Printf("%4d  %s, %d message(s)\n", epoch.Number, epochTitle(epoch), len(epoch.Messages))
+	}
+	fmt.Println("Use /archive show <n>, /archive restore <n> or /archive summarize <n>.")
+}
+
+func epochTitle(epoch session.Epoch) string {
+	if epoch.Time.IsZero() {
+		return epoch.Reason
+	}
+	return fmt.Sprintf("%s at %s", epoch.Reason, epoch.Time.Local().Format(archiveTimeLayout))
+}
+
+func findEpoch(epochs []session.Epoch, value string) (session.Epoch, error) {
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return session.Epoch{}, fmt.Errorf("invalid epoch: %s", value)
+	}
+	for _, epoch := range epochs {
+		if epoch.Number == number {
+			return epoch, nil
+		}
+	}
+	return session.Epoch{}, fmt.Errorf("no archived epoch %d", number)
+}
+
+// restoreEpoch inserts an epoch's messages back at the position they were
+// archived from, or at the end when the conversation has since become
+// shorter. The epoch stays in the archive.
+func restoreEpoch(epoch session.Epoch, orch *orchestrator.Orchestrator, sessions *SessionState) error {
+	restored := archivedOpenAIMessages(epoch.Messages)
+	if len(restored) == 0 {
+		return fmt.Errorf("epoch %d has no messages", epoch.Number)
+	}
+	messages := orch.Messages()
+	index := epoch.Index
+	if index < 1 {
+		index = 1
+	}
+	if index > len(messages) {
+		index = len(messages)
+	}
+
+	updated := make([]openai.ChatCompletionMessage, 0, len(messages)+len(restored))
+	updated = append(updated, messages[:index]...)
+	updated = append(updated, restored...)
+	updated = append(updated, messages[index:]...)
+	orch.ReplaceMessages(updated)
+	if sessions.data != nil {
+		for i := range sessions.data.Checkpoints {
+			if count := sessions.data.Checkpoints[i].MessageCount; count >= index {
+				sessions.data.Checkpoints[i].MessageCount = count + len(restored)
+			}
+		}
+	}
+	if err := sessions.SaveFromOrch(orch); err != nil {
+		return err
+	}
+	fmt.Printf("Restored %d message(s) from epoch %d at position %d.\n", len(restored), epoch.Number, index)
+	return nil
+}
+
+func archivedOpenAIMessages(messages []session.StoredMessage) []openai.ChatCompletionMessage {
+	return session.ToOpenAIMessages("", messages)[1:]
+}
+
+// formatArchivedMessages renders archived messages one block per message,
+// truncating each to limit characters.
+func formatArchivedMessages(messages []session.StoredMessage, limit int) string {
+	var b strings.Builder
+	for i, msg := range messages {
+		role := msg.Role
+		if role == "" {
+			role = "unknown"
+		}
+		fmt.Fprintf(&b, "[%d %s]", i+1, role)
+		if msg.Time != nil {
+			fmt.Fprintf(&b, " %s", msg.Time.Local().Format(archiveTimeLayout))
+		}
+		b.WriteString("\n")
+		content := msg.Content
+		if role == openai.ChatMessageRoleUser {
+			content = orchestrator.UserRequest(content)
+		}
+		if strings.TrimSpace(content) != "" {
+			b.WriteString(truncateText(strings.TrimSpace(content), limit))
+			b.WriteString("\n")
+		}
+		for _, call := range msg.ToolCalls {
+			fmt.Fprintf(&b, "tool_call: %s %s\n", call.Name, truncateLine(singleLine(call.Arguments), maxToolPreview))
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// recallQuery selects archived messages for the session_recall tool.
+type recallQuery struct {
+	epoch int
+	role  string
+	tool  string
+	text  string
+	limit int
+}
+
+// recallArchive returns the archived messages matching q, grouped by epoch.
+func recallArchive(epochs []session.Epoch, q recallQuery) string {
+	if len(epochs) == 0 {
+		return "The session archive is empty."
+	}
+	var b strings.Builder
+	found := 0
+	for _, epoch := range epochs {
+		if q.epoch > 0 && epoch.Number != q.epoch {
+			continue
+		}
+		var matched []session.StoredMessage
+		callNames := make(map[string]string)
+		for _, msg := range epoch.Messages {
+			var tools []string
+			for _, call := range msg.ToolCalls {
+				callNames[call.ID] = call.Name
+				tools = append(tools, call.Name)
+			}
+			if name := callNames[msg.ToolCallID]; msg.Role == openai.ChatMessageRoleTool && name != "" {
+				tools = append(tools, name)
+			}
+			if !recallMatches(msg, tools, q) {
+				continue
+			}
+			if found+len(matched) >= q.limit {
+				break
+			}
+			matched = append(matched, msg)
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		found += len(matched)
+		fmt.Fprintf(&b, "Epoch %d (%s):\n%s\n\n", epoch.Number, epochTitle(epoch),
+			formatArchivedMessages(matched, maxRecalledMessage))
+		if found >= q.limit {
+			break
+		}
+	}
+	if found == 0 {
+		if q.epoch > 0 {
+			return fmt.Sprintf("No matching messages in epoch %d (the archive has %d epoch(s)).", q.epoch, len(epochs))
+		}
+		return "No matching archived messages."
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func recallMatches(msg session.StoredMessage, tools []string, q recallQuery) bool {
+	if q.role != "" && !strings.EqualFold(msg.Role, q.role) {
+		return false
+	}
+	if q.tool != "" {
+		ok := false
+		for _, tool := range tools {
+			if strings.EqualFold(tool, q.tool) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if q.text == "" {
+		return true
+	}
+	text := msg.Content
+	for _, call := range msg.ToolCalls {
+		text += "\n" + call.Name + " " + call.Arguments
+	}
+	return strings.Contains(strings.ToLower(text), strings.ToLower(q.text))
+}
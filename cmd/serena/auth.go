@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/unixsysdev/serena-cli-go/internal/config"
+	"github.com/unixsysdev/serena-cli-go/internal/credentials"
+)
+
+const (
+	authLoginUsage  = "serena auth login [--provider name] [--store auto|keyring|file]"
+	authLogoutUsage = "serena auth logout [--provider name]"
+	authStatusUsage = "serena auth status"
+)
+
+var authCLICommands = map[string]cliCommand{
+	"login":  runAuthLogin,
+	"logout": runAuthLogout,
+	"status": runAuthStatus,
+}
+
+func openCredentialStore(mode string) (*credentials.Store, error) {
+	dir, err := credentials.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	store, err := credentials.Open(dir, mode)
+	if err != nil {
+		return nil, err
+	}
+	store.SetPassphraseFunc(credentialPassphrase)
+	return store, nil
+}
+
+// credentialSecret keeps the credentials passphrase once typed, so loading
+// the config and running an auth command ask only once.
+var credentialSecret []byte
+
+// credentialPassphrase asks for the passphrase of the credentials file on
+// the terminal.
+func credentialPassphrase(creating bool) ([]byte, error) {
+	if credentialSecret != nil {
+		return credentialSecret, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, credentials.ErrNoPassphrase
+	}
+	passphrase, err := askPassphrase("Credentials passphrase: ", "New credentials passphrase: ", creating)
+	if err != nil {
+		return nil, err
+	}
+	credentialSecret = passphrase
+	return passphrase, nil
+}
+
+// runAuthLogin stores an API key for a provider, read from the terminal
+// without echo or from standard input.
+func runAuthLogin(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("auth login", flag.ContinueOnError)
+	provider := flags.String("provider", cfg.LLM.ProviderName(), "Provider to store the key for")
+	mode := flags.String("store", cfg.CredentialStore, "Where to store the key: auto, keyring or file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 || *provider == "" {
+		return fmt.Errorf("usage: %s", authLoginUsage)
+	}
+	store, err := openCredentialStore(*mode)
+	if err != nil {
+		return err
+	}
+
+	key, err := readAPIKey(*provider)
+	if err != nil {
+		return err
+	}
+	where, err := store.Set(*provider, key)
+	if err != nil {
+		return err
+	}
+	switch where {
+	case credentials.StoreKeyring:
+		fmt.Printf("Stored the API key for %s in the system keyring.\n", *provider)
+	default:
+		dir, _ := credentials.DefaultDir()
+		fmt.Printf("Stored the API key for %s in the credentials file in %s, encrypted with your passphrase.\n", *provider, dir)
+	}
+	if *provider != cfg.LLM.ProviderName() {
+		fmt.Printf("Set llm.provider: %s in serena-cli.yaml to use it.\n", *provider)
+	}
+	return nil
+}
+
+func readAPIKey(provider string) (string, error) {
+	var raw []byte
+	var err error
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		raw, err = readPassphrase(fd, fmt.Sprintf("API key for %s: ", provider))
+	} else {
+		raw, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(string(raw))
+	if key == "" {
+		return "", fmt.Errorf("no API key given")
+	}
+	return key, nil
+}
+
+// runAuthLogout removes the stored API key of a provider.
+func runAuthLogout(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("auth logout", flag.ContinueOnError)
+	provider := flags.String("provider", cfg.LLM.ProviderName(), "Provider to remove the key of")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 || *provider == "" {
+		return fmt.Errorf("usage: %s", authLogoutUsage)
+	}
+	store, err := openCredentialStore(cfg.CredentialStore)
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(*provider); err != nil {
+		if errors.Is(err, credentials.ErrNotFound) {
+			return fmt.Errorf("no API key is stored for %s", *provider)
+		}
+		return err
+	}
+	fmt.Printf("Removed the API key for %s.\n", *provider)
+	return nil
+}
+
+// runAuthStatus shows where the API key of the configured provider comes
+// from and lists the stored keys.
+func runAuthStatus(cfg *config.Config, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: %s", authStatusUsage)
+	}
+	store, err := openCredentialStore(cfg.CredentialStore)
+	if err != nil {
+		return err
+	}
+	provider := cfg.LLM.ProviderName()
+	stored, where, storedErr := store.Get(provider)
+
+	fmt.Printf("Provider: %s (%s)\n", provider, cfg.LLM.BaseURL)
+	if cfg.LLM.APIKey == "" {
+		fmt.Println("API key:  not set; run serena auth login")
+	} else {
+		fmt.Printf("API key:  %s from %s\n", maskKey(cfg.LLM.APIKey), cfg.Origin("llm.api_key"))
+	}
+	if storedErr == nil && stored != cfg.LLM.APIKey {
+		fmt.Printf("The key stored in the %s is overridden.\n", credentialStoreName(where))
+	}
+	if storedErr != nil && !errors.Is(storedErr, credentials.ErrNotFound) {
+		fmt.Printf("Stored key unreadable: %v\n", storedErr)
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("No stored API keys.")
+		return nil
+	}
+	fmt.Println("Stored API keys:")
+	inFile := false
+	for _, entry := range entries {
+		fmt.Printf("  %-24s %-16s updated %s\n", entry.Provider, credentialStoreName(entry.Store), entry.UpdatedAt.Local().Format(archiveTimeLayout))
+		inFile = inFile || entry.Store == credentials.StoreFile
+	}
+	if inFile && store.UsesKeyFile() {
+		fmt.Println("Keys in the credentials file are protected only by the key file next to them; run serena auth login to encrypt them with a passphrase.")
+	}
+	return nil
+}
+
+func credentialStoreName(store string) string {
+	if store == credentials.StoreKeyring {
+		return "system keyring"
+	}
+	return "credentials file"
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/unixsysdev/serena-cli-go/internal/config"
+	"github.com/unixsysdev/serena-cli-go/internal/orchestrator"
+	"github.com/unixsysdev/serena-cli-go/internal/session"
+	"github.com/unixsysdev/serena-cli-go/internal/workspace"
+)
+
+const (
+	maxCommitDiffInput     = 12000
+	maxCommitResponseInput = 2000
+)
+
+// autoCommitTurn commits the changes made by the last turn to the configured
+// work branch and records the commit in the session. Edits that were already
+// in the working tree before the turn are not included.
+func autoCommitTurn(ctx context.Context, orch *orchestrator.Orchestrator, cfg *config.Config, sessions *SessionState, prompt string, response string, changes []workspace.FileStat) error {
+	tracker := orch.ChangeTracker()
+	if tracker == nil || sessions.data == nil {
+		return nil
+	}
+	from, to, err := tracker.Range(false)
+	if err != nil {
+		return err
+	}
+	repo := tracker.Repo()
+
+	message := commitMessage(ctx, orch, repo, from, to, prompt, response, changes)
+	branch := cfg.AutoCommitBranch
+	hash, err := repo.CommitToBranch(branch, from, to, message)
+	if err != nil {
+		return err
+	}
+	if hash == "" {
+		return nil
+	}
+
+	subject := strings.SplitN(message, "\n", 2)[0]
+	sessions.data.Commits = append(sessions.data.Commits, session.CommitRecord{
+		Hash:      hash,
+		Branch:    branch,
+		Subject:   subject,
+		Files:     len(changes),
+		CreatedAt: time.Now(),
+	})
+	if err := sessions.SaveFromOrch(orch); err != nil {
+		return err
+	}
+	fmt.Printf("Committed %s to %s: %s\n", shortHash(hash), branch, subject)
+	return nil
+}
+
+// commitMessage asks the compaction model to describe the turn, falling back
+// to the prompt when that fails.
+func commitMessage(ctx context.Context, orch *orchestrator.Orchestrator, repo *workspace.Repo, from string, to string, prompt string, response string, changes []workspace.FileStat) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "User request:\n%s\n\n", strings.TrimSpace(prompt))
+	fmt.Fprintf(&b, "Assistant reply:\n%s\n\nFiles changed:\n", truncateText(strings.TrimSpace(response), maxCommitResponseInput))
+	for _, change := range changes {
+		fmt.Fprintf(&b, "%s %s (+%d -%d)\n", change.Status, change.Path, change.Added, change.Deleted)
+	}
+	if diff, err := repo.Diff(from, to, false); err == nil {
+		fmt.Fprintf(&b, "\nDiff:\n%s\n", truncateText(diff, maxCommitDiffInput))
+	}
+
+	message, err := orch.CommitMessage(ctx, b.String())
+	message = strings.Trim(strings.TrimSpace(message), "`")
+	if err != nil || message == "" {
+		return "serena: " + truncateLine(singleLine(prompt), 64)
+	}
+	return strings.TrimSpace(message)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/unixsysdev/serena-cli-go/internal/config"
+	"github.com/unixsysdev/serena-cli-go/internal/session"
+)
+
+const (
+	bundleExtension    = ".serena.tgz"
+	sessionPackUsage   = "serena session pack [--redact] [-o file] <name>"
+	sessionUnpackUsage = "serena session unpack [--name name] [--force] [--project dir] <file>"
+)
+
+// runSessionPack writes a session, its summary, archive and usage into a
+// single bundle file.
+func runSessionPack(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("session pack", flag.ContinueOnError)
+	redact := flags.Bool("redact", false, "Replace anything that looks like a secret with [REDACTED:<detector>]")
+	output := flags.String("o", "", "Bundle path (default <name>"+bundleExtension+")")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: %s", sessionPackUsage)
+	}
+	store, err := openSessionStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	project, err := projectRoot(cfg)
+	if err != nil {
+		return err
+	}
+	opts := session.PackOptions{Project: project}
+	if *redact {
+		opts.Redact = redactSecrets
+	}
+	bundle, err := store.LoadBundle(flags.Arg(0), opts)
+	if err != nil {
+		return err
+	}
+
+	path := *output
+	if path == "" {
+		path = bundle.Session.Name + bundleExtension
+	}
+	file, err := os.OpenFile(expandHome(path), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := bundle.Write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Packed %s (%d messages, ~%d tokens) into %s\n", bundle.Session.Name, bundle.Usage.Messages, bundle.Usage.ApproxTokens, path)
+	if !*redact {
+		fmt.Println("The bundle is not redacted; use --redact before sharing if it may contain secrets.")
+	}
+	return nil
+}
+
+// runSessionUnpack imports a bundle into the session directory of the current
+// project (or --project), rewriting the packing project's path to ours.
+func runSessionUnpack(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("session unpack", flag.ContinueOnError)
+	name := flags.String("name", "", "Import under this session name")
+	force := flags.Bool("force", false, "Overwrite an existing session with the same name")
+	projectDir := flags.String("project", "", "Project directory to import into (default: current project)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: %s", sessionUnpackUsage)
+	}
+
+	project, err := projectRoot(cfg)
+	if err != nil {
+		return err
+	}
+	if *projectDir != "" {
+		if project, err = filepath.Abs(expandHome(*projectDir)); err != nil {
+			return err
+		}
+	}
+	baseDir, err := projectSessionDir(project)
+	if err != nil {
+		return err
+	}
+	store, err := openProjectStore(cfg, baseDir, cfg.SessionBackend)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	file, err := os.Open(expandHome(flags.Arg(0)))
+	if err != nil {
+		return err
+	}
+	bundle, err := session.ReadBundle(file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	from := bundle.Manifest.Project
+	bundle.RemapProject(project)
+
+	imported, err := store.Import(bundle, session.ImportOptions{Name: *name, Overwrite: *force})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %s as session %s in %s\n", bundle.Manifest.Session, imported, baseDir)
+	if from != "" && from != project {
+		fmt.Printf("Remapped project path %s -> %s\n", from, project)
+	}
+	if imported != sanitizeSessionName(bundle.Manifest.Session) && *name == "" {
+		fmt.Printf("A session named %s already existed; use --name or --force to choose.\n", bundle.Manifest.Session)
+	}
+	fmt.Printf("Open it with /session switch %s\n", imported)
+	return nil
+}
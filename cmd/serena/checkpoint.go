@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/unixsysdev/serena-cli-go/internal/orchestrator"
+	"github.com/unixsysdev/serena-cli-go/internal/session"
+	"github.com/unixsysdev/serena-cli-go/internal/workspace"
+)
+
+const maxCheckpoints = 100
+
+const checkpointRefPrefix = "refs/serena/checkpoints/"
+
+// checkpointRef is the shadow ref that keeps a session's checkpoint snapshots
+// reachable in the project repository.
+func checkpointRef(sessionName string) string {
+	return checkpointRefPrefix + sessionName
+}
+
+// pruneCheckpointRefs deletes the checkpoint refs of sessions not in names, so
+// snapshots of removed sessions can be garbage collected by git.
+func pruneCheckpointRefs(repo *workspace.Repo, names []string) (int, error) {
+	keep := make(map[string]bool, len(names))
+	for _, name := range names {
+		keep[checkpointRef(name)] = true
+	}
+	refs, err := repo.Refs(checkpointRefPrefix)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, ref := range refs {
+		if keep[ref] {
+			continue
+		}
+		if err := repo.DeleteRef(ref); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// recordCheckpoint stores the snapshot taken before the last turn together with
+// the conversation length at that point.
+func recordCheckpoint(orch *orchestrator.Orchestrator, sessions *SessionState, prompt string, messageCount int) error {
+	tracker := orch.ChangeTracker()
+	if tracker == nil || sessions.data == nil {
+		return nil
+	}
+	tree := tracker.TurnBase()
+	if tree == "" {
+		return nil
+	}
+
+	checkpoints := sessions.data.Checkpoints
+	id := 1
+	if len(checkpoints) > 0 {
+		id = checkpoints[len(checkpoints)-1].ID + 1
+	}
+	message := fmt.Sprintf("checkpoint %d: %s", id, truncateLine(singleLine(prompt), 60))
+	commit, err := tracker.Repo().SaveTree(checkpointRef(sessions.Current()), tree, message)
+	if err != nil {
+		return err
+	}
+
+	checkpoints = append(checkpoints, session.Checkpoint{
+		ID:           id,
+		Commit:       commit,
+		Tree:         tree,
+		MessageCount: messageCount,
+		Prompt:       prompt,
+		CreatedAt:    time.Now(),
+	})
+	if len(checkpoints) > maxCheckpoints {
+		checkpoints = checkpoints[len(checkpoints)-maxCheckpoints:]
+	}
+	sessions.data.Checkpoints = checkpoints
+	return nil
+}
+
+// handleUndoCommand implements /undo [n], restoring the state before the last
+// n turns.
+func handleUndoCommand(args []string, orch *orchestrator.Orchestrator, sessions *SessionState) error {
+	n := 1
+	if len(args) > 0 {
+		value, err := strconv.Atoi(args[0])
+		if err != nil || value < 1 {
+			return fmt.Errorf("usage: /undo [n]")
+		}
+		n = value
+	}
+	checkpoints := sessionCheckpoints(sessions)
+	if len(checkpoints) == 0 {
+		return fmt.Errorf("nothing to undo")
+	}
+	if n > len(checkpoints) {
+		return fmt.Errorf("only %d turn(s) can be undone", len(checkpoints))
+	}
+	return restoreCheckpoint(len(checkpoints)-n, orch, sessions)
+}
+
+// handleCheckpointCommand implements /checkpoint [list] and
+// /checkpoint restore <id>.
+func handleCheckpointCommand(args []string, orch *orchestrator.Orchestrator, sessions *SessionState) error {
+	if len(args) == 0 || args[0] == "list" {
+		return listCheckpoints(orch, sessions)
+	}
+	if args[0] != "restore" || len(args) != 2 {
+		return fmt.Errorf("usage: /checkpoint [list] | /checkpoint restore <id>")
+	}
+	id, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid checkpoint id: %s", args[1])
+	}
+	for idx, checkpoint := range sessionCheckpoints(sessions) {
+		if checkpoint.ID == id {
+			return restoreCheckpoint(idx, orch, sessions)
+		}
+	}
+	return fmt.Errorf("unknown checkpoint: %d (see /checkpoint list)", id)
+}
+
+func listCheckpoints(orch *orchestrator.Orchestrator, sessions *SessionState) error {
+	if orch.ChangeTracker() == nil {
+		return fmt.Errorf("checkpoints need the project to be inside a git repository")
+	}
+	checkpoints := sessionCheckpoints(sessions)
+	if len(checkpoints) == 0 {
+		fmt.Println("No checkpoints yet; one is recorded before every turn.")
+		return nil
+	}
+	fmt.Println("Checkpoints (state before each turn):")
+	for _, checkpoint := range checkpoints {
+		note := ""
+		if checkpoint.MessageCount == 0 {
+			note = " [files only]"
+		}
+		fmt.Printf("%4d  %s  %s%s\n", checkpoint.ID, checkpoint.CreatedAt.Format("2006-01-02 15:04"), truncateLine(singleLine(checkpoint.Prompt), maxToolPreview), note)
+	}
+	fmt.Println("Use /checkpoint restore <id> or /undo [n] to go back.")
+	return nil
+}
+
+// restoreCheckpoint reverts files and conversation to checkpoints[idx] and
+// discards it and every later checkpoint. The current files are saved on the
+// checkpoint ref first so the restore itself can be reverted with git.
+func restoreCheckpoint(idx int, orch *orchestrator.Orchestrator, sessions *SessionState) error {
+	tracker := orch.ChangeTracker()
+	if tracker == nil {
+		return fmt.Errorf("checkpoints need the project to be inside a git repository")
+	}
+	checkpoint := sessions.data.Checkpoints[idx]
+	repo := tracker.Repo()
+	ref := checkpointRef(sessions.Current())
+
+	current, err := repo.Snapshot()
+	if err != nil {
+		return err
+	}
+	saved, err := repo.SaveTree(ref, current, fmt.Sprintf("before restoring checkpoint %d", checkpoint.ID))
+	if err != nil {
+		return err
+	}
+	stats, err := repo.Restore(checkpoint.Tree)
+	if err != nil {
+		return err
+	}
+
+	messages := orch.Messages()
+	rewound := checkpoint.MessageCount > 0 && checkpoint.MessageCount <= len(messages)
+	if rewound {
+		orch.ReplaceMessages(messages[:checkpoint.MessageCount])
+	}
+	sessions.data.Checkpoints = sessions.data.Checkpoints[:idx]
+	if err := sessions.SaveFromOrch(orch); err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored checkpoint %d (before %q).\n", checkpoint.ID, truncateLine(singleLine(checkpoint.Prompt), 60))
+	if len(stats) > 0 {
+		printFileStats(stats, maxChangeSummaryFiles)
+	} else {
+		fmt.Println("No files needed restoring.")
+	}
+	if rewound {
+		fmt.Printf("Conversation rewound to %d message(s).\n", checkpoint.MessageCount)
+	} else {
+		fmt.Println("Conversation left unchanged; that turn was compacted or reset.")
+	}
+	fmt.Printf("Files before the restore are saved as commit %s.\n", shortHash(saved))
+	return nil
+}
+
+// shiftCheckpoints adjusts checkpoint message counts after the messages in
+// [1, end) were replaced by kept messages. Checkpoints inside the replaced
+// range can then only restore files.
+func shiftCheckpoints(sessions *SessionState, end int, kept int) {
+	if sessions.data == nil {
+		return
+	}
+	for i := range sessions.data.Checkpoints {
+		checkpoint := &sessions.data.Checkpoints[i]
+		if checkpoint.MessageCount >= end {
+			checkpoint.MessageCount = checkpoint.MessageCount - end + 1 + kept
+		} else {
+			checkpoint.MessageCount = 0
+		}
+	}
+}
+
+// forgetCheckpointMessages marks every checkpoint as files-only, e.g. after
+// the conversation was reset.
+func forgetCheckpointMessages(sessions *SessionState) {
+	if sessions.data == nil {
+		return
+	}
+	for i := range sessions.data.Checkpoints {
+		sessions.data.Checkpoints[i].MessageCount = 0
+	}
+}
+
+// forgetCheckpointsAfter marks checkpoints of turns beyond the first count
+// messages as files-only after the conversation was truncated.
+func forgetCheckpointsAfter(sessions *SessionState, count int) {
+	if sessions.data == nil {
+		return
+	}
+	for i := range sessions.data.Checkpoints {
+		if sessions.data.Checkpoints[i].MessageCount > count {
+			sessions.data.Checkpoints[i].MessageCount = 0
+		}
+	}
+}
+
+func sessionCheckpoints(sessions *SessionState) []session.Checkpoint {
+	if sessions.data == nil {
+		return nil
+	}
+	return sessions.data.Checkpoints
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
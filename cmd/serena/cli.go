@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/unixsysdev/serena-cli-go/internal/config"
+	"github.com/unixsysdev/serena-cli-go/internal/session"
+)
+
+// cliCommand is a non-interactive subcommand such as "serena session export".
+type cliCommand func(cfg *config.Config, args []string) error
+
+const (
+	sessionExportUsage  = "serena session export [--session name] <md|html|json> [path|-]"
+	sessionMigrateUsage = "serena session migrate [--to sqlite|json] [--all]"
+)
+
+var sessionCLICommands = map[string]cliCommand{
+	"encrypt": runSessionEncrypt,
+	"export":  runSessionExport,
+	"gc":      runSessionGC,
+	"migrate": runSessionMigrate,
+	"pack":    runSessionPack,
+	"unpack":  runSessionUnpack,
+}
+
+// lookupCLICommand returns the subcommand named by args and its remaining
+// arguments. Anything else is treated as a one-shot prompt; prompts that
+// start with a subcommand name must be passed with -p or after "--".
+func lookupCLICommand(args []string) (cliCommand, []string, bool) {
+	if len(args) >= 2 && args[0] == "search" {
+		return runSearch, args[1:], true
+	}
+	if len(args) >= 2 && args[0] == "config" {
+		if command, ok := configCLICommands[args[1]]; ok {
+			return command, args[2:], true
+		}
+	}
+	if len(args) >= 2 && args[0] == "auth" {
+		if command, ok := authCLICommands[args[1]]; ok {
+			return command, args[2:], true
+		}
+	}
+	if len(args) >= 2 && args[0] == "session" {
+		if command, ok := sessionCLICommands[args[1]]; ok {
+			return command, args[2:], true
+		}
+	}
+	return nil, nil, false
+}
+
+// afterTerminator reports whether the last positional arguments of args
+// follow a "--", which marks them as a prompt rather than a subcommand.
+func afterTerminator(args []string, positional int) bool {
+	i := len(args) - positional - 1
+	return i >= 0 && args[i] == "--"
+}
+
+// runCLICommand loads configuration without requiring API credentials and
+// runs command.
+func runCLICommand(command cliCommand, args []string, opts config.LoadOptions) error {
+	opts.SkipValidation = true
+	cfg, err := config.LoadWithOptions(opts)
+	if err != nil {
+		return err
+	}
+	return command(cfg, args)
+}
+
+func openSessionStore(cfg *config.Config) (*session.Store, error) {
+	baseDir, err := sessionBaseDir(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return openProjectStore(cfg, baseDir, cfg.SessionBackend)
+}
+
+func runSessionExport(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("session export", flag.ContinueOnError)
+	name := flags.String("session", defaultSessionName, "Session to export")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 || flags.NArg() > 2 {
+		return fmt.Errorf("usage: %s", sessionExportUsage)
+	}
+	store, err := openSessionStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	data, err := store.Load(*name)
+	if err != nil {
+		return err
+	}
+	path := flags.Arg(1)
+	written, err := exportSession(data, flags.Arg(0), path)
+	if err != nil {
+		return err
+	}
+	if written != "" {
+		fmt.Fprintf(os.Stderr, "Exported %s to %s\n", data.Name, written)
+	}
+	return nil
+}
+
+// runSessionMigrate copies sessions between the JSON and SQLite backends. The
+// source is left in place.
+func runSessionMigrate(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("session migrate", flag.ContinueOnError)
+	to := flags.String("to", session.BackendSQLite, "Backend to migrate to (sqlite or json)")
+	all := flags.Bool("all", false, "Migrate sessions of every project, not just the current one")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: %s", sessionMigrateUsage)
+	}
+	var from string
+	switch *to {
+	case session.BackendSQLite:
+		from = session.BackendJSON
+	case session.BackendJSON:
+		from = session.BackendSQLite
+	default:
+		return fmt.Errorf("usage: %s", sessionMigrateUsage)
+	}
+
+	baseDir, err := sessionBaseDir(cfg)
+	if err != nil {
+		return err
+	}
+	dirs, err := projectSessionDirs(baseDir, *all)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for _, dir := range dirs {
+		count, err := migrateSessionDir(cfg, dir, from, *to)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(dir), err)
+		}
+		if count > 0 {
+			fmt.Printf("Migrated %d sessions of %s\n", count, filepath.Base(dir))
+		}
+		total += count
+	}
+	fmt.Printf("Migrated %d sessions from %s to %s; the %s copies were left in place.\n", total, from, *to, from)
+	if cfg.SessionBackend != *to {
+		fmt.Printf("Set session_backend: %s in serena-cli.yaml to use them.\n", *to)
+	}
+	return nil
+}
+
+func migrateSessionDir(cfg *config.Config, dir string, from string, to string) (int, error) {
+	src, err := openProjectStore(cfg, dir, from)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	dst, err := openProjectStore(cfg, dir, to)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+	return session.Migrate(src.Backend(), dst.Backend())
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/unixsysdev/serena-cli-go/internal/config"
+	"github.com/unixsysdev/serena-cli-go/internal/orchestrator"
+)
+
+const customCommandDir = "commands"
+
+// customCommand is a reusable prompt loaded from a Markdown file.
+type customCommand struct {
+	Name         string
+	Description  string
+	Model        string
+	AllowedTools []string
+	Body         string
+	Source       string
+	Path         string
+}
+
+// placeholderPattern matches $ARGUMENTS and positional arguments with their
+// full number, so $10 is the tenth argument rather than $1 followed by 0.
+var placeholderPattern = regexp.MustCompile(`\$(?:ARGUMENTS|[1-9][0-9]*)`)
+
+type commandLocation struct {
+	dir    string
+	source string
+}
+
+// customCommandDirs returns the directories searched for command files, user
+// first so project commands override user commands of the same name.
+func customCommandDirs(cfg *config.Config) []commandLocation {
+	var dirs []commandLocation
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, commandLocation{dir: filepath.Join(home, ".serena-cli", customCommandDir), source: "user"})
+	}
+	if root, err := projectRoot(cfg); err == nil {
+		dirs = append(dirs, commandLocation{dir: filepath.Join(root, ".serena-cli", customCommandDir), source: "project"})
+	}
+	return dirs
+}
+
+// loadCustomCommands discovers command files. Files that fail to parse are
+// skipped and returned as errors.
+func loadCustomCommands(cfg *config.Config) (map[string]*customCommand, []error) {
+	commands := make(map[string]*customCommand)
+	var errs []error
+	for _, location := range customCommandDirs(cfg) {
+		matches, err := filepath.Glob(filepath.Join(location.dir, "*.md"))
+		if err != nil {
+			continue
+		}
+		for _, path := range matches {
+			cmd, err := parseCustomCommand(path, location.source)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("custom command %s: %w", path, err))
+				continue
+			}
+			commands[cmd.Name] = cmd
+		}
+	}
+	return commands, errs
+}
+
+func parseCustomCommand(path string, source string) (*customCommand, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	name = sanitizeSessionName(name)
+
+	cmd := &customCommand{
+		Name:   name,
+		Source: source,
+		Path:   path,
+	}
+
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	body := text
+	if strings.HasPrefix(text, "---\n") {
+		end := strings.Index(text[4:], "\n---")
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated front matter")
+		}
+		var meta map[string]interface{}
+		if err := yaml.Unmarshal([]byte(text[4:4+end]), &meta); err != nil {
+			return nil, fmt.Errorf("parse front matter: %w", err)
+		}
+		cmd.Description = metaString(meta, "description")
+		cmd.Model = metaString(meta, "model")
+		cmd.AllowedTools = metaList(meta, "allowed-tools", "allowed_tools", "tools")
+		body = text[4+end+4:]
+	}
+
+	cmd.Body = strings.TrimSpace(body)
+	if cmd.Body == "" {
+		return nil, fmt.Errorf("command body is empty")
+	}
+	if cmd.Description == "" {
+		cmd.Description = firstLine(cmd.Body)
+	}
+	return cmd, nil
+}
+
+func metaString(meta map[string]interface{}, key string) string {
+	if value, ok := meta[key]; ok && value != nil {
+		return strings.TrimSpace(fmt.Sprint(value))
+	}
+	return ""
+}
+
+func metaList(meta map[string]interface{}, keys ...string) []string {
+	for _, key := range keys {
+		raw, ok := meta[key]
+		if !ok || raw == nil {
+			continue
+		}
+		var values []string
+		switch v := raw.(type) {
+		case []interface{}:
+			for _, item := range v {
+				values = append(values, strings.TrimSpace(fmt.Sprint(item)))
+			}
+		default:
+			for _, item := range strings.Split(fmt.Sprint(v), ",") {
+				values = append(values, strings.TrimSpace(item))
+			}
+		}
+		var cleaned []string
+		for _, value := range values {
+			if value != "" {
+				cleaned = append(cleaned, value)
+			}
+		}
+		return cleaned
+	}
+	return nil
+}
+
+func firstLine(text string) string {
+	line := strings.TrimSpace(strings.SplitN(text, "\n", 2)[0])
+	line = strings.TrimLeft(line, "# ")
+	return truncateLine(line, 60)
+}
+
+// Expand substitutes $ARGUMENTS and $1, $2, ... in the command body in a
+// single pass, so placeholders inside argument values are left as typed.
+// When the body references neither, any arguments are appended so they are
+// not lost.
+func (c *customCommand) Expand(rawArgs string) string {
+	rawArgs = strings.TrimSpace(rawArgs)
+	args := splitArgs(rawArgs)
+
+	referenced := placeholderPattern.MatchString(c.Body)
+	body := placeholderPattern.ReplaceAllStringFunc(c.Body, func(match string) string {
+		if match == "$ARGUMENTS" {
+			return rawArgs
+		}
+		idx, err := strconv.Atoi(match[1:])
+		if err == nil && idx <= len(args) {
+			return args[idx-1]
+		}
+		return ""
+	})
+	if !referenced && rawArgs != "" {
+		body += "\n\nArguments: " + rawArgs
+	}
+	return body
+}
+
+// TurnOptions returns the orchestrator options requested by the front matter.
+func (c *customCommand) TurnOptions() orchestrator.TurnOptions {
+	return orchestrator.TurnOptions{
+		Model:        c.Model,
+		AllowedTools: c.AllowedTools,
+	}
+}
+
+// splitArgs splits on whitespace while honouring single and double quotes.
+func splitArgs(text string) []string {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+				continue
+			}
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+func runCustomCommand(cfg *config.Config, name string, line string) (commandResult, bool) {
+	commands, _ := loadCustomCommands(cfg)
+	cmd, ok := commands[name]
+	if !ok {
+		return commandResult{}, false
+	}
+	rawArgs := ""
+	if idx := strings.IndexAny(line, " \t"); idx >= 0 {
+		rawArgs = line[idx+1:]
+	}
+	opts := cmd.TurnOptions()
+	return commandResult{
+		Submit:  cmd.Expand(rawArgs),
+		Options: &opts,
+	}, true
+}
+
+func printCustomCommands(cfg *config.Config) {
+	commands, errs := loadCustomCommands(cfg)
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+	if len(commands) == 0 {
+		return
+	}
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("Custom commands:")
+	for _, name := range names {
+		cmd := commands[name]
+		label := "/" + name
+		detail := cmd.Description
+		if cmd.Model != "" {
+			detail += fmt.Sprintf(" [model: %s]", shortModelName(cmd.Model))
+		}
+		fmt.Printf("  %-15s %s (%s)\n", label, detail, cmd.Source)
+	}
+}
+
+func customCommandNames(cfg *config.Config) []string {
+	commands, _ := loadCustomCommands(cfg)
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestCustomCommandExpand(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		args string
+		want string
+	}{
+		{"arguments", "Review: $ARGUMENTS", "a b", "Review: a b"},
+		{"positional", "$2 then $1", "a b", "b then a"},
+		{"missing positional", "[$3]", "a b", "[]"},
+		{"quoted argument", "file $1", `"my file.go" x`, "file my file.go"},
+		{"tenth argument", "$10|$1", "1 2 3 4 5 6 7 8 9 ten", "ten|1"},
+		{"argument holding placeholder", "$1 and $2", "$2 b", "$2 and b"},
+		{"arguments holding placeholder", "$ARGUMENTS / $1", "$1", "$1 / $1"},
+		{"not referenced", "Explain.", "x y", "Explain.\n\nArguments: x y"},
+		{"no arguments", "Explain.", "", "Explain."},
+		{"dollar zero kept", "cost $0", "", "cost $0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &customCommand{Body: tt.body}
+			if got := c.Expand(tt.args); got != tt.want {
+				t.Errorf("Expand(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
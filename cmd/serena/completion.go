@@ -0,0 +1,245 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/unixsysdev/serena-cli-go/internal/config"
+	"github.com/unixsysdev/serena-cli-go/internal/orchestrator"
+)
+
+// replCommands lists the slash commands understood by handleCommand.
+var replCommands = []string{
+	"archive", "checkpoint", "clear", "compact", "config", "context", "diff", "exit", "help", "history",
+	"model", "models", "quit", "raw", "redactions", "reset", "rewind", "search", "session", "status",
+	"summary", "tools", "trace", "undo",
+}
+
+var sessionSubcommands = []string{
+	"list", "new", "switch", "fork", "delete", "info", "export", "reload",
+	"rename", "tag", "untag", "describe", "pin", "unpin",
+}
+
+// completer provides context-aware tab completion for the REPL input.
+type completer struct {
+	orch     *orchestrator.Orchestrator
+	cfg      *config.Config
+	sessions *SessionState
+}
+
+func newCompleter(orch *orchestrator.Orchestrator, cfg *config.Config, sessions *SessionState) *completer {
+	return &completer{orch: orch, cfg: cfg, sessions: sessions}
+}
+
+// Complete implements liner.WordCompleter. pos is a rune offset into line.
+func (c *completer) Complete(line string, pos int) (string, []string, string) {
+	runes := []rune(line)
+	if pos > len(runes) {
+		pos = len(runes)
+	}
+	before := string(runes[:pos])
+	tail := string(runes[pos:])
+
+	head, candidates := c.candidates(before)
+	return head, candidates, tail
+}
+
+// candidates returns the text to keep before the completed word and the
+// possible replacements for that word.
+func (c *completer) candidates(before string) (string, []string) {
+	if strings.HasPrefix(before, "@context ") {
+		partial := strings.TrimLeft(strings.TrimPrefix(before, "@context"), " ")
+		head := before[:len(before)-len(partial)]
+		return head, completePath(partial)
+	}
+
+	wordStart := strings.LastIndexAny(before, " \t\n") + 1
+	head := before[:wordStart]
+	word := before[wordStart:]
+
+	if !strings.HasPrefix(before, "/") {
+		if strings.HasPrefix(word, "@") {
+			return head, prefixed("@", completePath(word[1:]))
+		}
+		return before, nil
+	}
+
+	fields := strings.Fields(head)
+
+	if len(fields) == 0 {
+		names := replCommands
+		if c.cfg != nil {
+			names = append(append([]string{}, replCommands...), customCommandNames(c.cfg)...)
+			sort.Strings(names)
+		}
+		return "", prefixed("/", filterPrefix(names, strings.TrimPrefix(word, "/")))
+	}
+
+	cmd := strings.TrimPrefix(fields[0], "/")
+	args := fields[1:]
+
+	switch cmd {
+	case "model":
+		// Model names may be typed after an index-free "/model ", so complete
+		// the whole remainder as one word.
+		rest := strings.TrimLeft(strings.TrimPrefix(before, fields[0]), " ")
+		return before[:len(before)-len(rest)], filterPrefixFold(availableModels, rest)
+	case "session":
+		if len(args) == 0 {
+			return head, filterPrefix(sessionSubcommands, word)
+		}
+		if len(args) == 1 && (args[0] == "switch" || args[0] == "delete" || args[0] == "rename" ||
+			args[0] == "pin" || args[0] == "unpin") {
+			return head, filterPrefix(c.sessionNames(), word)
+		}
+		if len(args) == 1 && args[0] == "export" {
+			return head, filterPrefix([]string{"md", "html", "json"}, word)
+		}
+	case "tools":
+		if len(args) == 0 {
+			return head, filterPrefix(c.toolNames(), word)
+		}
+	case "trace":
+		if len(args) == 0 {
+			return head, filterPrefix([]string{"all"}, word)
+		}
+	case "summary":
+		if len(args) == 0 {
+			return head, filterPrefix([]string{"refresh"}, word)
+		}
+	case "context":
+		if len(args) == 0 {
+			return head, filterPrefix([]string{"add", "drop", "list", "refresh"}, word)
+		}
+		switch args[0] {
+		case "add":
+			return head, completePath(word)
+		case "drop", "rm":
+			if c.sessions != nil {
+				return head, filterPrefix(contextLabels(c.sessions.Contexts()), word)
+			}
+		}
+	case "diff":
+		if len(args) == 0 {
+			return head, filterPrefix([]string{"--stat", "session"}, word)
+		}
+	case "checkpoint":
+		if len(args) == 0 {
+			return head, filterPrefix([]string{"list", "restore"}, word)
+		}
+	case "history":
+		if len(args) == 0 {
+			return head, filterPrefix([]string{"run"}, word)
+		}
+	}
+	return before, nil
+}
+
+func (c *completer) sessionNames() []string {
+	if c.sessions == nil || c.sessions.store == nil {
+		return nil
+	}
+	all, err := c.sessions.store.List()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(all))
+	for _, entry := range all {
+		names = append(names, entry.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *completer) toolNames() []string {
+	if c.orch == nil {
+		return nil
+	}
+	var names []string
+	for _, tool := range c.orch.Tools() {
+		if tool.Function != nil {
+			names = append(names, tool.Function.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completePath lists filesystem entries matching partial. Directories get a
+// trailing separator so completion can continue into them.
+// The typed directory part is kept as is; ~ is only expanded for the lookup.
+func completePath(partial string) []string {
+	cut := strings.LastIndexAny(partial, "/"+string(filepath.Separator)) + 1
+	prefix, base := partial[:cut], partial[cut:]
+	readDir := "."
+	if prefix != "" {
+		readDir = expandHome(prefix)
+	}
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+
+	var matches []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) {
+			continue
+		}
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		candidate := prefix + name
+		if entry.IsDir() {
+			candidate += string(filepath.Separator)
+		}
+		matches = append(matches, candidate)
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func filterPrefix(values []string, prefix string) []string {
+	var matches []string
+	for _, value := range values {
+		if strings.HasPrefix(value, prefix) {
+			matches = append(matches, value)
+		}
+	}
+	return matches
+}
+
+func filterPrefixFold(values []string, prefix string) []string {
+	lower := strings.ToLower(prefix)
+	var matches []string
+	for _, value := range values {
+		if strings.HasPrefix(strings.ToLower(value), lower) {
+			matches = append(matches, value)
+		}
+	}
+	return matches
+}
+
+func prefixed(prefix string, values []string) []string {
+	out := make([]string, len(values))
+	for i, value := range values {
+		out[i] = prefix + value
+	}
+	return out
+}
+
+// commonPrefix returns the longest prefix shared by all values.
+func commonPrefix(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	prefix := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompletePath(t *testing.T) {
+	home := t.TempDir()
+	for _, dir := range []string{"src", "xdir", ".hidden"} {
+		if err := os.Mkdir(filepath.Join(home, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"xfile", filepath.Join("src", "main.go")} {
+		if err := os.WriteFile(filepath.Join(home, file), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("HOME", home)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(home); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	sep := string(filepath.Separator)
+	tests := []struct {
+		partial string
+		want    []string
+	}{
+		{"~", nil},
+		{"~/", []string{"~/src" + sep, "~/xdir" + sep, "~/xfile"}},
+		{"~/x", []string{"~/xdir" + sep, "~/xfile"}},
+		{"~/.h", []string{"~/.hidden" + sep}},
+		{"~/src/", []string{"~/src/main.go"}},
+		{"./", []string{"./src" + sep, "./xdir" + sep, "./xfile"}},
+		{"s", []string{"src" + sep}},
+		{home + "/src/", []string{home + "/src/main.go"}},
+		{"missing/", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.partial, func(t *testing.T) {
+			if got := completePath(tt.partial); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("completePath(%q) = %q, want %q", tt.partial, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/unixsysdev/serena-cli-go/internal/config"
+)
+
+const configShowUsage = "serena config show [--origin]"
+
+var configCLICommands = map[string]cliCommand{
+	"show": runConfigShow,
+}
+
+// settingFlags collects repeated --set key=value flags.
+type settingFlags map[string]string
+
+func (s settingFlags) String() string {
+	settings := make([]string, 0, len(s))
+	for key, value := range s {
+		settings = append(settings, key+"="+value)
+	}
+	sort.Strings(settings)
+	return strings.Join(settings, ",")
+}
+
+func (s settingFlags) Set(setting string) error {
+	key, value, err := config.ParseSetting(setting)
+	if err != nil {
+		return err
+	}
+	s[key] = value
+	return nil
+}
+
+// runConfigShow prints the resolved configuration, or with --origin every
+// value next to the layer it came from.
+func runConfigShow(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("config show", flag.ContinueOnError)
+	origin := flags.Bool("origin", false, "Show where each value came from")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: %s", configShowUsage)
+	}
+	if *origin {
+		return printConfigOrigins(cfg)
+	}
+	return printConfig(cfg)
+}
+
+// handleConfigCommand implements /config [--origin].
+func handleConfigCommand(args []string, cfg *config.Config) error {
+	switch {
+	case len(args) == 0:
+		return printConfig(cfg)
+	case len(args) == 1 && args[0] == "--origin":
+		return printConfigOrigins(cfg)
+	default:
+		return fmt.Errorf("usage: /config [--origin]")
+	}
+}
+
+func printConfigOrigins(cfg *config.Config) error {
+	files := cfg.Files()
+	if len(files) == 0 {
+		fmt.Println("No config files found.")
+	} else {
+		fmt.Println("Config files, lowest priority first:")
+		for _, path := range files {
+			fmt.Println("  " + path)
+		}
+	}
+	fmt.Println()
+
+	values := make(map[string]interface{})
+	flattenConfig("", configDisplay(cfg), values)
+	keys := make([]string, 0, len(values))
+	width := 0
+	for key := range values {
+		keys = append(keys, key)
+		width = max(width, len(key))
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, err := json.Marshal(values[key])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-*s = %s  (%s)\n", width, key, value, cfg.Origin(key))
+	}
+	return nil
+}
+
+// flattenConfig adds the leaves of a nested display map to out under dotted
+// keys.
+func flattenConfig(prefix string, value interface{}, out map[string]interface{}) {
+	var entries map[string]interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		entries = v
+	case map[string]string:
+		entries = make(map[string]interface{}, len(v))
+		for key, item := range v {
+			entries[key] = item
+		}
+	default:
+		out[prefix] = value
+		return
+	}
+	for key, item := range entries {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		flattenConfig(key, item, out)
+	}
+}
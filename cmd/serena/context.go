@@ -0,0 +1,360 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/unixsysdev/serena-cli-go/internal/attach"
+	"github.com/unixsysdev/serena-cli-go/internal/config"
+	"github.com/unixsysdev/serena-cli-go/internal/orchestrator"
+	"github.com/unixsysdev/serena-cli-go/internal/session"
+)
+
+// mentionAttachments expands @file, @dir/ and @file:10-40 mentions in a prompt
+// into attachments for that turn, reporting what was attached or skipped.
+func mentionAttachments(text string, cfg *config.Config) []orchestrator.ContextAttachment {
+	root, err := projectRoot(cfg)
+	if err != nil {
+		return nil
+	}
+	mentions := attach.ParseMentions(text, root)
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	loaded, warnings := attach.Load(mentions, attach.Options{Root: root, MaxFileSize: maxContextFileSize})
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Attachment skipped: %s\n", warning)
+	}
+	if len(loaded) == 0 {
+		return nil
+	}
+
+	attachments := make([]orchestrator.ContextAttachment, 0, len(loaded))
+	labels := make([]string, 0, len(loaded))
+	for _, item := range loaded {
+		attachments = append(attachments, orchestrator.ContextAttachment{Label: item.Label, Content: item.Content})
+		detail := fmt.Sprintf("%d bytes", len(item.Content))
+		if item.Kind == attach.KindTree {
+			detail = "tree"
+		}
+		labels = append(labels, fmt.Sprintf("%s (%s)", item.Label, detail))
+	}
+	fmt.Fprintf(os.Stderr, "Attached: %s\n", strings.Join(labels, ", "))
+	return attachments
+}
+
+const (
+	maxContextFiles     = 200
+	maxContextTotalSize = attach.DefaultMaxTotalSize
+)
+
+var legacyContextPattern = regexp.MustCompile(`(?s)^<context source=("(?:[^"\\]|\\.)*")>\n(.*)\n</context>$`)
+
+// handleContextImport attaches the files named by one or more paths,
+// directories or globs to the session context. Re-importing a file replaces
+// its content.
+func handleContextImport(line string, orch *orchestrator.Orchestrator, cfg *config.Config, sessions *SessionState) error {
+	patterns := splitArgs(strings.TrimSpace(strings.TrimPrefix(line, "@context")))
+	if len(patterns) == 0 {
+		return fmt.Errorf("usage: @context <file|dir|glob>...")
+	}
+	root, err := projectRoot(cfg)
+	if err != nil {
+		return err
+	}
+
+	ignorer := attach.NewIgnorer(root)
+	sources := sessions.Contexts()
+	total := contextSize(sources)
+	added, updated, skipped := 0, 0, 0
+	for _, pattern := range patterns {
+		files, err := attach.ExpandPattern(pattern, root, ignorer, maxContextFiles)
+		if err != nil {
+			fmt.Printf("%s: %v\n", pattern, err)
+			continue
+		}
+		for _, file := range files {
+			source, err := readContextSource(file, root)
+			if err != nil {
+				if len(files) == 1 {
+					fmt.Printf("%s: %v\n", attach.Label(file, root), err)
+				}
+				skipped++
+				continue
+			}
+			idx := findContextSource(sources, source.Label)
+			if idx >= 0 && sources[idx].Hash == source.Hash {
+				continue
+			}
+			previous := 0
+			if idx >= 0 {
+				previous = len(sources[idx].Content)
+			}
+			if total-previous+len(source.Content) > maxContextTotalSize {
+				fmt.Printf("%s: skipped, attached context would exceed %d bytes (try /context drop)\n", source.Label, maxContextTotalSize)
+				skipped++
+				continue
+			}
+			total += len(source.Content) - previous
+			if idx >= 0 {
+				source.AddedAt = sources[idx].AddedAt
+				sources[idx] = source
+				updated++
+			} else {
+				sources = append(sources, source)
+				added++
+			}
+		}
+	}
+
+	if added+updated > 0 {
+		if err := sessions.SetContexts(sources, orch); err != nil {
+			return err
+		}
+	}
+	summary := fmt.Sprintf("Context: %d added, %d updated", added, updated)
+	if skipped > 0 {
+		summary += fmt.Sprintf(", %d skipped (binary, too large or unreadable)", skipped)
+	}
+	fmt.Println(summary + ".")
+	return nil
+}
+
+// handleContextCommand implements /context and its list, add, drop and
+// refresh subcommands.
+func handleContextCommand(line string, args []string, orch *orchestrator.Orchestrator, cfg *config.Config, sessions *SessionState) error {
+	if len(args) == 0 {
+		if err := printContext(orch); err != nil {
+			return err
+		}
+		if sources := sessions.Contexts(); len(sources) > 0 {
+			fmt.Printf("Attached sources: %d (see /context list)\n", len(sources))
+		}
+		return nil
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		return listContextSources(orch, sessions)
+	case "add":
+		rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(line, "/context")), "add"))
+		return handleContextImport("@context "+rest, orch, cfg, sessions)
+	case "drop", "rm":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: /context drop <label|glob|dir/>...")
+		}
+		return dropContextSources(args[1:], orch, sessions)
+	case "refresh":
+		return refreshContextSources(orch, cfg, sessions)
+	default:
+		return fmt.Errorf("usage: /context [list|add <path>|drop <label>|refresh]")
+	}
+}
+
+func listContextSources(orch *orchestrator.Orchestrator, sessions *SessionState) error {
+	sources := sessions.Contexts()
+	if len(sources) == 0 {
+		fmt.Println("No context sources attached. Use @context <file|dir|glob> to add some.")
+		return nil
+	}
+	stats := orch.ConversationStats()
+	fmt.Println("Context sources:")
+	tokens := 0
+	for _, source := range sources {
+		sourceTokens := len(source.Content) / 4
+		tokens += sourceTokens
+		fmt.Printf("  %-40s %7d tokens  %5.1f%%\n", truncateLine(source.Label, 40), sourceTokens, tokenShare(sourceTokens, stats.ApproxTokens))
+	}
+	fmt.Printf("Total: %d sources, %d tokens (%.1f%% of %d in context)\n", len(sources), tokens, tokenShare(tokens, stats.ApproxTokens), stats.ApproxTokens)
+	return nil
+}
+
+func dropContextSources(patterns []string, orch *orchestrator.Orchestrator, sessions *SessionState) error {
+	sources := sessions.Contexts()
+	var kept []session.ContextSource
+	var dropped []string
+	for _, source := range sources {
+		if contextSourceMatches(source.Label, patterns) {
+			dropped = append(dropped, source.Label)
+			continue
+		}
+		kept = append(kept, source)
+	}
+	if len(dropped) == 0 {
+		return fmt.Errorf("no context source matches %s (see /context list)", strings.Join(patterns, " "))
+	}
+	if err := sessions.SetContexts(kept, orch); err != nil {
+		return err
+	}
+	fmt.Printf("Dropped %d context source(s): %s\n", len(dropped), strings.Join(dropped, ", "))
+	return nil
+}
+
+// contextSourceMatches reports whether label equals one of patterns, matches it
+// as a glob, or lies under it when the pattern ends with a slash.
+func contextSourceMatches(label string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if label == pattern {
+			return true
+		}
+		if strings.HasSuffix(pattern, "/") && strings.HasPrefix(label, pattern) {
+			return true
+		}
+		if ok, err := path.Match(pattern, label); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+func refreshContextSources(orch *orchestrator.Orchestrator, cfg *config.Config, sessions *SessionState) error {
+	sources := sessions.Contexts()
+	if len(sources) == 0 {
+		fmt.Println("No context sources attached.")
+		return nil
+	}
+	root, err := projectRoot(cfg)
+	if err != nil {
+		return err
+	}
+
+	var changed []string
+	failed := 0
+	for i, source := range sources {
+		fresh, err := readContextSource(source.Path, root)
+		if err != nil {
+			fmt.Printf("%s: %v (kept previous content)\n", source.Label, err)
+			failed++
+			continue
+		}
+		if fresh.Hash == source.Hash {
+			continue
+		}
+		fresh.Label = source.Label
+		fresh.AddedAt = source.AddedAt
+		sources[i] = fresh
+		changed = append(changed, source.Label)
+	}
+
+	if len(changed) > 0 {
+		if err := sessions.SetContexts(sources, orch); err != nil {
+			return err
+		}
+		fmt.Printf("Refreshed %d changed source(s): %s\n", len(changed), strings.Join(changed, ", "))
+	} else {
+		fmt.Println("All context sources are up to date.")
+	}
+	if failed > 0 {
+		fmt.Printf("%d source(s) could not be re-read.\n", failed)
+	}
+	return nil
+}
+
+func readContextSource(path string, root string) (session.ContextSource, error) {
+	loaded, err := attach.LoadFile(path, attach.Options{Root: root, MaxFileSize: maxContextFileSize})
+	if err != nil {
+		return session.ContextSource{}, err
+	}
+	return session.ContextSource{
+		Label:   loaded.Label,
+		Path:    path,
+		Content: loaded.Content,
+		Hash:    contentHash(loaded.Content),
+		AddedAt: time.Now(),
+	}, nil
+}
+
+// migrateContextMessages moves context blocks that older versions stored as
+// system messages into data.Context.
+func migrateContextMessages(data *session.SessionData) bool {
+	var kept []session.StoredMessage
+	migrated := false
+	for _, msg := range data.Messages {
+		if msg.Role == openai.ChatMessageRoleSystem {
+			if m := legacyContextPattern.FindStringSubmatch(msg.Content); m != nil {
+				label, err := strconv.Unquote(m[1])
+				if err == nil && findContextSource(data.Context, label) < 0 {
+					data.Context = append(data.Context, session.ContextSource{
+						Label:   label,
+						Path:    label,
+						Content: m[2],
+						Hash:    contentHash(m[2]),
+						AddedAt: data.CreatedAt,
+					})
+					migrated = true
+					continue
+				}
+			}
+		}
+		kept = append(kept, msg)
+	}
+	if migrated {
+		data.Messages = kept
+	}
+	return migrated
+}
+
+func contextAttachments(sources []session.ContextSource) []orchestrator.ContextAttachment {
+	attachments := make([]orchestrator.ContextAttachment, 0, len(sources))
+	for _, source := range sources {
+		attachments = append(attachments, orchestrator.ContextAttachment{Label: source.Label, Content: source.Content})
+	}
+	return attachments
+}
+
+// redactContexts returns sources with secrets redacted from their content.
+// Hashes still describe the original content, so refresh detects changes.
+func redactContexts(sources []session.ContextSource, orch *orchestrator.Orchestrator) []session.ContextSource {
+	redacted := make([]session.ContextSource, len(sources))
+	for i, source := range sources {
+		source.Content = orch.Redact(source.Content, "context "+source.Label)
+		redacted[i] = source
+	}
+	return redacted
+}
+
+func contextLabels(sources []session.ContextSource) []string {
+	labels := make([]string, 0, len(sources))
+	for _, source := range sources {
+		labels = append(labels, source.Label)
+	}
+	return labels
+}
+
+func findContextSource(sources []session.ContextSource, label string) int {
+	for i, source := range sources {
+		if source.Label == label {
+			return i
+		}
+	}
+	return -1
+}
+
+func contextSize(sources []session.ContextSource) int {
+	total := 0
+	for _, source := range sources {
+		total += len(source.Content)
+	}
+	return total
+}
+
+func tokenShare(tokens int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(tokens) / float64(total) * 100
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/unixsysdev/serena-cli-go/internal/orchestrator"
+	"github.com/unixsysdev/serena-cli-go/internal/workspace"
+)
+
+const maxChangeSummaryFiles = 20
+
+// handleDiffCommand implements /diff [session] [--stat] [path...].
+func handleDiffCommand(args []string, orch *orchestrator.Orchestrator) error {
+	tracker := orch.ChangeTracker()
+	if tracker == nil {
+		return fmt.Errorf("change tracking needs the project to be inside a git repository")
+	}
+
+	session := false
+	statOnly := false
+	var paths []string
+	for _, arg := range args {
+		switch arg {
+		case "session", "all":
+			session = true
+		case "turn", "last":
+			session = false
+		case "--stat", "stat":
+			statOnly = true
+		default:
+			paths = append(paths, arg)
+		}
+	}
+
+	from, to, err := tracker.Range(session)
+	if err != nil {
+		return err
+	}
+	scope := "last turn"
+	if session {
+		scope = "this session"
+	}
+
+	if statOnly {
+		stats, err := tracker.Repo().DiffStat(from, to)
+		if err != nil {
+			return err
+		}
+		if len(stats) == 0 {
+			fmt.Printf("No file changes during %s.\n", scope)
+			return nil
+		}
+		fmt.Printf("Changes during %s:\n", scope)
+		printFileStats(stats, 0)
+		return nil
+	}
+
+	diff, err := tracker.Repo().Diff(from, to, markdownOutput.colorEnabled(), paths...)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(diff) == "" {
+		fmt.Printf("No file changes during %s.\n", scope)
+		return nil
+	}
+	fmt.Print(diff)
+	if !strings.HasSuffix(diff, "\n") {
+		fmt.Println()
+	}
+	return nil
+}
+
+// turnChanges returns the files changed by the last turn, or nil when change
+// tracking is unavailable.
+func turnChanges(orch *orchestrator.Orchestrator) []workspace.FileStat {
+	tracker := orch.ChangeTracker()
+	if tracker == nil {
+		return nil
+	}
+	stats, err := tracker.TurnStats()
+	if err != nil {
+		return nil
+	}
+	return stats
+}
+
+// printFileStats prints one line per file with its status and line counts,
+// eliding entries beyond limit when limit is positive.
+func printFileStats(stats []workspace.FileStat, limit int) {
+	width := 0
+	for _, stat := range stats {
+		if len(stat.Path) > width {
+			width = len(stat.Path)
+		}
+	}
+	if width > 50 {
+		width = 50
+	}
+
+	color := markdownOutput.colorEnabled()
+	added, deleted := 0, 0
+	for i, stat := range stats {
+		added += stat.Added
+		deleted += stat.Deleted
+		if limit > 0 && i >= limit {
+			continue
+		}
+		status := stat.Status
+		if status == "" {
+			status = "M"
+		}
+		counts := "binary"
+		if !stat.Binary {
+			plus := fmt.Sprintf("+%d", stat.Added)
+			minus := fmt.Sprintf("-%d", stat.Deleted)
+			if color {
+				plus = colorGreen + plus + colorReset
+				minus = colorRed + minus + colorReset
+			}
+			counts = plus + " " + minus
+		}
+		fmt.Printf("  %s %-*s %s\n", status, width, stat.Path, counts)
+	}
+	if limit > 0 && len(stats) > limit {
+		fmt.Printf("  ... and %d more\n", len(stats)-limit)
+	}
+	fmt.Printf("  %d file(s) changed, +%d -%d\n", len(stats), added, deleted)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/term"
+
+	"github.com/unixsysdev/serena-cli-go/internal/config"
+	"github.com/unixsysdev/serena-cli-go/internal/crypt"
+	"github.com/unixsysdev/serena-cli-go/internal/session"
+)
+
+const (
+	sessionEncryptUsage = "serena session encrypt [--decrypt] [--all]"
+	passphraseEnv       = "SERENA_SESSION_PASSPHRASE"
+)
+
+// The session key is derived at most once per process: scrypt is slow on
+// purpose and the passphrase may have to be typed.
+var (
+	cipherOnce   sync.Once
+	cipherLoaded *crypt.Cipher
+	cipherErr    error
+)
+
+// sessionCipher returns the cipher for session storage, or nil when
+// session_encryption is disabled.
+func sessionCipher(cfg *config.Config) (*crypt.Cipher, error) {
+	if !cfg.SessionEncryption.Enabled {
+		return nil, nil
+	}
+	return loadSessionCipher(cfg)
+}
+
+func loadSessionCipher(cfg *config.Config) (*crypt.Cipher, error) {
+	cipherOnce.Do(func() {
+		baseDir, err := sessionBaseDir(cfg)
+		if err != nil {
+			cipherErr = err
+			return
+		}
+		root := filepath.Dir(baseDir)
+		secret, err := sessionSecret(cfg, root)
+		if err != nil {
+			cipherErr = err
+			return
+		}
+		cipherLoaded, cipherErr = crypt.LoadCipher(root, secret)
+		if errors.Is(cipherErr, crypt.ErrWrongKey) {
+			cipherErr = session.ErrWrongKey
+		}
+	})
+	return cipherLoaded, cipherErr
+}
+
+// sessionSecret reads the key file, the passphrase environment variable or
+// a passphrase typed on the terminal, asked twice when no key exists yet.
+func sessionSecret(cfg *config.Config, root string) ([]byte, error) {
+	if path := cfg.SessionEncryption.KeyFile; path != "" {
+		secret, err := os.ReadFile(expandHome(path))
+		if err != nil {
+			return nil, fmt.Errorf("read session key file: %w", err)
+		}
+		return secret, nil
+	}
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("sessions are encrypted: set %s or session_encryption.key_file", passphraseEnv)
+	}
+	_, err := os.Stat(filepath.Join(root, crypt.ParamsFileName))
+	return askPassphrase("Session passphrase: ", "New session passphrase: ", errors.Is(err, os.ErrNotExist))
+}
+
+// askPassphrase reads a passphrase on the terminal, asked twice with
+// newPrompt when creating.
+func askPassphrase(prompt string, newPrompt string, creating bool) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if creating {
+		prompt = newPrompt
+	}
+	passphrase, err := readPassphrase(fd, prompt)
+	if err != nil {
+		return nil, err
+	}
+	if creating {
+		again, err := readPassphrase(fd, "Repeat passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(passphrase, again) {
+			return nil, fmt.Errorf("passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
+
+func readPassphrase(fd int, prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return passphrase, err
+}
+
+// openProjectStore opens the session store of one project directory with
+// the configured backend and encryption.
+func openProjectStore(cfg *config.Config, dir string, backend string) (*session.Store, error) {
+	c, err := sessionCipher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return session.OpenEncryptedStore(dir, backend, c)
+}
+
+// runSessionEncrypt rewrites existing sessions encrypted with the configured
+// key, or as plaintext with --decrypt.
+func runSessionEncrypt(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("session encrypt", flag.ContinueOnError)
+	decrypt := flags.Bool("decrypt", false, "Write sessions back as plaintext")
+	all := flags.Bool("all", false, "Rewrite sessions of every project, not just the current one")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: %s", sessionEncryptUsage)
+	}
+	c, err := loadSessionCipher(cfg)
+	if err != nil {
+		return err
+	}
+	target := c
+	if *decrypt {
+		target = nil
+	}
+
+	baseDir, err := sessionBaseDir(cfg)
+	if err != nil {
+		return err
+	}
+	dirs, err := projectSessionDirs(baseDir, *all)
+	if err != nil {
+		return err
+	}
+	total := 0
+	for _, dir := range dirs {
+		store, err := session.OpenEncryptedStore(dir, cfg.SessionBackend, c)
+		if err != nil {
+			return err
+		}
+		count, err := store.Reencrypt(target)
+		store.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(dir), err)
+		}
+		total += count
+	}
+
+	if *decrypt {
+		fmt.Printf("Decrypted %d sessions.\n", total)
+		if cfg.SessionEncryption.Enabled {
+			fmt.Println("Set session_encryption.enabled: false in serena-cli.yaml, or they are encrypted again on the next save.")
+		}
+		return nil
+	}
+	fmt.Printf("Encrypted %d sessions.\n", total)
+	if !cfg.SessionEncryption.Enabled {
+		fmt.Println("Set session_encryption.enabled: true in serena-cli.yaml to keep new data encrypted.")
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/unixsysdev/serena-cli-go/internal/orchestrator"
+	"github.com/unixsysdev/serena-cli-go/internal/session"
+	"github.com/unixsysdev/serena-cli-go/internal/transcript"
+)
+
+// handleSessionExport implements "/session export <md|html|json> [path]".
+func handleSessionExport(args []string, orch *orchestrator.Orchestrator, sessions *SessionState) error {
+	if len(args) == 0 || len(args) > 2 {
+		return fmt.Errorf("usage: /session export <md|html|json> [path|-]")
+	}
+	if err := sessions.SaveFromOrch(orch); err != nil {
+		return err
+	}
+	path := ""
+	if len(args) == 2 {
+		path = args[1]
+	}
+	written, err := exportSession(sessions.data, args[0], path)
+	if err != nil {
+		return err
+	}
+	if written != "" {
+		fmt.Printf("Exported %s to %s\n", sessions.data.Name, written)
+	}
+	return nil
+}
+
+// exportSession writes data as a transcript in format. An empty path
+// defaults to <session>.<format> in the working directory and "-" writes to
+// stdout. It returns the file written, or "" for stdout. The transcript is
+// decrypted and unredacted, so the file is only readable by the user.
+func exportSession(data *session.SessionData, format string, path string) (string, error) {
+	format, err := transcript.NormalizeFormat(format)
+	if err != nil {
+		return "", err
+	}
+	if path == "-" {
+		return "", transcript.Write(os.Stdout, format, data, transcript.Options{})
+	}
+	if path == "" {
+		path = data.Name + "." + format
+	}
+	path = expandHome(path)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", err
+	}
+	if err := transcript.Write(file, format, data, transcript.Options{}); err != nil {
+		file.Close()
+		return "", err
+	}
+	return path, file.Close()
+}
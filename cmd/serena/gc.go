@@ -0,0 +1,213 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unixsysdev/serena-cli-go/internal/config"
+	"github.com/unixsysdev/serena-cli-go/internal/session"
+	"github.com/unixsysdev/serena-cli-go/internal/workspace"
+)
+
+const sessionGCUsage = "serena session gc [--max-age 30d] [--max-count n] [--max-size 500MB] [--keep name] [--all] [--dry-run]"
+
+// runSessionGC removes sessions outside the retention policy, and archive,
+// summary and temporary files no session refers to. Limits default to the
+// session_retention config section.
+func runSessionGC(cfg *config.Config, args []string) error {
+	retention := cfg.SessionRetention
+	policy := session.RetentionPolicy{
+		MaxAge:   time.Duration(retention.MaxAgeDays) * 24 * time.Hour,
+		MaxCount: retention.MaxCount,
+		MaxSize:  int64(retention.MaxSizeMB) << 20,
+	}
+	flags := flag.NewFlagSet("session gc", flag.ContinueOnError)
+	flags.Func("max-age", "Remove sessions not updated for this long (e.g. 30d, 12h)", func(value string) error {
+		age, err := parseAge(value)
+		policy.MaxAge = age
+		return err
+	})
+	flags.IntVar(&policy.MaxCount, "max-count", policy.MaxCount, "Keep at most this many sessions per project")
+	flags.Func("max-size", "Keep at most this much session data per project (e.g. 500MB)", func(value string) error {
+		size, err := parseSize(value)
+		policy.MaxSize = size
+		return err
+	})
+	flags.Func("keep", "Never remove this session (repeatable)", func(value string) error {
+		policy.Keep = append(policy.Keep, value)
+		return nil
+	})
+	all := flags.Bool("all", false, "Collect the sessions of every project, not just the current one")
+	dryRun := flags.Bool("dry-run", false, "List what would be removed without deleting anything")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 || policy.MaxCount < 0 {
+		return fmt.Errorf("usage: %s", sessionGCUsage)
+	}
+
+	baseDir, err := sessionBaseDir(cfg)
+	if err != nil {
+		return err
+	}
+	dirs, err := projectSessionDirs(baseDir, *all)
+	if err != nil {
+		return err
+	}
+
+	// Checkpoint refs live in the project repository, which is only known
+	// for the current project.
+	var repo *workspace.Repo
+	if root, err := projectRoot(cfg); err == nil {
+		repo, _ = workspace.Open(root)
+	}
+
+	var removed, orphans, freed int64
+	for _, dir := range dirs {
+		dirRepo := repo
+		if dir != baseDir {
+			dirRepo = nil
+		}
+		plan, err := planSessionGC(cfg, dir, policy, *dryRun, dirRepo)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(dir), err)
+		}
+		removed += int64(len(plan.Sessions))
+		orphans += int64(len(plan.Orphans))
+		freed += plan.Size()
+	}
+	verb := "Removed"
+	if *dryRun {
+		verb = "Would remove"
+	}
+	fmt.Printf("%s %d session(s) and %d orphaned file(s), freeing %s.\n", verb, removed, orphans, formatBytes(freed))
+	return nil
+}
+
+// planSessionGC prints and, unless dryRun, applies the plan for dir. With a
+// repo, checkpoint refs of sessions that no longer exist are deleted too.
+func planSessionGC(cfg *config.Config, dir string, policy session.RetentionPolicy, dryRun bool, repo *workspace.Repo) (*session.GCPlan, error) {
+	store, err := openProjectStore(cfg, dir, cfg.SessionBackend)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	plan, err := store.PlanGC(policy, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.Sessions) == 0 && len(plan.Orphans) == 0 {
+		if dryRun {
+			return plan, nil
+		}
+		return plan, pruneSessionRefs(store, repo)
+	}
+
+	fmt.Printf("%s (keeping %d session(s), %s):\n", filepath.Base(dir), plan.Kept, formatBytes(plan.KeptSize))
+	for _, candidate := range plan.Sessions {
+		fmt.Printf("  %-24s %-10s %s  %s\n", candidate.Name, formatBytes(candidate.Size),
+			candidate.UpdatedAt.Format("2006-01-02"), candidate.Reason)
+	}
+	for _, orphan := range plan.Orphans {
+		fmt.Printf("  %-24s %-10s orphaned file\n", filepath.Base(orphan.Path), formatBytes(orphan.Size))
+	}
+	if dryRun {
+		return plan, nil
+	}
+	if err := store.ApplyGC(plan); err != nil {
+		return plan, err
+	}
+	return plan, pruneSessionRefs(store, repo)
+}
+
+// pruneSessionRefs deletes checkpoint refs in repo whose session is no longer
+// in store.
+func pruneSessionRefs(store *session.Store, repo *workspace.Repo) error {
+	if repo == nil {
+		return nil
+	}
+	names, err := store.Backend().Names()
+	if err != nil {
+		return err
+	}
+	refs, err := pruneCheckpointRefs(repo, names)
+	if refs > 0 {
+		fmt.Printf("Deleted %d checkpoint ref(s) of removed sessions.\n", refs)
+	}
+	return err
+}
+
+// projectSessionDirs returns baseDir, or with all every project directory
+// next to it.
+func projectSessionDirs(baseDir string, all bool) ([]string, error) {
+	if !all {
+		return []string{baseDir}, nil
+	}
+	root := filepath.Dir(baseDir)
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, filepath.Join(root, entry.Name()))
+		}
+	}
+	return dirs, nil
+}
+
+// parseAge parses a Go duration or a whole number of days such as "30d".
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	age, err := time.ParseDuration(value)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age %q", value)
+	}
+	return age, nil
+}
+
+// parseSize parses a byte count with an optional KB, MB or GB suffix.
+func parseSize(value string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(value))
+	shift := 0
+	for _, unit := range []struct {
+		suffix string
+		shift  int
+	}{{"GB", 30}, {"MB", 20}, {"KB", 10}, {"G", 30}, {"M", 20}, {"K", 10}, {"B", 0}} {
+		if trimmed, ok := strings.CutSuffix(upper, unit.suffix); ok {
+			upper, shift = trimmed, unit.shift
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(upper), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(n * float64(int64(1)<<shift)), nil
+}
+
+// formatBytes renders a byte count with a binary unit, e.g. "1.5 MB".
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
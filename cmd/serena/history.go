@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/unixsysdev/serena-cli-go/internal/fsutil"
+	"github.com/unixsysdev/serena-cli-go/internal/redact"
+	"github.com/unixsysdev/serena-cli-go/internal/session"
+)
+
+const (
+	historyFileName    = "history"
+	maxHistoryEntries  = 1000
+	historyListDefault = 20
+)
+
+// historyRedactor detects input that should never be written to the history
+// file.
+var historyRedactor = redact.Default()
+
+// historyStore persists REPL input per project next to the session files,
+// one JSON string per line so pasted prompts keep their exact text.
+type historyStore struct {
+	path    string
+	entries []string
+}
+
+func loadHistory(baseDir string) (*historyStore, error) {
+	h := &historyStore{path: filepath.Join(baseDir, historyFileName)}
+	if err := h.reload(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *historyStore) reload() error {
+	file, err := os.Open(h.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			h.entries = nil
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(file)
+	// Whole pasted prompts are stored, so lines can be long.
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.TrimSpace(line) != "" {
+			entries = append(entries, decodeHistoryLine(line))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	h.entries = entries
+	return nil
+}
+
+// Entries returns a copy of the stored history, oldest first.
+func (h *historyStore) Entries() []string {
+	entries := make([]string, len(h.entries))
+	copy(entries, h.entries)
+	return entries
+}
+
+// Add records entry, moving an existing duplicate to the end. It returns false
+// when the entry was skipped because it looks like it contains a secret.
+func (h *historyStore) Add(entry string) (bool, error) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return false, nil
+	}
+	if containsSecret(entry) {
+		return false, nil
+	}
+
+	// Merge entries written by other processes for the same project.
+	unlock, err := session.LockFile(h.path + ".lock")
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	if err := h.reload(); err != nil {
+		return false, err
+	}
+
+	kept := h.entries[:0]
+	for _, existing := range h.entries {
+		if existing != entry {
+			kept = append(kept, existing)
+		}
+	}
+	h.entries = append(kept, entry)
+	if len(h.entries) > maxHistoryEntries {
+		h.entries = h.entries[len(h.entries)-maxHistoryEntries:]
+	}
+	return true, h.save()
+}
+
+func (h *historyStore) save() error {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return err
+	}
+	var content []byte
+	for _, entry := range h.entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		content = append(append(content, line...), '\n')
+	}
+	return fsutil.WriteFileAtomic(h.path, content, 0o600)
+}
+
+// decodeHistoryLine reads one line of the history file. Files written before
+// entries were JSON-encoded hold plain lines with pasted newlines as "\n".
+func decodeHistoryLine(line string) string {
+	if strings.HasPrefix(line, `"`) {
+		var entry string
+		if err := json.Unmarshal([]byte(line), &entry); err == nil {
+			return entry
+		}
+	}
+	return strings.ReplaceAll(line, `\n`, "\n")
+}
+
+func containsSecret(text string) bool {
+	return historyRedactor.Contains(text)
+}
+
+// redactSecrets replaces anything that looks like a secret with a placeholder.
+func redactSecrets(text string) string {
+	text, _ = historyRedactor.Redact(text)
+	return text
+}
+
+func handleHistoryCommand(args []string, sessions *SessionState) (commandResult, error) {
+	history := sessions.History()
+	if history == nil {
+		return commandResult{}, fmt.Errorf("history is not available")
+	}
+	entries := history.Entries()
+
+	if len(args) > 0 && args[0] == "run" {
+		if len(args) != 2 {
+			return commandResult{}, fmt.Errorf("usage: /history run <n>")
+		}
+		idx, err := strconv.Atoi(args[1])
+		if err != nil || idx < 1 || idx > len(entries) {
+			return commandResult{}, fmt.Errorf("history entry out of range: %s", args[1])
+		}
+		entry := entries[idx-1]
+		if strings.HasPrefix(entry, "/history") {
+			return commandResult{}, fmt.Errorf("refusing to re-run a /history command")
+		}
+		fmt.Printf("Re-running: %s\n", truncateLine(singleLine(entry), maxToolPreview))
+		return commandResult{Submit: entry, SubmitPaste: strings.Contains(entry, "\n")}, nil
+	}
+
+	query := strings.ToLower(strings.TrimSpace(strings.Join(args, " ")))
+	var matches []int
+	for i := len(entries) - 1; i >= 0 && len(matches) < historyListDefault; i-- {
+		if query == "" || strings.Contains(strings.ToLower(entries[i]), query) {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		fmt.Println("No matching history entries.")
+		return commandResult{}, nil
+	}
+
+	if query == "" {
+		fmt.Println("Recent prompts:")
+	} else {
+		fmt.Printf("Prompts matching %q:\n", query)
+	}
+	for i := len(matches) - 1; i >= 0; i-- {
+		idx := matches[i]
+		fmt.Printf("%5d  %s\n", idx+1, truncateLine(singleLine(entries[idx]), maxToolPreview))
+	}
+	fmt.Println("Use /history run <n> to send one again.")
+	return commandResult{}, nil
+}
@@ -17,8 +17,10 @@ import (
 	"time"
 
 	"github.com/peterh/liner"
+	"github.com/sashabaranov/go-openai"
 	"github.com/unixsysdev/serena-cli-go/internal/config"
 	"github.com/unixsysdev/serena-cli-go/internal/orchestrator"
+	"github.com/unixsysdev/serena-cli-go/internal/workspace"
 )
 
 var version = "dev"
@@ -34,14 +36,15 @@ const (
 const autoCompactThreshold = 0.9
 
 const (
-	colorReset  = "\x1b[0m"
-	colorBold   = "\x1b[1m"
-	colorRed    = "\x1b[31m"
-	colorGreen  = "\x1b[32m"
-	colorYellow = "\x1b[33m"
-	colorBlue   = "\x1b[34m"
-	colorCyan   = "\x1b[36m"
-	colorGray   = "\x1b[90m"
+	colorReset   = "\x1b[0m"
+	colorBold    = "\x1b[1m"
+	colorRed     = "\x1b[31m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorBlue    = "\x1b[34m"
+	colorMagenta = "\x1b[35m"
+	colorCyan    = "\x1b[36m"
+	colorGray    = "\x1b[90m"
 )
 
 var availableModels = []string{
@@ -57,32 +60,50 @@ var availableModels = []string{
 }
 
 func main() {
-	var showConfig bool
+	var configFile string
 	var showVersion bool
-
-	flag.BoolVar(&showConfig, "config", false, "Print resolved configuration and exit")
-	flag.BoolVar(&showVersion, "version", false, "Print version and exit")
-	flag.Parse()
+	var tuiMode bool
+	var promptFlag string
+	overrides := settingFlags{}
+
+	flags := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	flags.StringVar(&configFile, "config", "", "Load configuration from this file instead of the user and project files (serena config show prints it)")
+	flags.Var(overrides, "set", "Override a config value as key=value (repeatable)")
+	flags.BoolVar(&showVersion, "version", false, "Print version and exit")
+	flags.BoolVar(&tuiMode, "tui", false, "Run the full-screen terminal UI")
+	flags.StringVar(&promptFlag, "p", "", "Send this prompt and exit, even when it starts with a subcommand name")
+	parseFlags(flags, os.Args[1:])
 
 	if showVersion {
 		fmt.Println(version)
 		return
 	}
 
-	cfg, err := config.LoadWithOptions(config.LoadOptions{SkipValidation: showConfig})
+	loadOpts := config.LoadOptions{ConfigFile: configFile, Overrides: overrides, CredentialPassphrase: credentialPassphrase}
+	prompt, explicitPrompt := promptFlag, promptFlag != "" || afterTerminator(os.Args[1:], flags.NArg())
+	if promptFlag != "" && flags.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "-p takes the whole prompt; quote it or use serena -- <prompt>")
+		os.Exit(2)
+	}
+	if !explicitPrompt {
+		if command, args, ok := lookupCLICommand(flags.Args()); ok {
+			if err := runCLICommand(command, args, loadOpts); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	if prompt == "" {
+		prompt = strings.Join(flags.Args(), " ")
+	}
+
+	cfg, err := config.LoadWithOptions(loadOpts)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	if showConfig {
-		if err := printConfig(cfg); err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
-		}
-		return
-	}
-
 	orch, err := orchestrator.New(cfg)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -97,39 +118,79 @@ func main() {
 		_ = orch.Close()
 	}()
 
+	if root, err := projectRoot(cfg); err == nil {
+		if tracker, err := workspace.NewTracker(root); err == nil {
+			orch.SetChangeTracker(tracker)
+		}
+	}
+
 	sessions, err := initSessionState(cfg, orch)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+	defer func() {
+		_ = sessions.Close()
+	}()
 
 	ui := attachConsoleUI(orch)
 
 	ctx := context.Background()
 
-	if flag.NArg() > 0 {
-		prompt := strings.Join(flag.Args(), " ")
-		resp, err := orch.Chat(ctx, prompt)
-		ui.StopSpinner()
-		_ = sessions.SaveFromOrch(orch)
-		if err != nil {
+	if prompt != "" {
+		if err := executeTurn(ctx, prompt, orchestrator.TurnOptions{}, orch, cfg, ui, sessions, newCancelTracker()); err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
-		printInteraction(prompt, resp, orch.Model())
 		return
 	}
 
-	if err := runREPL(ctx, orch, cfg, ui, sessions); err != nil {
+	run := runREPL
+	if tuiMode {
+		run = runTUI
+	}
+	if err := run(ctx, orch, cfg, ui, sessions); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
+// parseFlags parses the top-level flags, exiting like flag.ExitOnError. A
+// --config without a value gets a hint, since it used to print the
+// configuration.
+func parseFlags(flags *flag.FlagSet, args []string) {
+	flags.SetOutput(io.Discard)
+	err := flags.Parse(args)
+	flags.SetOutput(os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		flags.Usage()
+		os.Exit(0)
+	}
+	if err == nil {
+		return
+	}
+	// A flag missing its value can only be the last argument.
+	if last := args[len(args)-1]; last == "--config" || last == "-config" {
+		fmt.Fprintln(os.Stderr, "--config takes a config file path; use \"serena config show\" to print the configuration")
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+		flags.Usage()
+	}
+	os.Exit(2)
+}
+
 func runREPL(ctx context.Context, orch *orchestrator.Orchestrator, cfg *config.Config, ui *ConsoleUI, sessions *SessionState) error {
 	line := liner.NewLiner()
 	line.SetCtrlCAborts(true)
 	line.SetMultiLineMode(true)
+	line.SetTabCompletionStyle(liner.TabPrints)
+	line.SetWordCompleter(newCompleter(orch, cfg, sessions).Complete)
+	for _, entry := range sessions.History().Entries() {
+		// Pasted prompts are recalled with /history run.
+		if !strings.Contains(entry, "\n") {
+			line.AppendHistory(entry)
+		}
+	}
 	defer func() {
 		_ = line.Close()
 	}()
@@ -142,7 +203,12 @@ func runREPL(ctx context.Context, orch *orchestrator.Orchestrator, cfg *config.C
 	fmt.Fprint(os.Stderr, formatBanner("Session", sessions.Current(), "(use /session to manage)"))
 	for {
 		prompt := promptString(cfg, orch, sessions)
-		rawInput, wasPaste, err := readUserInput(line, prompt)
+		draft := sessions.TakeDraft()
+		if strings.Contains(draft, "\n") {
+			fmt.Printf("Original prompt:\n%s\nUse /paste to send an edited version.\n", draft)
+			draft = ""
+		}
+		rawInput, wasPaste, err := readUserInput(line, prompt, draft)
 		if err != nil {
 			if err == liner.ErrPromptAborted {
 				fmt.Println()
@@ -175,60 +241,132 @@ func runREPL(ctx context.Context, orch *orchestrator.Orchestrator, cfg *config.C
 		if text == "" {
 			continue
 		}
-		line.AppendHistory(historyEntry(text, wasPaste))
-
-		if !wasPaste && strings.HasPrefix(text, "@context") {
-			if err := handleContextImport(text, orch, sessions); err != nil {
-				fmt.Println(err)
-			} else {
-				fmt.Println("Context file added.")
-			}
-			continue
+		if !strings.Contains(text, "\n") {
+			line.AppendHistory(text)
 		}
-		if !wasPaste && strings.HasPrefix(text, "/") {
-			exit, err := handleCommand(ctx, text, orch, cfg, ui, sessions)
-			if err != nil {
-				fmt.Println(err)
-			}
-			if exit {
-				return nil
-			}
-			continue
+		recordHistory(sessions, text)
+
+		exit, err := processInput(ctx, text, wasPaste, orch, cfg, ui, sessions, cancelTracker)
+		if err != nil {
+			return err
 		}
-		if !wasPaste && (text == "exit" || text == "quit") {
+		if exit {
 			return nil
 		}
+	}
+}
 
-		requestCtx, cancel := context.WithCancel(ctx)
-		cancelTracker.Set(cancel)
-		resp, err := orch.Chat(requestCtx, text)
-		cancelTracker.Clear()
-		ui.StopSpinner()
-		if err := maybeAutoCompact(ctx, orch, sessions); err != nil {
-			fmt.Fprintln(os.Stderr, err)
+// processInput dispatches one line (or pasted block) of user input: context
+// imports, slash commands and regular chat turns. It returns true when the
+// user asked to exit; a non-nil error is fatal for the session.
+func processInput(ctx context.Context, text string, wasPaste bool, orch *orchestrator.Orchestrator, cfg *config.Config, ui *ConsoleUI, sessions *SessionState, tracker *cancelTracker) (bool, error) {
+	if !wasPaste && strings.HasPrefix(text, "@context") {
+		if err := handleContextImport(text, orch, cfg, sessions); err != nil {
+			fmt.Println(err)
 		}
-		_ = sessions.SaveFromOrch(orch)
+		return false, nil
+	}
+	if !wasPaste && strings.HasPrefix(text, "!") {
+		if err := handleShellEscape(ctx, text, orch, cfg, tracker); err != nil {
+			fmt.Println(err)
+		}
+		return false, nil
+	}
+	if !wasPaste && strings.HasPrefix(text, "/") {
+		result, err := handleCommand(ctx, text, orch, cfg, ui, sessions)
 		if err != nil {
-			if errors.Is(err, context.Canceled) {
-				fmt.Println("Request cancelled.")
-				continue
-			}
-			if isLLMError(err) {
-				fmt.Println(err)
-				fmt.Println("Tip: the provider rejected this model. Try /model to switch.")
-				continue
-			}
-			return err
+			fmt.Println(err)
+		}
+		if result.Submit != "" && result.Options != nil {
+			return runTurn(ctx, result.Submit, *result.Options, orch, cfg, ui, sessions, tracker)
 		}
+		if result.Submit != "" {
+			return processInput(ctx, result.Submit, result.SubmitPaste, orch, cfg, ui, sessions, tracker)
+		}
+		return result.Exit, nil
+	}
+	if !wasPaste && (text == "exit" || text == "quit") {
+		return true, nil
+	}
+
+	return runTurn(ctx, text, orchestrator.TurnOptions{}, orch, cfg, ui, sessions, tracker)
+}
+
+// runTurn sends one prompt to the model from the interactive loop. Cancelled
+// requests and provider rejections are reported without ending the loop.
+func runTurn(ctx context.Context, text string, opts orchestrator.TurnOptions, orch *orchestrator.Orchestrator, cfg *config.Config, ui *ConsoleUI, sessions *SessionState, tracker *cancelTracker) (bool, error) {
+	err := executeTurn(ctx, text, opts, orch, cfg, ui, sessions, tracker)
+	if err == nil {
+		return false, nil
+	}
+	if errors.Is(err, context.Canceled) {
+		fmt.Println("Request cancelled.")
+		return false, nil
+	}
+	if isLLMError(err) {
+		fmt.Println(err)
+		fmt.Println("Tip: the provider rejected this model. Try /model to switch.")
+		return false, nil
+	}
+	return false, err
+}
+
+// executeTurn sends one prompt to the model and prints the interaction. Inline
+// @path mentions are attached as context for this turn, and the turn is
+// checkpointed, compacted, saved and auto-committed the same way in one-shot
+// and interactive runs.
+func executeTurn(ctx context.Context, text string, opts orchestrator.TurnOptions, orch *orchestrator.Orchestrator, cfg *config.Config, ui *ConsoleUI, sessions *SessionState, tracker *cancelTracker) error {
+	opts.Attachments = append(opts.Attachments, mentionAttachments(text, cfg)...)
+
+	model := orch.Model()
+	if opts.Model != "" {
+		model = opts.Model
+	}
+
+	messageCount := len(orch.Messages())
+	requestCtx, cancel := context.WithCancel(ctx)
+	tracker.Set(cancel)
+	resp, err := orch.ChatWithOptions(requestCtx, text, opts)
+	tracker.Clear()
+	cancel()
+	ui.StopSpinner()
+	if err := recordCheckpoint(orch, sessions, text, messageCount); err != nil {
+		fmt.Fprintf(os.Stderr, "Checkpoint failed: %v\n", err)
+	}
+	if err := maybeAutoCompact(ctx, orch, sessions); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if saveErr := sessions.SaveFromOrch(orch); saveErr != nil {
+		fmt.Fprintln(os.Stderr, saveErr)
+	}
+	if err != nil {
+		return err
+	}
 
-		printInteraction(text, resp, orch.Model())
+	changes := turnChanges(orch)
+	printInteraction(text, resp, model, changes)
+	if cfg.AutoCommit && len(changes) > 0 {
+		if err := autoCommitTurn(ctx, orch, cfg, sessions, text, resp, changes); err != nil {
+			fmt.Fprintf(os.Stderr, "Auto-commit failed: %v\n", err)
+		}
 	}
+	return nil
+}
+
+// commandResult tells the input loop what to do after a slash command.
+type commandResult struct {
+	Exit        bool
+	Submit      string
+	SubmitPaste bool
+	// Options, when set, sends Submit straight to the model with these
+	// per-turn options instead of dispatching it as user input.
+	Options *orchestrator.TurnOptions
 }
 
-func handleCommand(ctx context.Context, line string, orch *orchestrator.Orchestrator, cfg *config.Config, ui *ConsoleUI, sessions *SessionState) (bool, error) {
+func handleCommand(ctx context.Context, line string, orch *orchestrator.Orchestrator, cfg *config.Config, ui *ConsoleUI, sessions *SessionState) (commandResult, error) {
 	fields := strings.Fields(line)
 	if len(fields) == 0 {
-		return false, nil
+		return commandResult{}, nil
 	}
 
 	cmd := strings.TrimPrefix(fields[0], "/")
@@ -236,38 +374,65 @@ func handleCommand(ctx context.Context, line string, orch *orchestrator.Orchestr
 
 	switch cmd {
 	case "exit", "quit":
-		return true, nil
+		return commandResult{Exit: true}, nil
 	case "help":
-		printHelp()
-		return false, nil
+		printHelp(cfg)
+		return commandResult{}, nil
 	case "model", "models":
-		return false, handleModelCommand(cmd, args, orch, sessions)
+		return commandResult{}, handleModelCommand(cmd, args, orch, sessions)
 	case "tools":
-		return false, listTools(orch)
+		return commandResult{}, listTools(orch, args)
 	case "status":
-		return false, printStatus(orch, cfg, sessions)
+		return commandResult{}, printStatus(orch, cfg, sessions)
 	case "context":
-		return false, printContext(orch)
+		return commandResult{}, handleContextCommand(line, args, orch, cfg, sessions)
 	case "trace":
-		return false, ui.PrintTrace(args)
+		return commandResult{}, ui.PrintTrace(args)
 	case "summary":
-		return false, handleSummaryCommand(ctx, orch, sessions, args)
+		return commandResult{}, handleSummaryCommand(ctx, orch, sessions, args)
 	case "session":
-		return false, handleSessionCommand(args, orch, sessions, ui)
+		return commandResult{}, handleSessionCommand(args, orch, sessions, ui)
 	case "compact":
-		return false, compactSession(ctx, orch, sessions)
+		return commandResult{}, compactSession(ctx, orch, sessions)
 	case "clear":
 		clearScreen()
-		return false, nil
+		return commandResult{}, nil
 	case "config":
-		return false, printConfig(cfg)
+		return commandResult{}, handleConfigCommand(args, cfg)
+	case "raw":
+		if markdownOutput.ToggleRaw() {
+			fmt.Println("Raw output enabled; responses are printed unrendered.")
+		} else {
+			fmt.Println("Raw output disabled; responses are rendered as Markdown.")
+		}
+		return commandResult{}, nil
+	case "history":
+		return handleHistoryCommand(args, sessions)
+	case "diff":
+		return commandResult{}, handleDiffCommand(args, orch)
+	case "rewind":
+		return handleRewindCommand(line, args, orch, sessions)
+	case "search":
+		return commandResult{}, handleSearchCommand(args, orch, sessions)
+	case "archive":
+		return commandResult{}, handleArchiveCommand(ctx, args, orch, sessions)
+	case "undo":
+		return commandResult{}, handleUndoCommand(args, orch, sessions)
+	case "redactions":
+		return commandResult{}, handleRedactionsCommand(args, orch, cfg, sessions)
+	case "checkpoint", "checkpoints":
+		return commandResult{}, handleCheckpointCommand(args, orch, sessions)
 	case "reset":
 		orch.Reset()
+		forgetCheckpointMessages(sessions)
 		_ = sessions.SaveFromOrch(orch)
 		fmt.Println("Conversation reset.")
-		return false, nil
+		return commandResult{}, nil
 	default:
-		return false, fmt.Errorf("unknown command: %s (try /help)", fields[0])
+		if result, ok := runCustomCommand(cfg, cmd, line); ok {
+			return result, nil
+		}
+		return commandResult{}, fmt.Errorf("unknown command: %s (try /help)", fields[0])
 	}
 }
 
@@ -319,24 +484,37 @@ func listModels(current string) {
 	fmt.Println("Use /model <number|name> to switch.")
 }
 
-func printHelp() {
+func printHelp(cfg *config.Config) {
 	fmt.Println("Commands:")
 	fmt.Println("  /help           Show this help")
 	fmt.Println("  /model          List models")
 	fmt.Println("  /model <value>  Switch model by index or name")
 	fmt.Println("  /models         Alias for /model")
-	fmt.Println("  /tools          List available tools")
+	fmt.Println("  /tools [name]   List available tools or show one tool's schema")
 	fmt.Println("  /status         Show current status")
-	fmt.Println("  /context        Show context usage")
+	fmt.Println("  /context        Show context usage; list, add, drop <label>, refresh")
 	fmt.Println("  /trace [n]      Show recent tool calls")
 	fmt.Println("  /summary        Show or refresh the session summary")
-	fmt.Println("  /session ...    Manage sessions (list/new/switch/delete)")
+	fmt.Println("  /session ...    Manage sessions (list/new/switch/fork/rename/delete/info/tag/pin/...)")
 	fmt.Println("  /compact        Compact older context into a summary")
 	fmt.Println("  /clear          Clear the screen")
-	fmt.Println("  /config         Show resolved config (API key masked)")
+	fmt.Println("  /config ...     Show resolved config (API key masked); --origin adds where values came from")
+	fmt.Println("  /raw            Toggle raw (unrendered) model output")
+	fmt.Println("  /history [q]    Search previous prompts; /history run <n> re-sends one")
+	fmt.Println("  /diff [session] Show file changes from the last turn or the whole session")
+	fmt.Println("  /rewind [n]     List turns, or drop turn n and later to edit and resend it")
+	fmt.Println("  /search <query> Search all sessions and archives of this project")
+	fmt.Println("  /archive ...    List archived epochs; show, restore or summarize <n>")
+	fmt.Println("  /undo [n]       Revert files and conversation to before the last n turns")
+	fmt.Println("  /checkpoint ... List checkpoints or restore one (restore <id>)")
+	fmt.Println("  /redactions     Show which secrets were redacted in this session")
 	fmt.Println("  /reset          Clear the conversation context")
 	fmt.Println("  /exit, /quit    Exit the CLI")
-	fmt.Println("  @context <file> Append a file to the system context")
+	fmt.Println("  @context <path> Attach files, directories or globs to the session context")
+	fmt.Println("  @path, @dir/, @file:10-40 anywhere in a prompt attach files for that turn")
+	fmt.Println("  !<command>      Run a shell command in the project directory")
+	fmt.Println("  !!<command>     Run it and attach its output to your next message")
+	printCustomCommands(cfg)
 }
 
 func attachConsoleUI(orch *orchestrator.Orchestrator) *ConsoleUI {
@@ -345,12 +523,15 @@ func attachConsoleUI(orch *orchestrator.Orchestrator) *ConsoleUI {
 	return ui
 }
 
-func listTools(orch *orchestrator.Orchestrator) error {
+func listTools(orch *orchestrator.Orchestrator, args []string) error {
 	tools := orch.Tools()
 	if len(tools) == 0 {
 		fmt.Println("No tools loaded.")
 		return nil
 	}
+	if len(args) > 0 {
+		return printToolDetail(tools, args[0])
+	}
 	fmt.Println("Available tools:")
 	for _, tool := range tools {
 		if tool.Function == nil {
@@ -361,6 +542,24 @@ func listTools(orch *orchestrator.Orchestrator) error {
 	return nil
 }
 
+func printToolDetail(tools []openai.Tool, name string) error {
+	for _, tool := range tools {
+		if tool.Function == nil || tool.Function.Name != name {
+			continue
+		}
+		fmt.Printf("%s\n%s\n", tool.Function.Name, tool.Function.Description)
+		if tool.Function.Parameters != nil {
+			data, err := json.MarshalIndent(tool.Function.Parameters, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Parameters:\n%s\n", data)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown tool: %s (try /tools)", name)
+}
+
 func printContext(orch *orchestrator.Orchestrator) error {
 	stats := orch.ConversationStats()
 	percent := (float64(stats.ApproxTokens) / float64(contextLimitTokens)) * 100
@@ -399,6 +598,9 @@ func printStatus(orch *orchestrator.Orchestrator, cfg *config.Config, sessions *
 	fmt.Printf("Tools loaded: %d\n", len(orch.Tools()))
 	fmt.Printf("Session: %s\n", sessions.Current())
 	fmt.Printf("Approx tokens: %d / %d (%.1f%%)\n", stats.ApproxTokens, contextLimitTokens, percent)
+	if pending := orch.PendingAttachments(); len(pending) > 0 {
+		fmt.Printf("Pending attachments: %d (sent with your next message)\n", len(pending))
+	}
 	return nil
 }
 
@@ -411,8 +613,25 @@ func truncateLine(line string, maxLen int) string {
 }
 
 func printConfig(cfg *config.Config) error {
+	data, err := json.MarshalIndent(configDisplay(cfg), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(data))
+	return nil
+}
+
+// configDisplay returns the resolved configuration with the API key masked.
+func configDisplay(cfg *config.Config) map[string]interface{} {
+	redactionPatterns := make([]map[string]string, 0, len(cfg.Redaction.Patterns))
+	for _, pattern := range cfg.Redaction.Patterns {
+		redactionPatterns = append(redactionPatterns, map[string]string{"name": pattern.Name, "regex": pattern.Regex})
+	}
+
 	display := map[string]interface{}{
 		"llm": map[string]string{
+			"provider":         cfg.LLM.Provider,
 			"api_key":          maskKey(cfg.LLM.APIKey),
 			"base_url":         cfg.LLM.BaseURL,
 			"model":            cfg.LLM.Model,
@@ -429,21 +648,32 @@ func printConfig(cfg *config.Config) error {
 			"enable_gui_log_window": cfg.Serena.EnableGuiLogWindow,
 			"max_tool_answer_chars": cfg.Serena.MaxToolAnswerChars,
 		},
-		"debug": cfg.Debug,
+		"debug":              cfg.Debug,
+		"auto_commit":        cfg.AutoCommit,
+		"auto_commit_branch": cfg.AutoCommitBranch,
+		"session_backend":    cfg.SessionBackend,
+		"session_retention": map[string]interface{}{
+			"max_age_days": cfg.SessionRetention.MaxAgeDays,
+			"max_count":    cfg.SessionRetention.MaxCount,
+			"max_size_mb":  cfg.SessionRetention.MaxSizeMB,
+		},
+		"session_encryption": map[string]interface{}{
+			"enabled":  cfg.SessionEncryption.Enabled,
+			"key_file": cfg.SessionEncryption.KeyFile,
+		},
+		"credential_store": cfg.CredentialStore,
+		"redaction": map[string]interface{}{
+			"enabled":  cfg.Redaction.Enabled,
+			"entropy":  cfg.Redaction.Entropy,
+			"patterns": redactionPatterns,
+		},
 	}
 
 	if len(cfg.Serena.Env) > 0 {
 		serena := display["serena"].(map[string]interface{})
 		serena["env"] = cfg.Serena.Env
 	}
-
-	data, err := json.MarshalIndent(display, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	fmt.Println(string(data))
-	return nil
+	return display
 }
 
 func maskKey(key string) string {
@@ -473,6 +703,9 @@ type ConsoleUI struct {
 	spinnerStop chan struct{}
 	toolHistory []ToolEvent
 	currentTool *ToolEvent
+	status      string
+	quiet       bool
+	notify      func()
 }
 
 func NewConsoleUI(out *os.File) *ConsoleUI {
@@ -490,16 +723,45 @@ func (ui *ConsoleUI) Handler() *orchestrator.EventHandler {
 	}
 }
 
+// SetQuiet disables inline output and the spinner, and registers a callback
+// invoked after every event. The TUI uses it to render tool activity itself.
+func (ui *ConsoleUI) SetQuiet(notify func()) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	ui.stopSpinnerLocked()
+	ui.quiet = true
+	ui.notify = notify
+}
+
+// Activity returns the current status message and the running tool, if any.
+func (ui *ConsoleUI) Activity() (string, *ToolEvent) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	if ui.currentTool == nil {
+		return ui.status, nil
+	}
+	current := *ui.currentTool
+	return ui.status, &current
+}
+
 func (ui *ConsoleUI) StopSpinner() {
 	ui.mu.Lock()
 	defer ui.mu.Unlock()
 	ui.stopSpinnerLocked()
+	ui.status = ""
+	ui.notifyLocked()
 }
 
 func (ui *ConsoleUI) handleStatus(message string) {
 	ui.mu.Lock()
 	defer ui.mu.Unlock()
 
+	ui.status = strings.TrimSpace(message)
+	if ui.quiet {
+		ui.notifyLocked()
+		return
+	}
+
 	if isThinkingStatus(message) {
 		ui.startSpinnerLocked("thinking", normalizeStatus(message))
 		return
@@ -525,6 +787,12 @@ func (ui *ConsoleUI) handleToolStart(name string, args string) {
 		Started: time.Now(),
 	}
 	ui.currentTool = event
+	ui.status = "tool " + name
+
+	if ui.quiet {
+		ui.notifyLocked()
+		return
+	}
 
 	if args == "" {
 		fmt.Fprintf(ui.out, "%s %s\n", ui.colorize(colorBlue, "[tool]"), name)
@@ -556,6 +824,11 @@ func (ui *ConsoleUI) handleToolEnd(name string, result string, isError bool) {
 	ui.currentTool = nil
 	ui.appendToolEvent(*event)
 
+	if ui.quiet {
+		ui.notifyLocked()
+		return
+	}
+
 	duration := formatDuration(event.Duration)
 	if isError {
 		fmt.Fprintf(ui.out, "%s %s %s (%s): %s\n", ui.colorize(colorBlue, "[tool]"), name, ui.colorize(colorRed, "error"), duration, truncateLine(singleLine(result), maxToolPreview))
@@ -629,6 +902,12 @@ func (ui *ConsoleUI) appendToolEvent(event ToolEvent) {
 	}
 }
 
+func (ui *ConsoleUI) notifyLocked() {
+	if ui.notify != nil {
+		ui.notify()
+	}
+}
+
 func (ui *ConsoleUI) startSpinnerLocked(label string, message string) {
 	ui.stopSpinnerLocked()
 
@@ -815,28 +1094,16 @@ func handleSummaryCommand(ctx context.Context, orch *orchestrator.Orchestrator,
 	return nil
 }
 
-func handleContextImport(line string, orch *orchestrator.Orchestrator, sessions *SessionState) error {
-	fields := strings.Fields(line)
-	if len(fields) < 2 {
-		return fmt.Errorf("usage: @context <path>")
-	}
-	path := strings.Join(fields[1:], " ")
-	path = expandHome(path)
-
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return err
+// readUserInput reads one line, or several joined by trailing backslashes.
+// A non-empty draft pre-fills the first line for editing.
+func readUserInput(line *liner.State, prompt string, draft string) (string, bool, error) {
+	var input string
+	var err error
+	if draft != "" {
+		input, err = line.PromptWithSuggestion(prompt, draft, -1)
+	} else {
+		input, err = line.Prompt(prompt)
 	}
-	if len(data) > maxContextFileSize {
-		return fmt.Errorf("context file too large (%d bytes); limit is %d bytes", len(data), maxContextFileSize)
-	}
-
-	orch.AddContext(path, string(data))
-	return sessions.SaveFromOrch(orch)
-}
-
-func readUserInput(line *liner.State, prompt string) (string, bool, error) {
-	input, err := line.Prompt(prompt)
 	if err != nil {
 		return "", false, err
 	}
@@ -909,15 +1176,14 @@ func printPastePreview(text string) {
 	}
 }
 
-func historyEntry(text string, wasPaste bool) string {
-	if !wasPaste {
-		return text
+// recordHistory persists an input line to the project history file.
+func recordHistory(sessions *SessionState, entry string) {
+	if strings.HasPrefix(entry, "/history run") {
+		return
 	}
-	entry := strings.ReplaceAll(text, "\n", "\\n")
-	if len(entry) > 400 {
-		return entry[:400] + "..."
+	if _, err := sessions.History().Add(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "history: %v\n", err)
 	}
-	return entry
 }
 
 func expandHome(path string) string {
@@ -995,7 +1261,7 @@ func startCancelWatcher(tracker *cancelTracker, ui *ConsoleUI) func() {
 	}
 }
 
-func printInteraction(task string, response string, model string) {
+func printInteraction(task string, response string, model string, changes []workspace.FileStat) {
 	task = strings.TrimSpace(task)
 	response = strings.TrimSpace(response)
 
@@ -1007,7 +1273,12 @@ func printInteraction(task string, response string, model string) {
 	if response != "" {
 		label := fmt.Sprintf("MODEL RESPONSE (%s)", shortModelName(model))
 		fmt.Println(formatDivider(label))
-		fmt.Println(response)
+		fmt.Println(renderResponse(response))
+	}
+	if len(changes) > 0 {
+		fmt.Println(formatDivider(fmt.Sprintf("FILES CHANGED (%d)", len(changes))))
+		printFileStats(changes, maxChangeSummaryFiles)
+		fmt.Println("Use /diff to see the changes.")
 	}
 	fmt.Println(strings.Repeat("-", 60))
 }
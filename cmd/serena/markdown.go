@@ -0,0 +1,590 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+const (
+	ansiItalic     = "\x1b[3m"
+	ansiUnderline  = "\x1b[4m"
+	ansiBoldOff    = "\x1b[22m"
+	ansiItalicOff  = "\x1b[23m"
+	ansiUnderOff   = "\x1b[24m"
+	ansiDefaultFg  = "\x1b[39m"
+	defaultMDWidth = 80
+)
+
+// markdownSettings controls how model responses are rendered.
+type markdownSettings struct {
+	mu    sync.Mutex
+	raw   bool
+	force bool
+	width int
+}
+
+var markdownOutput = &markdownSettings{}
+
+// ToggleRaw flips raw output mode and returns the new value.
+func (m *markdownSettings) ToggleRaw() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.raw = !m.raw
+	return m.raw
+}
+
+// SetTarget forces rendering at a fixed width even when stdout is not a
+// terminal. The TUI uses it because its output is captured through a pipe.
+func (m *markdownSettings) SetTarget(force bool, width int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.force = force
+	m.width = width
+}
+
+func (m *markdownSettings) enabled() bool {
+	m.mu.Lock()
+	raw := m.raw
+	m.mu.Unlock()
+	return !raw && m.colorEnabled()
+}
+
+// colorEnabled reports whether stdout accepts ANSI colors, regardless of the
+// /raw toggle.
+func (m *markdownSettings) colorEnabled() bool {
+	if !useColor() {
+		return false
+	}
+	m.mu.Lock()
+	force := m.force
+	m.mu.Unlock()
+	return force || term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+func (m *markdownSettings) targetWidth() int {
+	m.mu.Lock()
+	width := m.width
+	m.mu.Unlock()
+	if width > 0 {
+		return width
+	}
+	if w, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && w > 0 {
+		return w
+	}
+	return defaultMDWidth
+}
+
+// renderResponse formats a model response for the terminal, falling back to
+// the raw text when rendering is disabled.
+func renderResponse(text string) string {
+	if !markdownOutput.enabled() {
+		return text
+	}
+	return renderMarkdown(text, markdownOutput.targetWidth())
+}
+
+var (
+	mdHeadingPattern  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdListPattern     = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	mdQuotePattern    = regexp.MustCompile(`^\s{0,3}>\s?(.*)$`)
+	mdTableSepPattern = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	mdBoldPattern     = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdItalicPattern   = regexp.MustCompile(`(^|[^\w*])\*([^*\s][^*]*?)\*`)
+	mdLinkPattern     = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdStrikePattern   = regexp.MustCompile(`~~([^~]+)~~`)
+	mdFencePattern    = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([\\w+#.-]*)")
+	mdTaskPattern     = regexp.MustCompile(`^\[([ xX])\]\s+`)
+)
+
+// renderMarkdown renders a subset of Markdown (headings, lists, quotes, rules,
+// tables, inline styles and fenced code) as ANSI text wrapped to width.
+func renderMarkdown(text string, width int) string {
+	if width < 20 {
+		width = 20
+	}
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var out []string
+	blank := false
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+
+		if m := mdFencePattern.FindStringSubmatch(line); m != nil {
+			fence := m[1]
+			var code []string
+			j := i + 1
+			for ; j < len(lines); j++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[j]), fence[:3]) {
+					break
+				}
+				code = append(code, lines[j])
+			}
+			out = append(out, renderCodeBlock(code, m[2])...)
+			i = j
+			continue
+		}
+
+		if isTableRow(line) && i+1 < len(lines) && mdTableSepPattern.MatchString(lines[i+1]) && strings.Contains(lines[i+1], "-") {
+			rows := [][]string{splitTableRow(line)}
+			aligns := parseTableAligns(lines[i+1])
+			j := i + 2
+			for ; j < len(lines) && isTableRow(lines[j]); j++ {
+				rows = append(rows, splitTableRow(lines[j]))
+			}
+			out = append(out, renderTable(rows, aligns, width)...)
+			i = j - 1
+			continue
+		}
+
+		if m := mdHeadingPattern.FindStringSubmatch(trimmed); m != nil {
+			style := colorBold
+			if len(m[1]) <= 2 {
+				style += colorCyan
+			}
+			heading := renderInline(m[2])
+			if len(m[1]) == 1 {
+				heading = ansiUnderline + heading
+			}
+			out = append(out, wrapANSI(style+heading+colorReset, width)...)
+			continue
+		}
+
+		if isRule(trimmed) {
+			ruleWidth := width
+			if ruleWidth > 60 {
+				ruleWidth = 60
+			}
+			out = append(out, colorGray+strings.Repeat("─", ruleWidth)+colorReset)
+			continue
+		}
+
+		if m := mdQuotePattern.FindStringSubmatch(line); m != nil {
+			prefix := colorGray + "│ " + colorReset
+			for _, row := range wrapANSI(ansiItalic+renderInline(m[1])+ansiItalicOff, width-2) {
+				out = append(out, prefix+row)
+			}
+			continue
+		}
+
+		if m := mdListPattern.FindStringSubmatch(line); m != nil {
+			indent := strings.Repeat(" ", len(strings.ReplaceAll(m[1], "\t", "  ")))
+			marker := m[2]
+			body := m[3]
+			switch marker {
+			case "-", "*", "+":
+				marker = "•"
+			}
+			if task := mdTaskPattern.FindStringSubmatch(body); task != nil {
+				box := "☐"
+				if task[1] != " " {
+					box = "☑"
+				}
+				body = box + " " + body[len(task[0]):]
+			}
+			out = append(out, hangingWrap(indent+colorCyan+marker+colorReset+" ", renderInline(body), width)...)
+			continue
+		}
+
+		out = append(out, wrapANSI(renderInline(line), width)...)
+	}
+
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	return strings.Join(out, "\n")
+}
+
+// isRule reports whether line is a thematic break such as "---" or "* * *".
+func isRule(line string) bool {
+	compact := strings.ReplaceAll(line, " ", "")
+	if len(compact) < 3 {
+		return false
+	}
+	return strings.Count(compact, compact[:1]) == len(compact) && strings.ContainsAny(compact[:1], "-*_")
+}
+
+// hangingWrap wraps body after prefix, indenting continuation rows to align
+// with the first character of body.
+func hangingWrap(prefix string, body string, width int) []string {
+	prefixWidth := ansiWidth(prefix)
+	avail := width - prefixWidth
+	if avail < 10 {
+		avail = 10
+	}
+	rows := wrapANSI(body, avail)
+	out := make([]string, len(rows))
+	pad := strings.Repeat(" ", prefixWidth)
+	for i, row := range rows {
+		if i == 0 {
+			out[i] = prefix + row
+			continue
+		}
+		out[i] = pad + row
+	}
+	return out
+}
+
+// renderInline applies code spans, links, bold, italic and strikethrough.
+func renderInline(text string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(text, "`")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(text[start+1:], "`")
+		if end < 0 {
+			break
+		}
+		b.WriteString(renderEmphasis(text[:start]))
+		b.WriteString(colorYellow + text[start+1:start+1+end] + ansiDefaultFg)
+		text = text[start+end+2:]
+	}
+	b.WriteString(renderEmphasis(text))
+	return b.String()
+}
+
+func renderEmphasis(text string) string {
+	if text == "" {
+		return text
+	}
+	text = mdLinkPattern.ReplaceAllString(text, ansiUnderline+"$1"+ansiUnderOff+colorGray+" ($2)"+ansiDefaultFg)
+	text = mdBoldPattern.ReplaceAllString(text, colorBold+"$1$2"+ansiBoldOff)
+	text = mdItalicPattern.ReplaceAllString(text, "$1"+ansiItalic+"$2"+ansiItalicOff)
+	text = mdStrikePattern.ReplaceAllString(text, "\x1b[9m$1\x1b[29m")
+	return text
+}
+
+func renderCodeBlock(lines []string, lang string) []string {
+	label := strings.ToLower(strings.TrimSpace(lang))
+	header := colorGray + "╭─"
+	if label != "" {
+		header += " " + label
+	}
+	header += colorReset
+
+	out := []string{header}
+	for _, line := range highlightCode(lines, label) {
+		out = append(out, colorGray+"│ "+colorReset+line+colorReset)
+	}
+	out = append(out, colorGray+"╰─"+colorReset)
+	return out
+}
+
+func isTableRow(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "|") && strings.Count(trimmed, "|") >= 2
+}
+
+func splitTableRow(line string) []string {
+	trimmed := strings.TrimSpace(line)
+	trimmed = strings.TrimPrefix(trimmed, "|")
+	trimmed = strings.TrimSuffix(trimmed, "|")
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(trimmed); i++ {
+		switch {
+		case trimmed[i] == '\\' && i+1 < len(trimmed) && trimmed[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case trimmed[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(trimmed[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+func parseTableAligns(line string) []byte {
+	cells := splitTableRow(line)
+	aligns := make([]byte, len(cells))
+	for i, cell := range cells {
+		left := strings.HasPrefix(cell, ":")
+		right := strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			aligns[i] = 'c'
+		case right:
+			aligns[i] = 'r'
+		default:
+			aligns[i] = 'l'
+		}
+	}
+	return aligns
+}
+
+func renderTable(rows [][]string, aligns []byte, width int) []string {
+	cols := 0
+	for _, row := range rows {
+		if len(row) > cols {
+			cols = len(row)
+		}
+	}
+	rendered := make([][]string, len(rows))
+	widths := make([]int, cols)
+	for i, row := range rows {
+		rendered[i] = make([]string, cols)
+		for c := 0; c < cols; c++ {
+			if c < len(row) {
+				rendered[i][c] = renderInline(row[c])
+			}
+			if w := ansiWidth(rendered[i][c]); w > widths[c] {
+				widths[c] = w
+			}
+		}
+	}
+
+	// Shrink the widest columns until the table fits.
+	for total(widths)+3*cols+1 > width {
+		widest := 0
+		for c := range widths {
+			if widths[c] > widths[widest] {
+				widest = c
+			}
+		}
+		if widths[widest] <= 3 {
+			break
+		}
+		widths[widest]--
+	}
+
+	border := func(left, mid, right string) string {
+		parts := make([]string, cols)
+		for c := range parts {
+			parts[c] = strings.Repeat("─", widths[c]+2)
+		}
+		return colorGray + left + strings.Join(parts, mid) + right + colorReset
+	}
+
+	sep := colorGray + "│" + colorReset
+	out := []string{border("┌", "┬", "┐")}
+	for i, row := range rendered {
+		var b strings.Builder
+		b.WriteString(sep)
+		for c, cell := range row {
+			align := byte('l')
+			if c < len(aligns) {
+				align = aligns[c]
+			}
+			if i == 0 {
+				cell = colorBold + cell + ansiBoldOff
+			}
+			b.WriteString(" " + alignCell(cell, widths[c], align) + " " + sep)
+		}
+		out = append(out, b.String())
+		if i == 0 {
+			out = append(out, border("├", "┼", "┤"))
+		}
+	}
+	return append(out, border("└", "┴", "┘"))
+}
+
+func alignCell(cell string, width int, align byte) string {
+	cell = truncateANSI(cell, width)
+	pad := width - ansiWidth(cell)
+	if pad <= 0 {
+		return cell
+	}
+	switch align {
+	case 'r':
+		return strings.Repeat(" ", pad) + cell
+	case 'c':
+		left := pad / 2
+		return strings.Repeat(" ", left) + cell + strings.Repeat(" ", pad-left)
+	default:
+		return cell + strings.Repeat(" ", pad)
+	}
+}
+
+func total(values []int) int {
+	sum := 0
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
+
+// syntaxSpec describes just enough of a language to colour keywords, strings,
+// comments and numbers line by line.
+type syntaxSpec struct {
+	keywords     map[string]bool
+	lineComments []string
+	blockStart   string
+	blockEnd     string
+	quotes       string
+}
+
+func newSyntax(keywords string, lineComments []string, blockStart, blockEnd, quotes string) *syntaxSpec {
+	set := make(map[string]bool)
+	for _, kw := range strings.Fields(keywords) {
+		set[kw] = true
+	}
+	return &syntaxSpec{keywords: set, lineComments: lineComments, blockStart: blockStart, blockEnd: blockEnd, quotes: quotes}
+}
+
+var syntaxSpecs = map[string]*syntaxSpec{
+	"go": newSyntax("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false iota error string int int64 bool byte rune any",
+		[]string{"//"}, "/*", "*/", "\"'`"),
+	"python": newSyntax("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield None True False self",
+		[]string{"#"}, "", "", "\"'"),
+	"js": newSyntax("async await break case catch class const continue debugger default delete do else export extends finally for function if import in instanceof let new of return static super switch this throw try typeof var void while yield null undefined true false interface type enum implements",
+		[]string{"//"}, "/*", "*/", "\"'`"),
+	"rust": newSyntax("as async await break const continue crate dyn else enum extern false fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait true type unsafe use where while Some None Ok Err",
+		[]string{"//"}, "/*", "*/", "\""),
+	"c": newSyntax("auto break case char class const continue default delete do double else enum extern float for goto if inline int long namespace new nullptr private protected public register return short signed sizeof static struct switch template this typedef union unsigned using virtual void volatile while true false NULL",
+		[]string{"//"}, "/*", "*/", "\"'"),
+	"java": newSyntax("abstract boolean break byte case catch char class const continue default do double else enum extends final finally float for if implements import instanceof int interface long new package private protected public return short static super switch this throw throws try void while null true false var val fun when object",
+		[]string{"//"}, "/*", "*/", "\"'"),
+	"sh": newSyntax("if then else elif fi for while until do done case esac function in return export local readonly set unset echo exit",
+		[]string{"#"}, "", "", "\"'"),
+	"sql": newSyntax("select from where insert into values update set delete create table drop alter index join left right inner outer on group by order having limit offset and or not null as distinct union all primary key SELECT FROM WHERE INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE DROP ALTER INDEX JOIN LEFT RIGHT INNER OUTER ON GROUP BY ORDER HAVING LIMIT OFFSET AND OR NOT NULL AS DISTINCT UNION ALL PRIMARY KEY",
+		[]string{"--"}, "/*", "*/", "'\""),
+	"json": newSyntax("true false null", nil, "", "", "\""),
+	"yaml": newSyntax("true false null yes no on off", []string{"#"}, "", "", "\"'"),
+}
+
+var syntaxAliases = map[string]string{
+	"golang": "go", "py": "python", "python3": "python",
+	"javascript": "js", "ts": "js", "typescript": "js", "jsx": "js", "tsx": "js",
+	"rs": "rust", "cpp": "c", "c++": "c", "h": "c", "hpp": "c", "cc": "c",
+	"kotlin": "java", "kt": "java", "bash": "sh", "shell": "sh", "zsh": "sh", "console": "sh",
+	"yml": "yaml",
+}
+
+func lookupSyntax(lang string) *syntaxSpec {
+	if alias, ok := syntaxAliases[lang]; ok {
+		lang = alias
+	}
+	return syntaxSpecs[lang]
+}
+
+func highlightCode(lines []string, lang string) []string {
+	spec := lookupSyntax(lang)
+	if spec == nil {
+		return lines
+	}
+	out := make([]string, len(lines))
+	inBlock := false
+	for i, line := range lines {
+		out[i] = spec.highlight(strings.ReplaceAll(line, "\t", "    "), &inBlock)
+	}
+	return out
+}
+
+func (s *syntaxSpec) highlight(line string, inBlock *bool) string {
+	var b strings.Builder
+	for i := 0; i < len(line); {
+		if *inBlock {
+			end := strings.Index(line[i:], s.blockEnd)
+			if end < 0 {
+				b.WriteString(colorGray + line[i:] + colorReset)
+				return b.String()
+			}
+			end += i + len(s.blockEnd)
+			b.WriteString(colorGray + line[i:end] + colorReset)
+			*inBlock = false
+			i = end
+			continue
+		}
+
+		rest := line[i:]
+		if s.blockStart != "" && strings.HasPrefix(rest, s.blockStart) {
+			*inBlock = true
+			b.WriteString(colorGray + s.blockStart)
+			i += len(s.blockStart)
+			end := strings.Index(line[i:], s.blockEnd)
+			if end < 0 {
+				b.WriteString(line[i:] + colorReset)
+				return b.String()
+			}
+			end += i + len(s.blockEnd)
+			b.WriteString(line[i:end] + colorReset)
+			*inBlock = false
+			i = end
+			continue
+		}
+		if s.isLineComment(rest) {
+			b.WriteString(colorGray + rest + colorReset)
+			return b.String()
+		}
+
+		c := line[i]
+		switch {
+		case strings.IndexByte(s.quotes, c) >= 0:
+			end := i + 1
+			for end < len(line) && line[end] != c {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end < len(line) {
+				end++
+			} else {
+				end = len(line)
+			}
+			b.WriteString(colorGreen + line[i:end] + colorReset)
+			i = end
+		case isDigit(c) && (i == 0 || !isIdentByte(line[i-1])):
+			end := i
+			for end < len(line) && (isIdentByte(line[end]) || line[end] == '.') {
+				end++
+			}
+			b.WriteString(colorYellow + line[i:end] + colorReset)
+			i = end
+		case isIdentByte(c):
+			end := i
+			for end < len(line) && isIdentByte(line[end]) {
+				end++
+			}
+			word := line[i:end]
+			switch {
+			case s.keywords[word]:
+				b.WriteString(colorMagenta + word + colorReset)
+			case end < len(line) && line[end] == '(':
+				b.WriteString(colorBlue + word + colorReset)
+			default:
+				b.WriteString(word)
+			}
+			i = end
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+func (s *syntaxSpec) isLineComment(text string) bool {
+	for _, prefix := range s.lineComments {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
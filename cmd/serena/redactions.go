@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/unixsysdev/serena-cli-go/internal/config"
+	"github.com/unixsysdev/serena-cli-go/internal/orchestrator"
+)
+
+// redactionGroup totals the secrets one detector replaced from one source.
+type redactionGroup struct {
+	Detector string
+	Source   string
+	Count    int
+	Last     time.Time
+}
+
+// handleRedactionsCommand implements /redactions: it reports which kinds of
+// secret were redacted in the current session, and from where.
+func handleRedactionsCommand(args []string, orch *orchestrator.Orchestrator, cfg *config.Config, sessions *SessionState) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: /redactions")
+	}
+	if err := sessions.SaveFromOrch(orch); err != nil {
+		return err
+	}
+	if !cfg.Redaction.Enabled {
+		fmt.Println("Redaction is disabled (redaction.enabled in serena-cli.yaml).")
+	}
+
+	index := make(map[[2]string]*redactionGroup)
+	var groups []*redactionGroup
+	total := 0
+	for _, r := range sessions.Redactions() {
+		key := [2]string{r.Detector, r.Source}
+		group := index[key]
+		if group == nil {
+			group = &redactionGroup{Detector: r.Detector, Source: r.Source}
+			index[key] = group
+			groups = append(groups, group)
+		}
+		group.Count += r.Count
+		if r.Time.After(group.Last) {
+			group.Last = r.Time
+		}
+		total += r.Count
+	}
+	if total == 0 {
+		fmt.Println("No secrets were redacted in this session.")
+		return nil
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Detector != groups[j].Detector {
+			return groups[i].Detector < groups[j].Detector
+		}
+		return groups[i].Source < groups[j].Source
+	})
+
+	fmt.Printf("Redacted %d secret(s) in session %s:\n", total, sessions.Current())
+	for _, group := range groups {
+		fmt.Printf("  %-18s %4d  %s (last %s)\n", group.Detector, group.Count, group.Source, group.Last.Local().Format(archiveTimeLayout))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/unixsysdev/serena-cli-go/internal/orchestrator"
+)
+
+// userTurn is a user message in the conversation.
+type userTurn struct {
+	index  int
+	prompt string
+}
+
+func userTurns(messages []openai.ChatCompletionMessage) []userTurn {
+	var turns []userTurn
+	for i, msg := range messages {
+		if i == 0 || msg.Role != openai.ChatMessageRoleUser {
+			continue
+		}
+		turns = append(turns, userTurn{index: i, prompt: orchestrator.UserRequest(msg.Content)})
+	}
+	return turns
+}
+
+// handleRewindCommand implements /rewind [n [prompt]]. Without arguments it
+// lists user turns; with n it drops that turn and everything after it, saving
+// the dropped messages to the archive, then either sends the new prompt or
+// offers the original one for editing.
+func handleRewindCommand(line string, args []string, orch *orchestrator.Orchestrator, sessions *SessionState) (commandResult, error) {
+	messages := orch.Messages()
+	turns := userTurns(messages)
+	if len(turns) == 0 {
+		return commandResult{}, fmt.Errorf("no turns to rewind to")
+	}
+
+	if len(args) == 0 {
+		fmt.Println("Turns:")
+		for i, turn := range turns {
+			fmt.Printf("%4d  %s\n", i+1, truncateLine(singleLine(turn.prompt), maxToolPreview))
+		}
+		fmt.Println("Use /rewind <n> to edit and resend a turn, or /rewind <n> <new prompt> to replace it.")
+		return commandResult{}, nil
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 || n > len(turns) {
+		return commandResult{}, fmt.Errorf("turn out of range: %s (1-%d)", args[0], len(turns))
+	}
+	turn := turns[n-1]
+
+	kept := trimToCompleteTurn(messages[:turn.index])
+	tail := messages[len(kept):]
+	if _, err := sessions.ArchiveMessages(fmt.Sprintf("Rewind to turn %d", n), len(kept), tail); err != nil {
+		return commandResult{}, err
+	}
+	orch.ReplaceMessages(kept)
+	forgetCheckpointsAfter(sessions, len(kept))
+	if err := sessions.SaveFromOrch(orch); err != nil {
+		return commandResult{}, err
+	}
+	fmt.Printf("Rewound to before turn %d; %d message(s) moved to the archive.\n", n, len(tail))
+
+	replacement := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(line, "/rewind")), args[0]))
+	if replacement != "" {
+		return commandResult{Submit: replacement}, nil
+	}
+	sessions.SetDraft(turn.prompt)
+	fmt.Println("The original prompt is ready to edit; press Enter to resend it.")
+	return commandResult{}, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/unixsysdev/serena-cli-go/internal/config"
+	"github.com/unixsysdev/serena-cli-go/internal/orchestrator"
+	"github.com/unixsysdev/serena-cli-go/internal/search"
+	"github.com/unixsysdev/serena-cli-go/internal/session"
+)
+
+const (
+	defaultSearchResults = 10
+	searchUsage          = "serena search [-n count] <query>"
+)
+
+// handleSearchCommand implements "/search [-n count] <query>" over every
+// session of the project.
+func handleSearchCommand(args []string, orch *orchestrator.Orchestrator, sessions *SessionState) error {
+	limit := defaultSearchResults
+	if len(args) >= 2 && args[0] == "-n" {
+		value, err := strconv.Atoi(args[1])
+		if err != nil || value <= 0 {
+			return fmt.Errorf("usage: /search [-n count] <query>")
+		}
+		limit = value
+		args = args[2:]
+	}
+	query := strings.Join(args, " ")
+	if strings.TrimSpace(query) == "" {
+		return fmt.Errorf("usage: /search [-n count] <query>")
+	}
+	if err := sessions.SaveFromOrch(orch); err != nil {
+		return err
+	}
+	hits, err := searchSessions(sessions.store, query, limit)
+	if err != nil {
+		return err
+	}
+	printSearchHits(hits, sessions.Current())
+	return nil
+}
+
+func searchSessions(store *session.Store, query string, limit int) ([]search.Hit, error) {
+	index, err := search.CachedIndex(store)
+	if err != nil {
+		return nil, err
+	}
+	return index.Search(query, limit), nil
+}
+
+func printSearchHits(hits []search.Hit, current string) {
+	if len(hits) == 0 {
+		fmt.Println("No matches.")
+		return
+	}
+	color := useColor()
+	for i, hit := range hits {
+		ref := searchHitRef(hit)
+		if hit.Session == current {
+			ref += " *"
+		}
+		if color {
+			ref = colorBold + ref + colorReset
+		}
+		fmt.Printf("%2d. %s %s\n", i+1, ref, formatScore(hit.Score, color))
+		fmt.Printf("    %s\n", hit.Snippet)
+	}
+}
+
+func formatScore(score float64, color bool) string {
+	text := fmt.Sprintf("(%.2f)", score)
+	if color {
+		return colorGray + text + colorReset
+	}
+	return text
+}
+
+// searchHitRef names where a hit came from, e.g. "default turn 3 assistant"
+// or "default archive (epoch 2: Compaction) user".
+func searchHitRef(hit search.Hit) string {
+	if hit.Source == search.SourceArchive {
+		ref := fmt.Sprintf("%s archive (%s)", hit.Session, hit.Label)
+		if hit.Role != "" {
+			ref += " " + hit.Role
+		}
+		return ref
+	}
+	if hit.Turn == 0 {
+		return fmt.Sprintf("%s %s", hit.Session, hit.Role)
+	}
+	return fmt.Sprintf("%s turn %d %s", hit.Session, hit.Turn, hit.Role)
+}
+
+// formatSearchResults renders hits for the session_search tool.
+func formatSearchResults(hits []search.Hit) string {
+	if len(hits) == 0 {
+		return "No matches found in project sessions."
+	}
+	var b strings.Builder
+	for i, hit := range hits {
+		fmt.Fprintf(&b, "%d. [%s] %s\n", i+1, searchHitRef(hit), hit.Snippet)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func runSearch(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	limit := flags.Int("n", defaultSearchResults, "Maximum number of results")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	query := strings.Join(flags.Args(), " ")
+	if strings.TrimSpace(query) == "" || *limit <= 0 {
+		return fmt.Errorf("usage: %s", searchUsage)
+	}
+	store, err := openSessionStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	hits, err := searchSessions(store, query, *limit)
+	if err != nil {
+		return err
+	}
+	printSearchHits(hits, "")
+	return nil
+}
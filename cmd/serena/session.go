@@ -1,12 +1,12 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -15,6 +15,7 @@ import (
 	"github.com/unixsysdev/serena-cli-go/internal/config"
 	"github.com/unixsysdev/serena-cli-go/internal/orchestrator"
 	"github.com/unixsysdev/serena-cli-go/internal/session"
+	"github.com/unixsysdev/serena-cli-go/internal/workspace"
 )
 
 const (
@@ -28,6 +29,10 @@ type SessionState struct {
 	name          string
 	baseDir       string
 	loadedSummary bool
+	history       *historyStore
+	draft         string
+	// repo holds the project's checkpoint refs; nil outside a git repository.
+	repo *workspace.Repo
 }
 
 func initSessionState(cfg *config.Config, orch *orchestrator.Orchestrator) (*SessionState, error) {
@@ -36,7 +41,12 @@ func initSessionState(cfg *config.Config, orch *orchestrator.Orchestrator) (*Ses
 		return nil, err
 	}
 
-	store, err := session.NewStore(baseDir)
+	store, err := openProjectStore(cfg, baseDir, cfg.SessionBackend)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := loadHistory(baseDir)
 	if err != nil {
 		return nil, err
 	}
@@ -44,6 +54,10 @@ func initSessionState(cfg *config.Config, orch *orchestrator.Orchestrator) (*Ses
 	state := &SessionState{
 		store:   store,
 		baseDir: baseDir,
+		history: history,
+	}
+	if root, err := projectRoot(cfg); err == nil {
+		state.repo, _ = workspace.Open(root)
 	}
 
 	if err := state.loadOrCreate(defaultSessionName, orch); err != nil {
@@ -60,14 +74,89 @@ func (s *SessionState) Current() string {
 	return s.name
 }
 
+// SetDraft stores text to pre-fill the next input prompt.
+func (s *SessionState) SetDraft(text string) {
+	s.draft = text
+}
+
+// TakeDraft returns and clears the pending input draft.
+func (s *SessionState) TakeDraft() string {
+	draft := s.draft
+	s.draft = ""
+	return draft
+}
+
+// Close releases the session store.
+func (s *SessionState) Close() error {
+	return s.store.Close()
+}
+
+// History returns the persistent input history for the project.
+func (s *SessionState) History() *historyStore {
+	return s.history
+}
+
 func (s *SessionState) SaveFromOrch(orch *orchestrator.Orchestrator) error {
 	if s.data == nil {
 		return nil
 	}
 	s.data.Model = orch.Model()
 	s.data.SystemPrompt = orch.SystemPrompt()
-	s.data.Messages = session.FromOpenAIMessages(orch.Messages())
-	return s.store.Save(s.data)
+	for _, r := range orch.TakeRedactions() {
+		s.data.Redactions = append(s.data.Redactions, session.Redaction{
+			Time:     r.Time,
+			Detector: r.Detector,
+			Source:   r.Source,
+			Count:    r.Count,
+		})
+	}
+	messages := session.FromOpenAIMessages(orch.Messages())
+	session.CarryMetadata(messages, s.data.Messages, time.Now(), orch.LastTurnModel())
+	s.data.Messages = messages
+	if err := s.store.Save(s.data); err != nil {
+		if errors.Is(err, session.ErrConflict) {
+			return fmt.Errorf("%w\nYour conversation was not saved. Use /session fork <name> to keep it as a new session, or /session reload to load the other version and discard it.", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// Reload replaces the conversation with the stored copy of the current
+// session, discarding unsaved messages.
+func (s *SessionState) Reload(orch *orchestrator.Orchestrator) error {
+	if err := s.loadOrCreate(s.name, orch); err != nil {
+		return err
+	}
+	fmt.Printf("Reloaded session %s (%d messages, version %d).\n", s.name, len(s.data.Messages), s.data.Version)
+	return nil
+}
+
+// Contexts returns a copy of the context sources attached to the session.
+func (s *SessionState) Contexts() []session.ContextSource {
+	if s.data == nil {
+		return nil
+	}
+	return append([]session.ContextSource(nil), s.data.Context...)
+}
+
+// SetContexts replaces the session's context sources, applies them to orch
+// and saves the session.
+func (s *SessionState) SetContexts(sources []session.ContextSource, orch *orchestrator.Orchestrator) error {
+	if s.data == nil {
+		return fmt.Errorf("no active session")
+	}
+	s.data.Context = redactContexts(sources, orch)
+	orch.SetContexts(contextAttachments(s.data.Context))
+	return s.SaveFromOrch(orch)
+}
+
+// Redactions returns the secrets redacted in the current session so far.
+func (s *SessionState) Redactions() []session.Redaction {
+	if s.data == nil {
+		return nil
+	}
+	return append([]session.Redaction(nil), s.data.Redactions...)
 }
 
 func (s *SessionState) loadOrCreate(name string, orch *orchestrator.Orchestrator) error {
@@ -81,9 +170,25 @@ func (s *SessionState) loadOrCreate(name string, orch *orchestrator.Orchestrator
 			Name:         sessionName,
 			Model:        orch.Model(),
 			SystemPrompt: orch.SystemPrompt(),
-			ArchiveFile:  sessionName + "_archive.txt",
-			SummaryFile:  sessionName + "_summary.md",
+			ArchiveFile:  session.ArchiveFileName(sessionName),
+			SummaryFile:  session.SummaryFileName(sessionName),
+		}
+		if err := s.store.Save(data); err != nil {
+			if !errors.Is(err, session.ErrConflict) {
+				return err
+			}
+			// Another process created it first; use theirs.
+			if data, err = s.store.Load(sessionName); err != nil {
+				return err
+			}
 		}
+	}
+
+	archiveMigrated, err := s.store.MigrateTextArchive(data)
+	if err != nil {
+		return err
+	}
+	if migrateContextMessages(data) || archiveMigrated {
 		if err := s.store.Save(data); err != nil {
 			return err
 		}
@@ -92,6 +197,14 @@ func (s *SessionState) loadOrCreate(name string, orch *orchestrator.Orchestrator
 	s.name = sessionName
 	s.data = data
 	s.loadedSummary = false
+	if tracker := orch.ChangeTracker(); tracker != nil {
+		tracker.ResetSession()
+	}
+	// Redactions of the previous session's unsaved messages are dropped
+	// with them.
+	orch.TakeRedactions()
+	data.Context = redactContexts(data.Context, orch)
+	orch.SetContexts(contextAttachments(data.Context))
 
 	if s.data.Model != "" && s.data.Model != orch.Model() {
 		orch.SetModel(s.data.Model)
@@ -124,7 +237,157 @@ func (s *SessionState) Delete(name string) error {
 	if sessionName == s.name {
 		return fmt.Errorf("cannot delete active session")
 	}
-	return s.store.Delete(sessionName)
+	if err := s.store.Delete(sessionName); err != nil {
+		return err
+	}
+	if s.repo != nil {
+		return s.repo.DeleteRef(checkpointRef(sessionName))
+	}
+	return nil
+}
+
+// Rename renames a session. Renaming the active session saves it first and
+// keeps it active under the new name.
+func (s *SessionState) Rename(oldName string, newName string, orch *orchestrator.Orchestrator) error {
+	oldName, newName = sanitizeSessionName(oldName), sanitizeSessionName(newName)
+	if newName == "" {
+		return fmt.Errorf("session name required")
+	}
+	active := oldName == s.name
+	if active {
+		if err := s.SaveFromOrch(orch); err != nil {
+			return err
+		}
+	}
+	data, err := s.store.Rename(oldName, newName)
+	if err != nil {
+		return err
+	}
+	if active {
+		s.name = newName
+		s.data = data
+	}
+	if s.repo != nil {
+		return s.repo.RenameRef(checkpointRef(oldName), checkpointRef(newName))
+	}
+	return nil
+}
+
+// Update applies fn to the named session's metadata and saves it. The active
+// session is updated in place.
+func (s *SessionState) Update(name string, orch *orchestrator.Orchestrator, fn func(data *session.SessionData)) error {
+	name = sanitizeSessionName(name)
+	if name == s.name && s.data != nil {
+		fn(s.data)
+		return s.SaveFromOrch(orch)
+	}
+	data, err := s.store.Load(name)
+	if err != nil {
+		return err
+	}
+	fn(data)
+	return s.store.Save(data)
+}
+
+// Fork copies the current session, its context, summary and archive into a
+// new session and switches to it. When keep is non-negative only the first
+// keep messages are copied, cut back to the last complete turn.
+func (s *SessionState) Fork(name string, keep int, orch *orchestrator.Orchestrator) error {
+	if s.data == nil {
+		return fmt.Errorf("no active session")
+	}
+	forkName := sanitizeSessionName(name)
+	if _, err := s.store.Load(forkName); err == nil {
+		return fmt.Errorf("session %s already exists", forkName)
+	}
+	// A conflicting save is expected here: forking is how the user keeps a
+	// conversation that another process overwrote.
+	if err := s.SaveFromOrch(orch); err != nil && !errors.Is(err, session.ErrConflict) {
+		return err
+	}
+
+	messages := orch.Messages()
+	if keep >= 0 {
+		if keep > len(messages)-1 {
+			return fmt.Errorf("message index out of range: %d (session has %d messages)", keep, len(messages)-1)
+		}
+		messages = trimToCompleteTurn(messages[:keep+1])
+	}
+
+	fork := &session.SessionData{
+		Name:         forkName,
+		Model:        s.data.Model,
+		SystemPrompt: s.data.SystemPrompt,
+		Messages:     session.FromOpenAIMessages(messages),
+		ArchiveFile:  session.ArchiveFileName(forkName),
+		SummaryFile:  session.SummaryFileName(forkName),
+		Context:      append([]session.ContextSource(nil), s.data.Context...),
+		Parent:       s.name,
+		ForkIndex:    len(messages) - 1,
+		Description:  s.data.Description,
+		Tags:         append([]string(nil), s.data.Tags...),
+		Redactions:   append([]session.Redaction(nil), s.data.Redactions...),
+	}
+	for _, checkpoint := range s.data.Checkpoints {
+		if checkpoint.MessageCount < len(messages) {
+			fork.Checkpoints = append(fork.Checkpoints, checkpoint)
+		}
+	}
+	for _, pair := range [][2]string{
+		{s.data.ArchiveFile, fork.ArchiveFile},
+		{s.data.SummaryFile, fork.SummaryFile},
+	} {
+		if err := s.copySessionFile(pair[0], pair[1]); err != nil {
+			return err
+		}
+	}
+	if err := s.store.Save(fork); err != nil {
+		return err
+	}
+	return s.loadOrCreate(forkName, orch)
+}
+
+// Lineage returns the chain of parent sessions for data, oldest first.
+func (s *SessionState) Lineage(data *session.SessionData) []string {
+	var chain []string
+	seen := map[string]bool{data.Name: true}
+	for parent := data.Parent; parent != "" && !seen[parent]; {
+		seen[parent] = true
+		chain = append([]string{parent}, chain...)
+		parentData, err := s.store.Load(parent)
+		if err != nil {
+			break
+		}
+		parent = parentData.Parent
+	}
+	return chain
+}
+
+// copySessionFile copies an archive or summary file through the store, so
+// the copy is encrypted like the original and written atomically.
+func (s *SessionState) copySessionFile(src string, dst string) error {
+	if src == "" {
+		return nil
+	}
+	content, err := s.store.ReadFile(src)
+	if err != nil || content == "" {
+		return err
+	}
+	return s.store.WriteFile(dst, content)
+}
+
+// trimToCompleteTurn drops trailing tool calls and tool results so the
+// conversation never ends with an unanswered tool call.
+func trimToCompleteTurn(messages []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	for len(messages) > 1 {
+		last := messages[len(messages)-1]
+		if last.Role == openai.ChatMessageRoleTool || len(last.ToolCalls) > 0 {
+			messages = messages[:len(messages)-1]
+			continue
+		}
+		break
+	}
+	return messages
 }
 
 func (s *SessionState) ArchivePath() string {
@@ -141,54 +404,39 @@ func (s *SessionState) SummaryPath() string {
 	return filepath.Join(s.baseDir, s.data.SummaryFile)
 }
 
-func (s *SessionState) AppendArchive(content string) error {
-	if content == "" {
-		return nil
-	}
-	path := s.ArchivePath()
-	if path == "" {
-		return nil
-	}
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return err
-	}
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
+// ArchiveMessages appends messages taken from conversation position index to
+// the session archive as a new epoch, keeping their timestamps and models,
+// and returns the epoch number.
+func (s *SessionState) ArchiveMessages(reason string, index int, messages []openai.ChatCompletionMessage) (int, error) {
+	if s.data == nil || len(messages) == 0 {
+		return 0, nil
+	}
+	stored := session.FromOpenAIMessages(messages)
+	session.CarryMetadata(stored, s.data.Messages, time.Now(), "")
+	return s.store.AppendArchive(s.data.ArchiveFile, reason, index, stored)
+}
 
-	header := fmt.Sprintf("\n---\nCompaction at %s\n---\n", time.Now().Format(time.RFC3339))
-	if _, err := f.WriteString(header + content + "\n"); err != nil {
-		return err
+// ArchiveEpochs returns the archived epochs of the current session.
+func (s *SessionState) ArchiveEpochs() ([]session.Epoch, error) {
+	if s.data == nil {
+		return nil, nil
 	}
-	return nil
+	return s.store.ReadArchive(s.data.ArchiveFile)
 }
 
 func (s *SessionState) WriteSummary(content string) error {
-	path := s.SummaryPath()
-	if path == "" {
+	if s.data == nil || s.data.SummaryFile == "" {
 		return nil
 	}
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return err
-	}
-	return os.WriteFile(path, []byte(content), 0o600)
+	return s.store.WriteFile(s.data.SummaryFile, content)
 }
 
 func (s *SessionState) ReadSummary() (string, error) {
-	path := s.SummaryPath()
-	if path == "" {
+	if s.data == nil {
 		return "", nil
 	}
-	data, err := os.ReadFile(path)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return "", nil
-		}
-		return "", err
-	}
-	return strings.TrimSpace(string(data)), nil
+	summary, err := s.store.ReadFile(s.data.SummaryFile)
+	return strings.TrimSpace(summary), err
 }
 
 func (s *SessionState) maybeShowSessionSummary(ctx context.Context, orch *orchestrator.Orchestrator) error {
@@ -261,54 +509,9 @@ func (s *SessionState) Summary(ctx context.Context, orch *orchestrator.Orchestra
 	return summary, nil
 }
 
-func (s *SessionState) SearchArchive(query string, maxResults int) (string, error) {
-	path := s.ArchivePath()
-	if path == "" {
-		return "No archive file for this session.", nil
-	}
-	file, err := os.Open(path)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return "No archive content yet.", nil
-		}
-		return "", err
-	}
-	defer file.Close()
-
-	queryLower := strings.ToLower(query)
-	if queryLower == "" {
-		return "Query is empty.", nil
-	}
-	if maxResults <= 0 {
-		maxResults = 10
-	}
-
-	var matches []string
-	scanner := bufio.NewScanner(file)
-	lineNo := 0
-	for scanner.Scan() {
-		lineNo++
-		line := scanner.Text()
-		if strings.Contains(strings.ToLower(line), queryLower) {
-			matches = append(matches, fmt.Sprintf("%d: %s", lineNo, line))
-			if len(matches) >= maxResults {
-				break
-			}
-		}
-	}
-	if err := scanner.Err(); err != nil {
-		return "", err
-	}
-
-	if len(matches) == 0 {
-		return "No matches found in session archive.", nil
-	}
-	return strings.Join(matches, "\n"), nil
-}
-
 func handleSessionCommand(args []string, orch *orchestrator.Orchestrator, sessions *SessionState, ui *ConsoleUI) error {
-	if len(args) == 0 || args[0] == "list" {
-		return printSessions(sessions)
+	if len(args) == 0 {
+		return printSessions(sessions, nil)
 	}
 
 	switch args[0] {
@@ -330,52 +533,284 @@ func handleSessionCommand(args []string, orch *orchestrator.Orchestrator, sessio
 		}
 		ui.StopSpinner()
 		return sessions.SaveFromOrch(orch)
+	case "list":
+		return printSessions(sessions, args[1:])
 	case "delete":
 		if len(args) < 2 {
 			return fmt.Errorf("usage: /session delete <name>")
 		}
 		return sessions.Delete(args[1])
+	case "rename":
+		oldName, newName := sessions.Current(), ""
+		switch len(args) {
+		case 2:
+			newName = args[1]
+		case 3:
+			oldName, newName = args[1], args[2]
+		default:
+			return fmt.Errorf("usage: /session rename [old] <new>")
+		}
+		if err := sessions.Rename(oldName, newName, orch); err != nil {
+			return err
+		}
+		fmt.Printf("Renamed %s to %s.\n", sanitizeSessionName(oldName), sanitizeSessionName(newName))
+		return nil
+	case "tag", "untag":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: /session %s <tag>...", args[0])
+		}
+		remove := args[0] == "untag"
+		return sessions.Update(sessions.Current(), orch, func(data *session.SessionData) {
+			data.Tags = updateTags(data.Tags, args[1:], remove)
+			fmt.Printf("Tags: %s\n", formatTags(data.Tags))
+		})
+	case "describe":
+		description := strings.TrimSpace(strings.Join(args[1:], " "))
+		return sessions.Update(sessions.Current(), orch, func(data *session.SessionData) {
+			data.Description = description
+		})
+	case "pin", "unpin":
+		name := sessions.Current()
+		if len(args) > 1 {
+			name = args[1]
+		}
+		pinned := args[0] == "pin"
+		if err := sessions.Update(name, orch, func(data *session.SessionData) { data.Pinned = pinned }); err != nil {
+			return err
+		}
+		if pinned {
+			fmt.Printf("Pinned %s; it is listed first and never garbage collected.\n", sanitizeSessionName(name))
+		} else {
+			fmt.Printf("Unpinned %s.\n", sanitizeSessionName(name))
+		}
+		return nil
+	case "fork":
+		return handleSessionFork(args[1:], orch, sessions, ui)
 	case "info":
 		return printSessionInfo(sessions)
+	case "export":
+		return handleSessionExport(args[1:], orch, sessions)
+	case "reload":
+		if err := sessions.Reload(orch); err != nil {
+			return err
+		}
+		ui.StopSpinner()
+		return nil
+	default:
+		return fmt.Errorf("unknown session command (use %s)", strings.Join(sessionSubcommands, "/"))
+	}
+}
+
+func handleSessionFork(args []string, orch *orchestrator.Orchestrator, sessions *SessionState, ui *ConsoleUI) error {
+	usage := fmt.Errorf("usage: /session fork <name> [at <message-index>]")
+	if len(args) == 0 {
+		return usage
+	}
+	keep := -1
+	rest := args[1:]
+	if len(rest) > 0 && rest[0] == "at" {
+		rest = rest[1:]
+	}
+	switch len(rest) {
+	case 0:
+	case 1:
+		value, err := strconv.Atoi(rest[0])
+		if err != nil || value < 0 {
+			return usage
+		}
+		keep = value
 	default:
-		return fmt.Errorf("unknown session command (use list/new/switch/delete/info)")
+		return usage
+	}
+
+	parent := sessions.Current()
+	if err := sessions.Fork(args[0], keep, orch); err != nil {
+		return err
 	}
+	ui.StopSpinner()
+	fmt.Printf("Forked %s into %s at message %d; now on %s.\n", parent, sessions.Current(), sessions.data.ForkIndex, sessions.Current())
+	return nil
 }
 
-func printSessions(sessions *SessionState) error {
+const sessionListUsage = "/session list [--tag tag] [--pinned] [--sort updated|created|name|size]"
+
+// printSessions lists sessions, pinned first, optionally filtered by tag and
+// sorted by the given key.
+func printSessions(sessions *SessionState, args []string) error {
+	var tags []string
+	pinnedOnly := false
+	sortKey := "updated"
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--pinned":
+			pinnedOnly = true
+		case (args[i] == "--tag" || args[i] == "--sort") && i+1 < len(args):
+			if args[i] == "--tag" {
+				tags = append(tags, args[i+1])
+			} else {
+				sortKey = args[i+1]
+			}
+			i++
+		case strings.HasPrefix(args[i], "#"):
+			tags = append(tags, args[i])
+		default:
+			return fmt.Errorf("usage: %s", sessionListUsage)
+		}
+	}
+
 	all, err := sessions.store.List()
 	if err != nil {
 		return err
 	}
-	if len(all) == 0 {
+	var matched []session.SessionData
+	for _, entry := range all {
+		if pinnedOnly && !entry.Pinned {
+			continue
+		}
+		ok := true
+		for _, tag := range tags {
+			ok = ok && entry.HasTag(tag)
+		}
+		if ok {
+			matched = append(matched, entry)
+		}
+	}
+
+	sizes := make(map[string]int64)
+	if sortKey == "size" {
+		for i := range matched {
+			usage, err := sessions.store.DiskUsage(&matched[i])
+			if err != nil {
+				return err
+			}
+			sizes[matched[i].Name] = usage.Total()
+		}
+	}
+	var less func(a, b session.SessionData) bool
+	switch sortKey {
+	case "updated":
+		less = func(a, b session.SessionData) bool { return a.UpdatedAt.After(b.UpdatedAt) }
+	case "created":
+		less = func(a, b session.SessionData) bool { return a.CreatedAt.After(b.CreatedAt) }
+	case "name":
+		less = func(a, b session.SessionData) bool { return a.Name < b.Name }
+	case "size":
+		less = func(a, b session.SessionData) bool { return sizes[a.Name] > sizes[b.Name] }
+	default:
+		return fmt.Errorf("usage: %s", sessionListUsage)
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].Pinned != matched[j].Pinned {
+			return matched[i].Pinned
+		}
+		return less(matched[i], matched[j])
+	})
+
+	if len(matched) == 0 {
 		fmt.Println("No sessions found.")
 		return nil
 	}
 	fmt.Println("Sessions:")
-	for _, entry := range all {
+	for _, entry := range matched {
 		marker := " "
 		if entry.Name == sessions.name {
 			marker = "*"
 		}
-		fmt.Printf("%s %s (updated %s)\n", marker, entry.Name, entry.UpdatedAt.Format(time.RFC822))
+		name := entry.Name
+		if entry.Pinned {
+			name += " [pinned]"
+		}
+		details := "updated " + entry.UpdatedAt.Format(time.RFC822)
+		if sortKey == "created" {
+			details = "created " + entry.CreatedAt.Format(time.RFC822)
+		}
+		if sortKey == "size" {
+			details += ", " + formatBytes(sizes[entry.Name])
+		}
+		if entry.Parent != "" {
+			details += fmt.Sprintf(", forked from %s at message %d", entry.Parent, entry.ForkIndex)
+		}
+		fmt.Printf("%s %s (%s)%s\n", marker, name, details, formatTagSuffix(entry.Tags))
+		if entry.Description != "" {
+			fmt.Printf("    %s\n", truncateLine(singleLine(entry.Description), maxToolPreview))
+		}
 	}
 	return nil
 }
 
+// updateTags adds or removes tags.
+func updateTags(tags []string, changes []string, remove bool) []string {
+	if !remove {
+		return session.NormalizeTags(append(append([]string(nil), tags...), changes...))
+	}
+	drop := make(map[string]bool)
+	for _, tag := range session.NormalizeTags(changes) {
+		drop[tag] = true
+	}
+	var kept []string
+	for _, tag := range tags {
+		if !drop[tag] {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
+
+func formatTags(tags []string) string {
+	if len(tags) == 0 {
+		return "(none)"
+	}
+	return "#" + strings.Join(tags, " #")
+}
+
+func formatTagSuffix(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return " " + formatTags(tags)
+}
+
 func printSessionInfo(sessions *SessionState) error {
 	if sessions.data == nil {
 		fmt.Println("No active session.")
 		return nil
 	}
 	fmt.Printf("Session: %s\n", sessions.data.Name)
+	if sessions.data.Description != "" {
+		fmt.Printf("Description: %s\n", sessions.data.Description)
+	}
+	if len(sessions.data.Tags) > 0 {
+		fmt.Printf("Tags: %s\n", formatTags(sessions.data.Tags))
+	}
+	if sessions.data.Pinned {
+		fmt.Println("Pinned: yes")
+	}
 	fmt.Printf("Model: %s\n", sessions.data.Model)
 	fmt.Printf("Updated: %s\n", sessions.data.UpdatedAt.Format(time.RFC822))
+	fmt.Printf("Messages: %d\n", len(sessions.data.Messages))
+	fmt.Printf("Version: %d\n", sessions.data.Version)
+	if lineage := sessions.Lineage(sessions.data); len(lineage) > 0 {
+		fmt.Printf("Lineage: %s > %s (forked at message %d)\n", strings.Join(lineage, " > "), sessions.data.Name, sessions.data.ForkIndex)
+	}
 	if sessions.data.ArchiveFile != "" {
 		fmt.Printf("Archive: %s\n", sessions.ArchivePath())
 	}
 	if sessions.data.SummaryFile != "" {
 		fmt.Printf("Summary: %s\n", sessions.SummaryPath())
 	}
+	if usage, err := sessions.store.DiskUsage(sessions.data); err == nil {
+		fmt.Printf("Size: %s (session %s, archive %s, summary %s)\n", formatBytes(usage.Total()),
+			formatBytes(usage.Session), formatBytes(usage.Archive), formatBytes(usage.Summary))
+	}
+	if len(sessions.data.Checkpoints) > 0 {
+		fmt.Printf("Checkpoints: %d\n", len(sessions.data.Checkpoints))
+	}
+	if len(sessions.data.Commits) > 0 {
+		fmt.Println("Commits:")
+		for _, commit := range sessions.data.Commits {
+			fmt.Printf("  %s %s %s (%d files, %s)\n", shortHash(commit.Hash), commit.Branch, commit.Subject, commit.Files, commit.CreatedAt.Format(time.RFC822))
+		}
+	}
 	return nil
 }
 
@@ -405,18 +840,19 @@ func compactSession(ctx context.Context, orch *orchestrator.Orchestrator, sessio
 		return err
 	}
 
-	if err := sessions.AppendArchive(transcript); err != nil {
+	epoch, err := sessions.ArchiveMessages("Compaction", 1, older)
+	if err != nil {
 		return err
 	}
 	if err := sessions.WriteSummary(summary); err != nil {
 		return err
 	}
 
-	archiveHint := sessions.ArchivePath()
 	summaryMsg := openai.ChatCompletionMessage{
 		Role: openai.ChatMessageRoleAssistant,
-		Content: fmt.Sprintf("<summary>\n%s\n</summary>\n<archive>\n%s\nUse session_search to look up details.\n</archive>",
-			strings.TrimSpace(summary), archiveHint),
+		Content: fmt.Sprintf("<summary>\n%s\n</summary>\n<archive>\nEarlier messages are archived as epoch %d. "+
+			"Use session_recall to read them or session_search to look up details.\n</archive>",
+			strings.TrimSpace(summary), epoch),
 	}
 
 	newMessages := []openai.ChatCompletionMessage{
@@ -425,6 +861,7 @@ func compactSession(ctx context.Context, orch *orchestrator.Orchestrator, sessio
 	}
 	newMessages = append(newMessages, recent...)
 	orch.ReplaceMessages(newMessages)
+	shiftCheckpoints(sessions, len(messages)-keep, 1)
 
 	if err := sessions.SaveFromOrch(orch); err != nil {
 		return err
@@ -470,18 +907,19 @@ func registerSessionTools(orch *orchestrator.Orchestrator, sessions *SessionStat
 	searchTool := openai.Tool{
 		Type: openai.ToolTypeFunction,
 		Function: &openai.FunctionDefinition{
-			Name:        "session_search",
-			Description: "Searches the compacted session archive for a query and returns matching lines.",
+			Name: "session_search",
+			Description: "Searches the messages and archives of every session in this project, " +
+				"ranked by relevance, and returns snippets with session and turn references.",
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"query": map[string]interface{}{
 						"type":        "string",
-						"description": "Substring to search for in the session archive.",
+						"description": "Words to search for.",
 					},
 					"max_results": map[string]interface{}{
 						"type":        "integer",
-						"description": "Maximum number of matching lines to return.",
+						"description": "Maximum number of results to return.",
 					},
 				},
 				"required": []string{"query"},
@@ -491,24 +929,90 @@ func registerSessionTools(orch *orchestrator.Orchestrator, sessions *SessionStat
 
 	orch.AddLocalTool(searchTool, func(ctx context.Context, args map[string]interface{}) (string, error) {
 		query, _ := args["query"].(string)
-		maxResults := 10
-		if raw, ok := args["max_results"]; ok {
-			switch v := raw.(type) {
-			case float64:
-				maxResults = int(v)
-			case int:
-				maxResults = v
-			case string:
-				if parsed, err := strconv.Atoi(v); err == nil {
-					maxResults = parsed
-				}
-			}
+		if strings.TrimSpace(query) == "" {
+			return "Query is empty.", nil
+		}
+		maxResults := intArgument(args, "max_results", defaultSearchResults)
+		hits, err := searchSessions(sessions.store, query, maxResults)
+		if err != nil {
+			return "", err
 		}
-		return sessions.SearchArchive(query, maxResults)
+		return formatSearchResults(hits), nil
+	})
+
+	recallTool := openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name: "session_recall",
+			Description: "Reads messages archived from this session by compaction or rewind, " +
+				"optionally filtered by epoch, role, tool name or text.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"epoch": map[string]interface{}{
+						"type":        "integer",
+						"description": "Archive epoch number; omit to read every epoch.",
+					},
+					"role": map[string]interface{}{
+						"type":        "string",
+						"description": "Only messages with this role (user, assistant or tool).",
+					},
+					"tool": map[string]interface{}{
+						"type":        "string",
+						"description": "Only calls to, and results of, this tool.",
+					},
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "Only messages containing this text (case-insensitive).",
+					},
+					"max_messages": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum number of messages to return.",
+					},
+				},
+			},
+		},
+	}
+
+	orch.AddLocalTool(recallTool, func(ctx context.Context, args map[string]interface{}) (string, error) {
+		epochs, err := sessions.ArchiveEpochs()
+		if err != nil {
+			return "", err
+		}
+		q := recallQuery{
+			epoch: intArgument(args, "epoch", 0),
+			limit: intArgument(args, "max_messages", defaultRecallCount),
+		}
+		q.role, _ = args["role"].(string)
+		q.tool, _ = args["tool"].(string)
+		q.text, _ = args["query"].(string)
+		q.text = strings.TrimSpace(q.text)
+		return recallArchive(epochs, q), nil
 	})
 }
 
-func sessionBaseDir(cfg *config.Config) (string, error) {
+// intArgument reads a positive integer tool argument, falling back to def.
+func intArgument(args map[string]interface{}, key string, def int) int {
+	value := def
+	switch v := args[key].(type) {
+	case float64:
+		value = int(v)
+	case int:
+		value = v
+	case string:
+		if parsed, err := strconv.Atoi(v); err == nil {
+			value = parsed
+		}
+	}
+	if value <= 0 {
+		return def
+	}
+	return value
+}
+
+// projectRoot returns the absolute project directory, defaulting to the
+// working directory when no project path is configured.
+func projectRoot(cfg *config.Config) (string, error) {
 	projectPath := cfg.Serena.ProjectPath
 	if projectPath == "" || projectPath == "." {
 		cwd, err := os.Getwd()
@@ -517,10 +1021,19 @@ func sessionBaseDir(cfg *config.Config) (string, error) {
 		}
 		projectPath = cwd
 	}
-	absPath, err := filepath.Abs(projectPath)
+	return filepath.Abs(projectPath)
+}
+
+func sessionBaseDir(cfg *config.Config) (string, error) {
+	absPath, err := projectRoot(cfg)
 	if err != nil {
 		return "", err
 	}
+	return projectSessionDir(absPath)
+}
+
+// projectSessionDir returns the session directory for an absolute project path.
+func projectSessionDir(absPath string) (string, error) {
 	projectName := sanitizeSessionName(filepath.Base(absPath))
 	home, err := os.UserHomeDir()
 	if err != nil {
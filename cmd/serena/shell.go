@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/unixsysdev/serena-cli-go/internal/config"
+	"github.com/unixsysdev/serena-cli-go/internal/orchestrator"
+)
+
+const (
+	shellHeadBytes = 8000
+	shellTailBytes = 12000
+	shellWaitDelay = 2 * time.Second
+)
+
+// handleShellEscape runs a local shell command for "!cmd" input. With "!!cmd"
+// the captured output and exit code are attached to the next chat turn.
+func handleShellEscape(ctx context.Context, text string, orch *orchestrator.Orchestrator, cfg *config.Config, tracker *cancelTracker) error {
+	attachOutput := strings.HasPrefix(text, "!!")
+	command := strings.TrimSpace(strings.TrimLeft(text, "!"))
+	if command == "" {
+		return fmt.Errorf("usage: !<command> to run it, !!<command> to also attach its output to your next message")
+	}
+
+	dir, err := projectRoot(cfg)
+	if err != nil {
+		return err
+	}
+
+	cmdCtx, cancel := context.WithCancel(ctx)
+	tracker.Set(cancel)
+	defer func() {
+		tracker.Clear()
+		cancel()
+	}()
+
+	capture := newCappedBuffer(shellHeadBytes, shellTailBytes)
+	cmd := shellCommand(cmdCtx, command)
+	cmd.Dir = dir
+	cmd.Stdout = io.MultiWriter(os.Stdout, capture)
+	cmd.Stderr = io.MultiWriter(os.Stderr, capture)
+	cmd.WaitDelay = shellWaitDelay
+
+	runErr := cmd.Run()
+	exitCode := 0
+	status := "exit status 0"
+	var exitErr *exec.ExitError
+	switch {
+	case runErr == nil:
+	case cmdCtx.Err() != nil:
+		exitCode = -1
+		status = "cancelled"
+		fmt.Println("Command cancelled.")
+	case errors.As(runErr, &exitErr):
+		exitCode = exitErr.ExitCode()
+		status = fmt.Sprintf("exit status %d", exitCode)
+	default:
+		return runErr
+	}
+	if exitCode != 0 && status != "cancelled" {
+		fmt.Printf("[%s]\n", status)
+	}
+
+	if !attachOutput {
+		return nil
+	}
+	output := strings.TrimRight(capture.String(), "\n")
+	if output == "" {
+		output = "(no output)"
+	}
+	orch.AttachNext(orchestrator.ContextAttachment{
+		Label:   fmt.Sprintf("$ %s (%s)", command, status),
+		Content: fmt.Sprintf("$ %s\n%s\n[%s]", command, output, status),
+	})
+	fmt.Printf("Output attached to your next message (%d bytes).\n", capture.Len())
+	return nil
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	return exec.CommandContext(ctx, shell, "-c", command)
+}
+
+// cappedBuffer keeps the first head and last tail bytes written to it so long
+// command output stays bounded.
+type cappedBuffer struct {
+	head      []byte
+	tail      []byte
+	headLimit int
+	tailLimit int
+	total     int
+}
+
+func newCappedBuffer(headLimit int, tailLimit int) *cappedBuffer {
+	return &cappedBuffer{headLimit: headLimit, tailLimit: tailLimit}
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.total += len(p)
+	data := p
+	if room := b.headLimit - len(b.head); room > 0 {
+		if room > len(data) {
+			room = len(data)
+		}
+		b.head = append(b.head, data[:room]...)
+		data = data[room:]
+	}
+	b.tail = append(b.tail, data...)
+	if len(b.tail) > 2*b.tailLimit {
+		b.tail = append([]byte(nil), b.tail[len(b.tail)-b.tailLimit:]...)
+	}
+	return len(p), nil
+}
+
+// Len returns the number of bytes written, including any dropped.
+func (b *cappedBuffer) Len() int {
+	return b.total
+}
+
+func (b *cappedBuffer) String() string {
+	tail := b.tail
+	if len(tail) > b.tailLimit {
+		tail = tail[len(tail)-b.tailLimit:]
+	}
+	dropped := b.total - len(b.head) - len(tail)
+	if dropped <= 0 {
+		return string(b.head) + string(tail)
+	}
+	return fmt.Sprintf("%s\n... [%d bytes truncated] ...\n%s", b.head, dropped, tail)
+}
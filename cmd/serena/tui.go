@@ -0,0 +1,1015 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/term"
+
+	"github.com/unixsysdev/serena-cli-go/internal/config"
+	"github.com/unixsysdev/serena-cli-go/internal/orchestrator"
+)
+
+const (
+	tuiMaxLines      = 5000
+	tuiToolPaneWidth = 44
+	tuiMinToolWidth  = 100
+	tuiRefresh       = 250 * time.Millisecond
+	tuiInputPrompt   = "> "
+)
+
+const (
+	ansiHideCursor    = "\x1b[?25l"
+	ansiShowCursor    = "\x1b[?25h"
+	ansiAltScreenOn   = "\x1b[?1049h"
+	ansiAltScreenOff  = "\x1b[?1049l"
+	ansiPasteOn       = "\x1b[?2004h"
+	ansiPasteOff      = "\x1b[?2004l"
+	ansiInverse       = "\x1b[7m"
+	ansiClearScreen   = "\x1b[2J"
+	ansiClearToEOL    = "\x1b[K"
+	bracketPasteStart = "\x1b[200~"
+	bracketPasteEnd   = "\x1b[201~"
+)
+
+type tuiKeyKind int
+
+const (
+	keyUnknown tuiKeyKind = iota
+	keyRune
+	keyPaste
+	keyEnter
+	keyBackspace
+	keyDelete
+	keyLeft
+	keyRight
+	keyUp
+	keyDown
+	keyHome
+	keyEnd
+	keyPageUp
+	keyPageDown
+	keyCtrlC
+	keyCtrlD
+	keyCtrlK
+	keyCtrlL
+	keyCtrlO
+	keyCtrlT
+	keyCtrlU
+	keyCtrlW
+	keyTab
+)
+
+type tuiKey struct {
+	kind tuiKeyKind
+	r    rune
+	text string
+}
+
+type tuiStatus struct {
+	model   string
+	session string
+	tokens  int
+}
+
+// tuiApp is the full-screen terminal UI. Stdout and stderr are redirected into
+// the conversation pane so existing command handlers render unchanged.
+type tuiApp struct {
+	ctx      context.Context
+	orch     *orchestrator.Orchestrator
+	cfg      *config.Config
+	ui       *ConsoleUI
+	sessions *SessionState
+	tracker  *cancelTracker
+	complete *completer
+	term     *os.File
+
+	mu       sync.Mutex
+	lines    []string
+	partial  string
+	scroll   int
+	pageSize int
+	input    []rune
+	cursor   int
+	history  []string
+	histPos  int
+	busy     bool
+	quit     bool
+	frame    int
+	status   tuiStatus
+	redraw   chan struct{}
+}
+
+func runTUI(ctx context.Context, orch *orchestrator.Orchestrator, cfg *config.Config, ui *ConsoleUI, sessions *SessionState) error {
+	inFd := int(os.Stdin.Fd())
+	outFd := int(os.Stdout.Fd())
+	if !term.IsTerminal(inFd) || !term.IsTerminal(outFd) {
+		return fmt.Errorf("--tui requires an interactive terminal")
+	}
+
+	app := &tuiApp{
+		ctx:      ctx,
+		orch:     orch,
+		cfg:      cfg,
+		ui:       ui,
+		sessions: sessions,
+		tracker:  newCancelTracker(),
+		complete: newCompleter(orch, cfg, sessions),
+		term:     os.Stdout,
+		pageSize: 10,
+		redraw:   make(chan struct{}, 1),
+	}
+
+	state, err := term.MakeRaw(inFd)
+	if err != nil {
+		return fmt.Errorf("enable raw mode: %w", err)
+	}
+	defer func() {
+		_ = term.Restore(inFd, state)
+	}()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	origStdout, origStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = writer, writer
+	captureDone := make(chan struct{})
+	go app.capture(reader, captureDone)
+	defer func() {
+		os.Stdout, os.Stderr = origStdout, origStderr
+		_ = writer.Close()
+		<-captureDone
+		_ = reader.Close()
+	}()
+
+	stopSignals := startCancelWatcher(app.tracker, ui)
+	defer stopSignals()
+
+	ui.SetQuiet(app.requestRedraw)
+
+	fmt.Fprint(app.term, ansiAltScreenOn+ansiPasteOn)
+	defer fmt.Fprint(app.term, ansiPasteOff+ansiShowCursor+ansiAltScreenOff)
+
+	app.history = append(app.history, sessions.History().Entries()...)
+	app.histPos = len(app.history)
+
+	app.refreshStatus()
+	fmt.Fprint(os.Stderr, formatBanner("Model", orch.Model(), "(Ctrl+T to cycle, /model to pick)"))
+	fmt.Fprint(os.Stderr, formatBanner("Session", sessions.Current(), "(Ctrl+O to cycle, /session to manage)"))
+
+	keys := make(chan tuiKey, 64)
+	go readTUIKeys(os.Stdin, keys)
+
+	ticker := time.NewTicker(tuiRefresh)
+	defer ticker.Stop()
+
+	for {
+		app.render()
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			app.handleKey(key)
+		case <-app.redraw:
+		case <-ticker.C:
+			app.mu.Lock()
+			app.frame++
+			app.mu.Unlock()
+		}
+		if app.shouldQuit() {
+			return nil
+		}
+	}
+}
+
+func (a *tuiApp) requestRedraw() {
+	select {
+	case a.redraw <- struct{}{}:
+	default:
+	}
+}
+
+func (a *tuiApp) shouldQuit() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.quit
+}
+
+func (a *tuiApp) isBusy() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.busy
+}
+
+func (a *tuiApp) capture(r io.Reader, done chan struct{}) {
+	defer close(done)
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			a.appendOutput(string(buf[:n]))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (a *tuiApp) appendOutput(text string) {
+	a.mu.Lock()
+	parts := strings.Split(a.partial+text, "\n")
+	a.partial = parts[len(parts)-1]
+	for _, part := range parts[:len(parts)-1] {
+		a.lines = append(a.lines, sanitizeTerminalText(part))
+	}
+	if len(a.lines) > tuiMaxLines {
+		a.lines = a.lines[len(a.lines)-tuiMaxLines:]
+	}
+	a.mu.Unlock()
+	a.requestRedraw()
+}
+
+// refreshStatus caches status bar values. It must only run while no turn is in
+// flight, since the orchestrator is not safe for concurrent use.
+func (a *tuiApp) refreshStatus() {
+	stats := a.orch.ConversationStats()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.status = tuiStatus{
+		model:   a.orch.Model(),
+		session: a.sessions.Current(),
+		tokens:  stats.ApproxTokens,
+	}
+}
+
+// runTask runs fn off the render loop, marking the UI busy until it returns.
+func (a *tuiApp) runTask(fn func() bool) {
+	a.mu.Lock()
+	if a.busy {
+		a.mu.Unlock()
+		return
+	}
+	a.busy = true
+	a.mu.Unlock()
+
+	go func() {
+		exit := fn()
+		a.refreshStatus()
+		a.mu.Lock()
+		a.busy = false
+		if exit {
+			a.quit = true
+		}
+		a.mu.Unlock()
+		a.requestRedraw()
+	}()
+}
+
+func (a *tuiApp) handleKey(key tuiKey) {
+	switch key.kind {
+	case keyCtrlC:
+		if a.isBusy() {
+			if a.tracker.Cancel() {
+				a.ui.StopSpinner()
+				a.appendOutput("Cancel requested.\n")
+			}
+			return
+		}
+		a.setInput("")
+	case keyCtrlD:
+		if !a.isBusy() && a.inputEmpty() {
+			a.mu.Lock()
+			a.quit = true
+			a.mu.Unlock()
+		}
+	case keyEnter:
+		a.submit()
+	case keyCtrlT:
+		a.cycleModel()
+	case keyCtrlO:
+		a.cycleSession()
+	case keyCtrlL:
+		fmt.Fprint(a.term, ansiClearScreen)
+	case keyTab:
+		a.completeInput()
+	default:
+		a.editInput(key)
+	}
+}
+
+func (a *tuiApp) editInput(key tuiKey) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch key.kind {
+	case keyRune:
+		a.insertLocked([]rune{key.r})
+	case keyPaste:
+		text := strings.ReplaceAll(key.text, "\r\n", "\n")
+		text = strings.ReplaceAll(text, "\r", "\n")
+		a.insertLocked([]rune(text))
+	case keyBackspace:
+		if a.cursor > 0 {
+			a.input = append(a.input[:a.cursor-1], a.input[a.cursor:]...)
+			a.cursor--
+		}
+	case keyDelete:
+		if a.cursor < len(a.input) {
+			a.input = append(a.input[:a.cursor], a.input[a.cursor+1:]...)
+		}
+	case keyLeft:
+		if a.cursor > 0 {
+			a.cursor--
+		}
+	case keyRight:
+		if a.cursor < len(a.input) {
+			a.cursor++
+		}
+	case keyHome:
+		a.cursor = 0
+	case keyEnd:
+		a.cursor = len(a.input)
+	case keyCtrlU:
+		a.input = append([]rune{}, a.input[a.cursor:]...)
+		a.cursor = 0
+	case keyCtrlK:
+		a.input = a.input[:a.cursor]
+	case keyCtrlW:
+		start := a.cursor
+		for start > 0 && a.input[start-1] == ' ' {
+			start--
+		}
+		for start > 0 && a.input[start-1] != ' ' {
+			start--
+		}
+		a.input = append(a.input[:start], a.input[a.cursor:]...)
+		a.cursor = start
+	case keyUp:
+		if a.histPos > 0 {
+			a.histPos--
+			a.input = []rune(a.history[a.histPos])
+			a.cursor = len(a.input)
+		}
+	case keyDown:
+		if a.histPos < len(a.history) {
+			a.histPos++
+			value := ""
+			if a.histPos < len(a.history) {
+				value = a.history[a.histPos]
+			}
+			a.input = []rune(value)
+			a.cursor = len(a.input)
+		}
+	case keyPageUp:
+		a.scroll += a.pageSize
+	case keyPageDown:
+		a.scroll -= a.pageSize
+		if a.scroll < 0 {
+			a.scroll = 0
+		}
+	}
+}
+
+func (a *tuiApp) insertLocked(runes []rune) {
+	tail := append([]rune{}, a.input[a.cursor:]...)
+	a.input = append(append(a.input[:a.cursor], runes...), tail...)
+	a.cursor += len(runes)
+}
+
+func (a *tuiApp) inputEmpty() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.input) == 0
+}
+
+func (a *tuiApp) setInput(value string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.input = []rune(value)
+	a.cursor = len(a.input)
+}
+
+func (a *tuiApp) submit() {
+	if a.isBusy() {
+		return
+	}
+
+	a.mu.Lock()
+	raw := string(a.input)
+	a.input = nil
+	a.cursor = 0
+	a.scroll = 0
+	text := strings.TrimSpace(raw)
+	if text != "" {
+		a.history = append(a.history, raw)
+	}
+	a.histPos = len(a.history)
+	a.mu.Unlock()
+
+	if text == "" {
+		return
+	}
+
+	switch text {
+	case "/clear":
+		a.mu.Lock()
+		a.lines = nil
+		a.partial = ""
+		a.mu.Unlock()
+		return
+	case "/paste":
+		a.appendOutput("Paste directly into the input line; multi-line pastes are kept intact.\n")
+		return
+	}
+
+	wasPaste := strings.Contains(text, "\n")
+	recordHistory(a.sessions, text)
+	echo := strings.ReplaceAll(text, "\n", "\n  ")
+	a.appendOutput(colorBold + colorCyan + tuiInputPrompt + colorReset + echo + "\n")
+
+	a.runTask(func() bool {
+		exit, err := processInput(a.ctx, text, wasPaste, a.orch, a.cfg, a.ui, a.sessions, a.tracker)
+		if err != nil {
+			fmt.Println("Error:", err)
+		}
+		if draft := a.sessions.TakeDraft(); draft != "" {
+			a.setInput(draft)
+		}
+		return exit
+	})
+}
+
+// completeInput applies tab completion at the cursor, listing the candidates
+// in the conversation pane when more than one matches.
+func (a *tuiApp) completeInput() {
+	if a.isBusy() {
+		return
+	}
+	a.mu.Lock()
+	before := string(a.input[:a.cursor])
+	tail := append([]rune{}, a.input[a.cursor:]...)
+	a.mu.Unlock()
+
+	head, matches := a.complete.candidates(before)
+	if len(matches) == 0 {
+		return
+	}
+	replacement := matches[0]
+	if len(matches) > 1 {
+		a.appendOutput(colorGray + strings.Join(matches, "  ") + colorReset + "\n")
+		replacement = commonPrefix(matches)
+		if len(replacement) < len(before)-len(head) {
+			return
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.input = append([]rune(head+replacement), tail...)
+	a.cursor = len([]rune(head + replacement))
+}
+
+func (a *tuiApp) cycleModel() {
+	if a.isBusy() || len(availableModels) == 0 {
+		return
+	}
+	current := a.orch.Model()
+	next := availableModels[0]
+	for i, model := range availableModels {
+		if model == current {
+			next = availableModels[(i+1)%len(availableModels)]
+			break
+		}
+	}
+	a.orch.SetModel(next)
+	_ = a.sessions.SaveFromOrch(a.orch)
+	a.refreshStatus()
+	a.appendOutput(fmt.Sprintf("Model set to %s\n", next))
+}
+
+func (a *tuiApp) cycleSession() {
+	a.runTask(func() bool {
+		all, err := a.sessions.store.List()
+		if err != nil {
+			fmt.Println(err)
+			return false
+		}
+		names := make([]string, 0, len(all))
+		for _, entry := range all {
+			names = append(names, entry.Name)
+		}
+		sort.Strings(names)
+		if len(names) < 2 {
+			fmt.Println("No other sessions (use /session new <name>).")
+			return false
+		}
+		next := names[0]
+		for i, name := range names {
+			if name == a.sessions.Current() {
+				next = names[(i+1)%len(names)]
+				break
+			}
+		}
+		if err := a.sessions.Switch(next, a.orch); err != nil {
+			fmt.Println(err)
+			return false
+		}
+		a.ui.StopSpinner()
+		_ = a.sessions.SaveFromOrch(a.orch)
+		fmt.Printf("Switched to session %s\n", next)
+		return false
+	})
+}
+
+func (a *tuiApp) render() {
+	width, height, err := term.GetSize(int(a.term.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	bodyHeight := height - 3
+	if bodyHeight < 1 {
+		bodyHeight = 1
+	}
+	toolWidth := 0
+	if width >= tuiMinToolWidth {
+		toolWidth = tuiToolPaneWidth
+	}
+	chatWidth := width
+	if toolWidth > 0 {
+		chatWidth = width - toolWidth - 1
+	}
+
+	toolRows := a.toolRows(toolWidth, bodyHeight)
+	activity, _ := a.ui.Activity()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	markdownOutput.SetTarget(true, chatWidth)
+
+	a.pageSize = bodyHeight - 1
+	if a.pageSize < 1 {
+		a.pageSize = 1
+	}
+
+	var rows []string
+	for _, line := range a.lines {
+		rows = append(rows, wrapANSI(line, chatWidth)...)
+	}
+	if a.partial != "" {
+		rows = append(rows, wrapANSI(sanitizeTerminalText(a.partial), chatWidth)...)
+	}
+	maxScroll := len(rows) - bodyHeight
+	if maxScroll < 0 {
+		maxScroll = 0
+	}
+	if a.scroll > maxScroll {
+		a.scroll = maxScroll
+	}
+	end := len(rows) - a.scroll
+	start := end - bodyHeight
+	if start < 0 {
+		start = 0
+	}
+	visible := rows[start:end]
+
+	var b strings.Builder
+	b.WriteString(ansiHideCursor)
+	for row := 0; row < bodyHeight; row++ {
+		fmt.Fprintf(&b, "\x1b[%d;1H", row+1)
+		line := ""
+		if row < len(visible) {
+			line = visible[row]
+		}
+		b.WriteString(padANSI(line, chatWidth))
+		if toolWidth > 0 {
+			b.WriteString(colorGray + "│" + colorReset)
+			b.WriteString(padANSI(toolRows[row], toolWidth))
+		}
+	}
+
+	fmt.Fprintf(&b, "\x1b[%d;1H", bodyHeight+1)
+	b.WriteString(ansiInverse + padANSI(a.statusLine(activity), width) + colorReset)
+
+	inputLine, cursorCol := a.inputLine(width)
+	fmt.Fprintf(&b, "\x1b[%d;1H", bodyHeight+2)
+	b.WriteString(inputLine + ansiClearToEOL)
+
+	fmt.Fprintf(&b, "\x1b[%d;1H", bodyHeight+3)
+	hint := "Enter send · Tab complete · Ctrl+C cancel · Ctrl+T model · Ctrl+O session · PgUp/PgDn scroll · Ctrl+D quit"
+	if a.scroll > 0 {
+		hint = fmt.Sprintf("[scrolled %d lines] ", a.scroll) + hint
+	}
+	b.WriteString(colorGray + truncateANSI(hint, width) + colorReset + ansiClearToEOL)
+
+	fmt.Fprintf(&b, "\x1b[%d;%dH", bodyHeight+2, cursorCol)
+	b.WriteString(ansiShowCursor)
+	_, _ = a.term.WriteString(b.String())
+}
+
+func (a *tuiApp) statusLine(activity string) string {
+	percent := (float64(a.status.tokens) / float64(contextLimitTokens)) * 100
+	state := "idle"
+	if a.busy {
+		frames := []string{"|", "/", "-", "\\"}
+		label := activity
+		if label == "" {
+			label = "working"
+		}
+		state = frames[a.frame%len(frames)] + " " + label
+	}
+	sessionName := a.status.session
+	if sessionName == "" {
+		sessionName = defaultSessionName
+	}
+	return fmt.Sprintf(" %s │ session: %s │ tokens: ~%d/%d (%.1f%%) │ %s ",
+		shortModelName(a.status.model), sessionName, a.status.tokens, contextLimitTokens, percent, state)
+}
+
+func (a *tuiApp) inputLine(width int) (string, int) {
+	display := make([]rune, len(a.input))
+	for i, r := range a.input {
+		if r == '\n' {
+			r = '↵'
+		}
+		display[i] = r
+	}
+	avail := width - len(tuiInputPrompt) - 1
+	if avail < 1 {
+		avail = 1
+	}
+	offset := 0
+	if a.cursor > avail {
+		offset = a.cursor - avail
+	}
+	end := offset + avail
+	if end > len(display) {
+		end = len(display)
+	}
+	visible := display[offset:end]
+	cursorCol := len(tuiInputPrompt) + 1 + runewidth.StringWidth(string(display[offset:a.cursor]))
+	return colorBold + colorCyan + tuiInputPrompt + colorReset + string(visible), cursorCol
+}
+
+func (a *tuiApp) toolRows(width int, height int) []string {
+	rows := make([]string, height)
+	if width <= 0 {
+		return rows
+	}
+
+	var lines []string
+	lines = append(lines, colorBold+"Tool calls"+colorReset)
+	for _, event := range a.ui.snapshotHistory() {
+		marker := colorGreen + "✓" + colorReset
+		if event.IsError {
+			marker = colorRed + "✗" + colorReset
+		}
+		lines = append(lines, truncateANSI(fmt.Sprintf("%s %s %s", marker, event.Name, colorGray+formatDuration(event.Duration)+colorReset), width))
+		if event.Args != "" {
+			lines = append(lines, colorGray+"  "+truncateANSI(event.Args, width-2)+colorReset)
+		}
+	}
+	if _, current := a.ui.Activity(); current != nil {
+		lines = append(lines, truncateANSI(fmt.Sprintf("%s %s %s", colorYellow+"…"+colorReset, current.Name, colorGray+formatDuration(time.Since(current.Started))+colorReset), width))
+		if current.Args != "" {
+			lines = append(lines, colorGray+"  "+truncateANSI(current.Args, width-2)+colorReset)
+		}
+	}
+
+	if len(lines) > height {
+		lines = append(lines[:1], lines[len(lines)-height+1:]...)
+	}
+	copy(rows, lines)
+	return rows
+}
+
+// sanitizeTerminalText keeps SGR color sequences and printable text, dropping
+// cursor movement and other control sequences that would corrupt the layout.
+func sanitizeTerminalText(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == 0x1b:
+			end := ansiSequenceEnd(text, i)
+			if seq := text[i:end]; strings.HasPrefix(seq, "\x1b[") && strings.HasSuffix(seq, "m") {
+				b.WriteString(seq)
+			}
+			i = end
+		case c == '\t':
+			b.WriteString("    ")
+			i++
+		case c < 0x20 || c == 0x7f:
+			i++
+		default:
+			r, size := utf8.DecodeRuneInString(text[i:])
+			b.WriteRune(r)
+			i += size
+		}
+	}
+	return b.String()
+}
+
+// ansiSequenceEnd returns the index just past the escape sequence starting at i.
+func ansiSequenceEnd(text string, i int) int {
+	if i+1 >= len(text) {
+		return len(text)
+	}
+	if text[i+1] != '[' {
+		return i + 2
+	}
+	for j := i + 2; j < len(text); j++ {
+		if text[j] >= 0x40 && text[j] <= 0x7e {
+			return j + 1
+		}
+	}
+	return len(text)
+}
+
+func ansiWidth(text string) int {
+	width := 0
+	for i := 0; i < len(text); {
+		if text[i] == 0x1b {
+			i = ansiSequenceEnd(text, i)
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		width += runewidth.RuneWidth(r)
+		i += size
+	}
+	return width
+}
+
+func padANSI(text string, width int) string {
+	text = truncateANSI(text, width)
+	pad := width - ansiWidth(text)
+	if pad < 0 {
+		pad = 0
+	}
+	return text + colorReset + strings.Repeat(" ", pad)
+}
+
+func truncateANSI(text string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if ansiWidth(text) <= width {
+		return text
+	}
+	var b strings.Builder
+	used := 0
+	for i := 0; i < len(text); {
+		if text[i] == 0x1b {
+			end := ansiSequenceEnd(text, i)
+			b.WriteString(text[i:end])
+			i = end
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		w := runewidth.RuneWidth(r)
+		if used+w > width-1 {
+			break
+		}
+		b.WriteRune(r)
+		used += w
+		i += size
+	}
+	b.WriteString("…")
+	return b.String()
+}
+
+// wrapANSI word-wraps text to width visible columns, carrying active SGR
+// attributes onto continuation rows.
+func wrapANSI(text string, width int) []string {
+	if width <= 0 || ansiWidth(text) <= width {
+		return []string{text}
+	}
+
+	var rows []string
+	var row []byte
+	active := ""
+	rowWidth := 0
+	breakAt, breakWidth := -1, 0
+	breakActive := ""
+
+	for i := 0; i < len(text); {
+		if text[i] == 0x1b {
+			end := ansiSequenceEnd(text, i)
+			seq := text[i:end]
+			row = append(row, seq...)
+			if seq == colorReset || seq == "\x1b[m" {
+				active = ""
+			} else {
+				active += seq
+			}
+			i = end
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(text[i:])
+		w := runewidth.RuneWidth(r)
+		if rowWidth+w > width && rowWidth > 0 {
+			if breakAt > 0 {
+				rest := append([]byte(breakActive), row[breakAt:]...)
+				rows = append(rows, string(bytes.TrimRight(row[:breakAt], " ")))
+				row = rest
+				rowWidth -= breakWidth
+			} else {
+				rows = append(rows, string(row))
+				row = []byte(active)
+				rowWidth = 0
+			}
+			breakAt = -1
+		}
+
+		row = append(row, text[i:i+size]...)
+		rowWidth += w
+		if r == ' ' {
+			breakAt = len(row)
+			breakWidth = rowWidth
+			breakActive = active
+		}
+		i += size
+	}
+	rows = append(rows, string(row))
+	return rows
+}
+
+func readTUIKeys(in io.Reader, keys chan<- tuiKey) {
+	defer close(keys)
+	decoder := &keyDecoder{}
+	buf := make([]byte, 4096)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			for _, key := range decoder.feed(buf[:n]) {
+				keys <- key
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// keyDecoder turns raw terminal bytes into key events, including bracketed
+// paste payloads. Incomplete sequences are kept until the next read.
+type keyDecoder struct {
+	pending []byte
+	inPaste bool
+	paste   []byte
+}
+
+func (d *keyDecoder) feed(data []byte) []tuiKey {
+	buf := append(d.pending, data...)
+	d.pending = nil
+
+	var keys []tuiKey
+	for i := 0; i < len(buf); {
+		if d.inPaste {
+			idx := bytes.Index(buf[i:], []byte(bracketPasteEnd))
+			if idx < 0 {
+				keep := len(buf) - len(bracketPasteEnd)
+				if keep < i {
+					keep = i
+				}
+				d.paste = append(d.paste, buf[i:keep]...)
+				d.pending = append([]byte{}, buf[keep:]...)
+				return keys
+			}
+			d.paste = append(d.paste, buf[i:i+idx]...)
+			keys = append(keys, tuiKey{kind: keyPaste, text: string(d.paste)})
+			d.paste = nil
+			d.inPaste = false
+			i += idx + len(bracketPasteEnd)
+			continue
+		}
+
+		c := buf[i]
+		if c == 0x1b {
+			if i+1 >= len(buf) {
+				i++
+				continue
+			}
+			switch buf[i+1] {
+			case '[':
+				end := -1
+				for j := i + 2; j < len(buf); j++ {
+					if buf[j] >= 0x40 && buf[j] <= 0x7e {
+						end = j
+						break
+					}
+				}
+				if end < 0 {
+					d.pending = append([]byte{}, buf[i:]...)
+					return keys
+				}
+				seq := string(buf[i+2 : end+1])
+				i = end + 1
+				if seq == "200~" {
+					d.inPaste = true
+					continue
+				}
+				keys = append(keys, tuiKey{kind: csiKey(seq)})
+			case 'O':
+				if i+2 >= len(buf) {
+					d.pending = append([]byte{}, buf[i:]...)
+					return keys
+				}
+				keys = append(keys, tuiKey{kind: csiKey(string(buf[i+2]))})
+				i += 3
+			default:
+				i += 2
+			}
+			continue
+		}
+
+		if kind, ok := controlKey(c); ok {
+			keys = append(keys, tuiKey{kind: kind})
+			i++
+			continue
+		}
+		if c < 0x20 {
+			i++
+			continue
+		}
+
+		if !utf8.FullRune(buf[i:]) {
+			d.pending = append([]byte{}, buf[i:]...)
+			return keys
+		}
+		r, size := utf8.DecodeRune(buf[i:])
+		keys = append(keys, tuiKey{kind: keyRune, r: r})
+		i += size
+	}
+	return keys
+}
+
+func csiKey(seq string) tuiKeyKind {
+	switch seq {
+	case "A":
+		return keyUp
+	case "B":
+		return keyDown
+	case "C":
+		return keyRight
+	case "D":
+		return keyLeft
+	case "H", "1~", "7~":
+		return keyHome
+	case "F", "4~", "8~":
+		return keyEnd
+	case "3~":
+		return keyDelete
+	case "5~":
+		return keyPageUp
+	case "6~":
+		return keyPageDown
+	default:
+		return keyUnknown
+	}
+}
+
+func controlKey(c byte) (tuiKeyKind, bool) {
+	switch c {
+	case '\r', '\n':
+		return keyEnter, true
+	case 0x7f, 0x08:
+		return keyBackspace, true
+	case 0x01:
+		return keyHome, true
+	case 0x03:
+		return keyCtrlC, true
+	case 0x04:
+		return keyCtrlD, true
+	case 0x05:
+		return keyEnd, true
+	case '\t':
+		return keyTab, true
+	case 0x0b:
+		return keyCtrlK, true
+	case 0x0c:
+		return keyCtrlL, true
+	case 0x0f:
+		return keyCtrlO, true
+	case 0x14:
+		return keyCtrlT, true
+	case 0x15:
+		return keyCtrlU, true
+	case 0x17:
+		return keyCtrlW, true
+	default:
+		return keyUnknown, false
+	}
+}
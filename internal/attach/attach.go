@@ -0,0 +1,401 @@
+package attach
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Default limits applied when Options leaves them unset.
+const (
+	DefaultMaxFileSize    = 200000
+	DefaultMaxTotalSize   = 600000
+	DefaultMaxTreeEntries = 400
+	binarySniffSize       = 8000
+)
+
+// Kinds of attachment.
+const (
+	KindFile  = "file"
+	KindRange = "range"
+	KindTree  = "tree"
+)
+
+// Attachment is file or directory content attached to a prompt.
+type Attachment struct {
+	Label   string
+	Path    string
+	Kind    string
+	Content string
+}
+
+// Options controls how mentions are resolved and loaded.
+type Options struct {
+	// Root is the project directory used for relative paths and .gitignore.
+	Root           string
+	MaxFileSize    int
+	MaxTotalSize   int
+	MaxTreeEntries int
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxFileSize <= 0 {
+		o.MaxFileSize = DefaultMaxFileSize
+	}
+	if o.MaxTotalSize <= 0 {
+		o.MaxTotalSize = DefaultMaxTotalSize
+	}
+	if o.MaxTreeEntries <= 0 {
+		o.MaxTreeEntries = DefaultMaxTreeEntries
+	}
+	if o.Root == "" {
+		o.Root, _ = os.Getwd()
+	}
+	return o
+}
+
+// Mention is an @path reference found in a prompt.
+type Mention struct {
+	Raw       string
+	Path      string
+	StartLine int
+	EndLine   int
+}
+
+var (
+	mentionPattern = regexp.MustCompile(`(?:^|\s)@("[^"]+"|[^\s"]+)`)
+	rangePattern   = regexp.MustCompile(`^(.*?):(\d+)(?:-(\d+))?$`)
+)
+
+// ParseMentions returns @path mentions in text that resolve to existing files
+// or directories under root. Trailing punctuation is ignored.
+func ParseMentions(text string, root string) []Mention {
+	var mentions []Mention
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		token := strings.Trim(match[1], `"`)
+		mention, ok := resolveMention(token, root)
+		if !ok || seen[mention.Raw] {
+			continue
+		}
+		seen[mention.Raw] = true
+		mentions = append(mentions, mention)
+	}
+	return mentions
+}
+
+func resolveMention(token string, root string) (Mention, bool) {
+	candidates := []string{token}
+	if trimmed := strings.TrimRight(token, ".,;:!?)]}'`"); trimmed != token {
+		candidates = append(candidates, trimmed)
+	}
+	for _, candidate := range candidates {
+		mention := Mention{Raw: candidate, Path: candidate}
+		if m := rangePattern.FindStringSubmatch(candidate); m != nil {
+			start, _ := strconv.Atoi(m[2])
+			end := start
+			if m[3] != "" {
+				end, _ = strconv.Atoi(m[3])
+			}
+			if start > 0 && end >= start {
+				mention.Path = m[1]
+				mention.StartLine = start
+				mention.EndLine = end
+			}
+		}
+		if mention.Path == "" {
+			continue
+		}
+		if _, err := os.Stat(resolvePath(mention.Path, root)); err == nil {
+			return mention, true
+		}
+	}
+	return Mention{}, false
+}
+
+// Load resolves mentions into attachments, enforcing size limits. Problems
+// with individual mentions are returned as warnings rather than failing.
+func Load(mentions []Mention, opts Options) ([]Attachment, []string) {
+	opts = opts.withDefaults()
+	var attachments []Attachment
+	var warnings []string
+	total := 0
+	ignorer := NewIgnorer(opts.Root)
+
+	for _, mention := range mentions {
+		path := resolvePath(mention.Path, opts.Root)
+		info, err := os.Stat(path)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", mention.Raw, err))
+			continue
+		}
+
+		var attachment Attachment
+		switch {
+		case info.IsDir():
+			attachment, err = LoadTree(path, opts, ignorer)
+		case mention.StartLine > 0:
+			attachment, err = LoadRange(path, mention.StartLine, mention.EndLine, opts)
+		default:
+			attachment, err = LoadFile(path, opts)
+		}
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", mention.Raw, err))
+			continue
+		}
+		if total+len(attachment.Content) > opts.MaxTotalSize {
+			warnings = append(warnings, fmt.Sprintf("%s: skipped, attachments exceed %d bytes in total", mention.Raw, opts.MaxTotalSize))
+			continue
+		}
+		total += len(attachment.Content)
+		attachments = append(attachments, attachment)
+	}
+	return attachments, warnings
+}
+
+// LoadFile reads a text file within the size limit.
+func LoadFile(path string, opts Options) (Attachment, error) {
+	opts = opts.withDefaults()
+	data, err := readText(path, opts.MaxFileSize)
+	if err != nil {
+		return Attachment{}, err
+	}
+	return Attachment{
+		Label:   Label(path, opts.Root),
+		Path:    path,
+		Kind:    KindFile,
+		Content: string(data),
+	}, nil
+}
+
+// LoadRange reads lines start..end (1-based, inclusive) prefixed with their
+// line numbers.
+func LoadRange(path string, start int, end int, opts Options) (Attachment, error) {
+	opts = opts.withDefaults()
+	info, err := os.Stat(path)
+	if err != nil {
+		return Attachment{}, err
+	}
+	data, err := readText(path, int(info.Size())+1)
+	if err != nil {
+		return Attachment{}, err
+	}
+	lines := strings.Split(string(data), "\n")
+	if start > len(lines) {
+		return Attachment{}, fmt.Errorf("line %d is past the end of the file (%d lines)", start, len(lines))
+	}
+	if end > len(lines) {
+		end = len(lines)
+	}
+
+	var b strings.Builder
+	for i := start; i <= end; i++ {
+		fmt.Fprintf(&b, "%d: %s\n", i, lines[i-1])
+	}
+	if b.Len() > opts.MaxFileSize {
+		return Attachment{}, fmt.Errorf("range too large (%d bytes); limit is %d bytes", b.Len(), opts.MaxFileSize)
+	}
+	return Attachment{
+		Label:   fmt.Sprintf("%s:%d-%d", Label(path, opts.Root), start, end),
+		Path:    path,
+		Kind:    KindRange,
+		Content: strings.TrimRight(b.String(), "\n"),
+	}, nil
+}
+
+// LoadTree summarizes a directory as an indented listing of non-ignored
+// entries with file sizes.
+func LoadTree(dir string, opts Options, ignorer *Ignorer) (Attachment, error) {
+	opts = opts.withDefaults()
+	if ignorer == nil {
+		ignorer = NewIgnorer(opts.Root)
+	}
+
+	var b strings.Builder
+	entries := 0
+	truncated := false
+	fileCount, dirCount := 0, 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path == dir {
+			return nil
+		}
+		if d.Name() == ".git" || ignorer.Ignored(path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entries >= opts.MaxTreeEntries {
+			truncated = true
+			return filepath.SkipAll
+		}
+		entries++
+
+		rel, _ := filepath.Rel(dir, path)
+		depth := strings.Count(rel, string(filepath.Separator))
+		indent := strings.Repeat("  ", depth)
+		if d.IsDir() {
+			dirCount++
+			fmt.Fprintf(&b, "%s%s/\n", indent, d.Name())
+			return nil
+		}
+		fileCount++
+		size := int64(0)
+		if info, err := d.Info(); err == nil {
+			size = info.Size()
+		}
+		fmt.Fprintf(&b, "%s%s (%s)\n", indent, d.Name(), formatSize(size))
+		return nil
+	})
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	summary := fmt.Sprintf("%d directories, %d files", dirCount, fileCount)
+	if truncated {
+		summary += fmt.Sprintf(" (listing truncated at %d entries)", opts.MaxTreeEntries)
+	}
+	label := Label(dir, opts.Root)
+	if !strings.HasSuffix(label, "/") {
+		label += "/"
+	}
+	return Attachment{
+		Label:   label,
+		Path:    dir,
+		Kind:    KindTree,
+		Content: strings.TrimRight(b.String(), "\n") + "\n" + summary,
+	}, nil
+}
+
+// IsBinary reports whether data looks like binary content.
+func IsBinary(data []byte) bool {
+	sample := data
+	if len(sample) > binarySniffSize {
+		sample = sample[:binarySniffSize]
+	}
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return true
+	}
+	if len(data) > binarySniffSize {
+		// Drop a multi-byte rune cut off at the sample boundary.
+		for i := 0; i < utf8.UTFMax && !utf8.Valid(sample); i++ {
+			sample = sample[:len(sample)-1]
+		}
+	}
+	return !utf8.Valid(sample)
+}
+
+// Label returns path relative to root when it lies inside it.
+func Label(path string, root string) string {
+	if root != "" {
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return path
+}
+
+func readText(path string, maxSize int) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > int64(maxSize) {
+		return nil, fmt.Errorf("file too large (%d bytes); limit is %d bytes", info.Size(), maxSize)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if IsBinary(data) {
+		return nil, fmt.Errorf("binary file skipped")
+	}
+	return data, nil
+}
+
+func resolvePath(path string, root string) string {
+	if strings.HasPrefix(path, "~") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	if filepath.IsAbs(path) || root == "" {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(root, path)
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
+
+// Ignorer answers whether paths are excluded by .gitignore rules. It asks git
+// once for the ignored set and treats everything as included outside a repo.
+type Ignorer struct {
+	root    string
+	ignored map[string]bool
+}
+
+// NewIgnorer builds an Ignorer for the repository containing root.
+func NewIgnorer(root string) *Ignorer {
+	ig := &Ignorer{ignored: make(map[string]bool)}
+	top, err := exec.Command("git", "-C", root, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return ig
+	}
+	ig.root = strings.TrimSpace(string(top))
+
+	out, err := exec.Command("git", "-C", ig.root, "ls-files", "--others", "--ignored", "--exclude-standard", "--directory", "-z").Output()
+	if err != nil {
+		return ig
+	}
+	for _, entry := range strings.Split(string(out), "\x00") {
+		entry = strings.TrimSuffix(entry, "/")
+		if entry != "" {
+			ig.ignored[filepath.FromSlash(entry)] = true
+		}
+	}
+	return ig
+}
+
+// Ignored reports whether path, or one of its parent directories, is ignored.
+func (i *Ignorer) Ignored(path string) bool {
+	if i == nil || i.root == "" || len(i.ignored) == 0 {
+		return false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	rel, err := filepath.Rel(i.root, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	for rel != "." && rel != "" {
+		if i.ignored[rel] {
+			return true
+		}
+		rel = filepath.Dir(rel)
+	}
+	return false
+}
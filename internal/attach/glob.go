@@ -0,0 +1,126 @@
+package attach
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ExpandPattern resolves a file path, directory or glob (including ** for any
+// number of directories) to the files it names, relative to root. Directories
+// are walked recursively, skipping .git and .gitignore'd entries. At most
+// limit files are returned.
+func ExpandPattern(pattern string, root string, ignorer *Ignorer, limit int) ([]string, error) {
+	if ignorer == nil {
+		ignorer = NewIgnorer(root)
+	}
+	resolved := resolvePath(pattern, root)
+
+	if !strings.ContainsAny(pattern, "*?[") {
+		info, err := os.Stat(resolved)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return []string{resolved}, nil
+		}
+		return walkFiles(resolved, ignorer, limit, nil)
+	}
+
+	base := globBase(resolved)
+	rest, err := filepath.Rel(base, resolved)
+	if err != nil {
+		return nil, err
+	}
+	rest = filepath.ToSlash(rest)
+	matches, err := walkFiles(base, ignorer, limit, func(rel string) bool {
+		return matchGlob(rest, rel)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no files match %s", pattern)
+	}
+	return matches, nil
+}
+
+// walkFiles lists regular files under dir accepted by match (all when nil).
+func walkFiles(dir string, ignorer *Ignorer, limit int, match func(rel string) bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir {
+				return err
+			}
+			return nil
+		}
+		if p != dir && (d.Name() == ".git" || ignorer.Ignored(p)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if match != nil {
+			rel, err := filepath.Rel(dir, p)
+			if err != nil || !match(filepath.ToSlash(rel)) {
+				return nil
+			}
+		}
+		if limit > 0 && len(files) >= limit {
+			return fmt.Errorf("more than %d files match; narrow the pattern", limit)
+		}
+		files = append(files, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// globBase returns the longest leading directory of pattern without glob
+// metacharacters.
+func globBase(pattern string) string {
+	dir := pattern
+	for strings.ContainsAny(dir, "*?[") {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// matchGlob matches a slash-separated path against a pattern where ** matches
+// zero or more path segments and other segments use path.Match.
+func matchGlob(pattern string, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
@@ -1,11 +1,13 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"net/url"
 
 	"github.com/spf13/viper"
+
+	"github.com/unixsysdev/serena-cli-go/internal/credentials"
 )
 
 // Config holds all configuration for Serena CLI
@@ -13,10 +15,61 @@ type Config struct {
 	LLM    LLMConfig    `mapstructure:"llm"`
 	Serena SerenaConfig `mapstructure:"serena"`
 	Debug  bool         `mapstructure:"debug"`
+
+	AutoCommit       bool   `mapstructure:"auto_commit"`
+	AutoCommitBranch string `mapstructure:"auto_commit_branch"`
+
+	SessionBackend    string           `mapstructure:"session_backend"`
+	SessionRetention  RetentionConfig  `mapstructure:"session_retention"`
+	SessionEncryption EncryptionConfig `mapstructure:"session_encryption"`
+
+	Redaction RedactionConfig `mapstructure:"redaction"`
+
+	// CredentialStore is where "serena auth login" keeps API keys: auto,
+	// keyring or file.
+	CredentialStore string `mapstructure:"credential_store"`
+
+	// files and origins record the config files merged and where each
+	// value came from.
+	files   []string
+	origins map[string]string
+}
+
+// RedactionConfig controls the secret redaction applied to everything sent
+// to the LLM or stored in sessions.
+type RedactionConfig struct {
+	Enabled  bool               `mapstructure:"enabled"`
+	Entropy  bool               `mapstructure:"entropy"`
+	Patterns []RedactionPattern `mapstructure:"patterns"`
+}
+
+// RedactionPattern is a custom secret detector.
+type RedactionPattern struct {
+	Name  string `mapstructure:"name"`
+	Regex string `mapstructure:"regex"`
+}
+
+// EncryptionConfig controls encryption of stored sessions. The key comes
+// from KeyFile, or from a passphrase in SERENA_SESSION_PASSPHRASE or typed at
+// startup.
+type EncryptionConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	KeyFile string `mapstructure:"key_file"`
+}
+
+// RetentionConfig holds the default policy of "serena session gc". Zero
+// values disable a limit.
+type RetentionConfig struct {
+	MaxAgeDays int `mapstructure:"max_age_days"`
+	MaxCount   int `mapstructure:"max_count"`
+	MaxSizeMB  int `mapstructure:"max_size_mb"`
 }
 
 // LLMConfig holds LLM API configuration.
 type LLMConfig struct {
+	// Provider names the stored credentials to use; it defaults to the host
+	// of BaseURL.
+	Provider        string `mapstructure:"provider"`
 	APIKey          string `mapstructure:"api_key"`
 	BaseURL         string `mapstructure:"base_url"`
 	Model           string `mapstructure:"model"`
@@ -40,56 +93,85 @@ type SerenaConfig struct {
 // LoadOptions controls configuration loading behavior.
 type LoadOptions struct {
 	SkipValidation bool
+	// ConfigFile is loaded instead of the user and project config files.
+	ConfigFile string
+	// Overrides are key=value settings from the command line, applied last.
+	Overrides map[string]string
+	// CredentialPassphrase asks for the passphrase of the credentials file
+	// when a stored key is kept there and the passphrase is not in the
+	// environment.
+	CredentialPassphrase func(creating bool) ([]byte, error)
 }
 
-// Load loads configuration from file, environment, and defaults.
+// Load loads configuration from defaults, files and the environment.
 func Load() (*Config, error) {
 	return LoadWithOptions(LoadOptions{})
 }
 
-// LoadWithOptions loads configuration with custom options.
+// LoadWithOptions merges configuration layers, each overriding the ones
+// before it key by key: built-in defaults, the user config files, the project
+// config files, environment variables and command-line overrides.
 func LoadWithOptions(opts LoadOptions) (*Config, error) {
 	v := viper.New()
+	known := Keys()
+	origins := make(map[string]string)
 
 	// Set defaults
 	setDefaults(v)
+	for _, key := range v.AllKeys() {
+		origins[key] = OriginDefault
+	}
 
-	// Config file search paths
-	v.SetConfigName("serena-cli")
-	v.SetConfigType("yaml")
-
-	v.AddConfigPath(".")
-	home, _ := os.UserHomeDir()
-	v.AddConfigPath(filepath.Join(home, ".serena-cli"))
-	v.AddConfigPath(filepath.Join(home, ".config", "serena-cli"))
-
-	// Read config file (optional)
-	if err := v.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return nil, fmt.Errorf("failed to read config: %w", err)
+	// Config files, deep-merged in order.
+	files, err := ConfigFiles(opts.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		settings, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := v.MergeConfigMap(settings); err != nil {
+			return nil, fmt.Errorf("failed to merge config %s: %w", path, err)
+		}
+		for _, key := range settingKeys("", settings, known) {
+			origins[key] = path
 		}
 	}
 
 	// Environment variables
-	v.SetEnvPrefix("SERENA")
-	v.AutomaticEnv()
-
-	// Direct LLM env vars
-	v.BindEnv("llm.api_key", "LLM_API_KEY", "CHUTES_API_KEY")
-	v.BindEnv("llm.base_url", "LLM_BASE_URL", "CHUTES_BASE_URL")
-	v.BindEnv("llm.model", "LLM_MODEL", "CHUTES_MODEL")
-	v.BindEnv("llm.compaction_model", "LLM_COMPACTION_MODEL", "CHUTES_COMPACTION_MODEL")
-	v.BindEnv("llm.timeout_seconds", "LLM_TIMEOUT_SECONDS", "CHUTES_TIMEOUT_SECONDS")
-	v.BindEnv("serena.tool_timeout_seconds", "SERENA_TOOL_TIMEOUT_SECONDS")
-	v.BindEnv("serena.enable_web_dashboard", "SERENA_ENABLE_WEB_DASHBOARD")
-	v.BindEnv("serena.enable_gui_log_window", "SERENA_ENABLE_GUI_LOG_WINDOW")
-	v.BindEnv("serena.max_tool_answer_chars", "SERENA_MAX_TOOL_ANSWER_CHARS")
+	values, names := envSettings(known)
+	for key, value := range values {
+		v.Set(key, value)
+		origins[key] = "env " + names[key]
+	}
+
+	// Command-line overrides
+	for key, value := range opts.Overrides {
+		v.Set(key, value)
+		origins[key] = OriginFlag
+	}
 
 	// Parse config.
 	var cfg Config
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
+	cfg.files = files
+	cfg.origins = origins
+
+	// Keys stored with "serena auth login" apply when none is configured.
+	if cfg.LLM.APIKey == "" {
+		key, origin, err := storedAPIKey(&cfg, opts.CredentialPassphrase)
+		if err != nil && !opts.SkipValidation {
+			return nil, err
+		}
+		if key != "" {
+			cfg.LLM.APIKey = key
+			origins["llm.api_key"] = origin
+		}
+	}
 
 	// Validate
 	if !opts.SkipValidation {
@@ -117,12 +199,60 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("serena.enable_web_dashboard", false)
 	v.SetDefault("serena.enable_gui_log_window", false)
 	v.SetDefault("debug", false)
+	v.SetDefault("auto_commit", false)
+	v.SetDefault("auto_commit_branch", "serena/work")
+	v.SetDefault("session_backend", "json")
+	v.SetDefault("session_retention.max_age_days", 0)
+	v.SetDefault("session_retention.max_count", 0)
+	v.SetDefault("session_retention.max_size_mb", 0)
+	v.SetDefault("session_encryption.enabled", false)
+	v.SetDefault("session_encryption.key_file", "")
+	v.SetDefault("redaction.enabled", true)
+	v.SetDefault("redaction.entropy", false)
+	v.SetDefault("credential_store", credentials.StoreAuto)
 }
 
 // Validate validates the configuration
 func Validate(cfg *Config) error {
 	if cfg.LLM.APIKey == "" {
-		return fmt.Errorf("LLM API key is required (set LLM_API_KEY or configure in serena-cli.yaml)")
+		return fmt.Errorf("LLM API key is required (run serena auth login, set LLM_API_KEY or configure in serena-cli.yaml)")
 	}
 	return nil
 }
+
+// ProviderName returns the name API keys of this LLM endpoint are stored
+// under.
+func (c LLMConfig) ProviderName() string {
+	if c.Provider != "" {
+		return c.Provider
+	}
+	if u, err := url.Parse(c.BaseURL); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	return "default"
+}
+
+// storedAPIKey returns the key stored for the configured provider and its
+// origin, or "" when there is none.
+func storedAPIKey(cfg *Config, passphrase func(creating bool) ([]byte, error)) (string, string, error) {
+	dir, err := credentials.DefaultDir()
+	if err != nil {
+		return "", "", err
+	}
+	store, err := credentials.Open(dir, cfg.CredentialStore)
+	if err != nil {
+		return "", "", err
+	}
+	store.SetPassphraseFunc(passphrase)
+	key, where, err := store.Get(cfg.LLM.ProviderName())
+	if errors.Is(err, credentials.ErrNotFound) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("read stored API key for %s: %w", cfg.LLM.ProviderName(), err)
+	}
+	if where == credentials.StoreKeyring {
+		return key, OriginKeyring, nil
+	}
+	return key, OriginAuthFile, nil
+}
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Origins of configuration values besides the files they were read from.
+const (
+	OriginDefault  = "default"
+	OriginFlag     = "flag --set"
+	OriginAuthFile = "serena auth login (credentials file)"
+	OriginKeyring  = "serena auth login (system keyring)"
+)
+
+// ProjectConfigFile is the project layer, relative to the project directory.
+var ProjectConfigFile = filepath.Join(".serena-cli", "config.yaml")
+
+// envBindings lists environment variables with names of their own. Every
+// other key can also be set as SERENA_<KEY>, with dots replaced by
+// underscores, such as SERENA_SESSION_BACKEND.
+var envBindings = []struct {
+	key   string
+	names []string
+}{
+	{"llm.provider", []string{"LLM_PROVIDER"}},
+	{"llm.api_key", []string{"LLM_API_KEY", "CHUTES_API_KEY"}},
+	{"llm.base_url", []string{"LLM_BASE_URL", "CHUTES_BASE_URL"}},
+	{"llm.model", []string{"LLM_MODEL", "CHUTES_MODEL"}},
+	{"llm.compaction_model", []string{"LLM_COMPACTION_MODEL", "CHUTES_COMPACTION_MODEL"}},
+	{"llm.timeout_seconds", []string{"LLM_TIMEOUT_SECONDS", "CHUTES_TIMEOUT_SECONDS"}},
+	{"serena.tool_timeout_seconds", []string{"SERENA_TOOL_TIMEOUT_SECONDS"}},
+	{"serena.enable_web_dashboard", []string{"SERENA_ENABLE_WEB_DASHBOARD"}},
+	{"serena.enable_gui_log_window", []string{"SERENA_ENABLE_GUI_LOG_WINDOW"}},
+	{"serena.max_tool_answer_chars", []string{"SERENA_MAX_TOOL_ANSWER_CHARS"}},
+}
+
+// ConfigFiles returns the configuration files merged by LoadWithOptions, from
+// lowest to highest priority: the user files, then the project files in the
+// current directory. An explicit file replaces them all. Missing files are
+// skipped, except an explicit one.
+func ConfigFiles(explicit string) ([]string, error) {
+	if explicit != "" {
+		if _, err := os.Stat(explicit); err != nil {
+			return nil, fmt.Errorf("config file: %w", err)
+		}
+		return []string{explicit}, nil
+	}
+	var candidates []string
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates,
+			filepath.Join(home, ".config", "serena-cli", "serena-cli.yaml"),
+			filepath.Join(home, ".serena-cli", "serena-cli.yaml"),
+		)
+	}
+	candidates = append(candidates, "serena-cli.yaml", ProjectConfigFile)
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, path := range candidates {
+		abs, err := filepath.Abs(path)
+		if err != nil || seen[abs] {
+			continue
+		}
+		seen[abs] = true
+		if info, err := os.Stat(abs); err == nil && !info.IsDir() {
+			files = append(files, abs)
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("config file: %w", err)
+		}
+	}
+	return files, nil
+}
+
+// readConfigFile returns the settings in one YAML file.
+func readConfigFile(path string) (map[string]interface{}, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if ext := filepath.Ext(path); ext != ".json" && ext != ".toml" {
+		v.SetConfigType("yaml")
+	}
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+	return v.AllSettings(), nil
+}
+
+// settingKeys returns the dotted keys set in settings. Recursion stops at
+// the keys of Config, so the entries of a map such as serena.env are not
+// listed separately.
+func settingKeys(prefix string, settings map[string]interface{}, known map[string]reflect.Kind) []string {
+	var keys []string
+	for name, value := range settings {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		if sub, ok := value.(map[string]interface{}); ok {
+			if _, leaf := known[key]; !leaf {
+				keys = append(keys, settingKeys(key, sub, known)...)
+				continue
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Keys returns every configuration key with the kind of its value.
+func Keys() map[string]reflect.Kind {
+	keys := make(map[string]reflect.Kind)
+	collectKeys("", reflect.TypeOf(Config{}), keys)
+	return keys
+}
+
+func collectKeys(prefix string, t reflect.Type, keys map[string]reflect.Kind) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("mapstructure")
+		if !field.IsExported() || tag == "" || tag == "-" {
+			continue
+		}
+		key := tag
+		if prefix != "" {
+			key = prefix + "." + tag
+		}
+		if field.Type.Kind() == reflect.Struct {
+			collectKeys(key, field.Type, keys)
+			continue
+		}
+		kind := field.Type.Kind()
+		if kind == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct {
+			// Lists of objects such as redaction.patterns only come from files.
+			kind = reflect.Invalid
+		}
+		keys[key] = kind
+	}
+}
+
+// envSettings returns the configuration set by environment variables, and
+// the variable each value came from.
+func envSettings(known map[string]reflect.Kind) (map[string]string, map[string]string) {
+	values := make(map[string]string)
+	names := make(map[string]string)
+	bound := make(map[string]bool)
+	for _, binding := range envBindings {
+		bound[binding.key] = true
+		for _, name := range binding.names {
+			if value := os.Getenv(name); value != "" {
+				values[binding.key], names[binding.key] = value, name
+				break
+			}
+		}
+	}
+	for key, kind := range known {
+		if bound[key] || kind == reflect.Map || kind == reflect.Invalid {
+			continue
+		}
+		name := envName(key)
+		if value := os.Getenv(name); value != "" {
+			values[key], names[key] = value, name
+		}
+	}
+	return values, names
+}
+
+// envName returns the SERENA_<KEY> variable that sets key.
+func envName(key string) string {
+	return "SERENA_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// ParseSetting splits a "key=value" command-line override and checks that
+// key exists.
+func ParseSetting(setting string) (string, string, error) {
+	key, value, ok := strings.Cut(setting, "=")
+	key = strings.ToLower(strings.TrimSpace(key))
+	if !ok || key == "" {
+		return "", "", fmt.Errorf("invalid setting %q, expected key=value", setting)
+	}
+	kind, known := Keys()[key]
+	if !known {
+		return "", "", fmt.Errorf("unknown config key %q", key)
+	}
+	if kind == reflect.Map || kind == reflect.Invalid {
+		return "", "", fmt.Errorf("%s can only be set in a config file", key)
+	}
+	return key, value, nil
+}
+
+// Origin returns where the value of key came from: OriginDefault, the path
+// of a config file, "env NAME", OriginFlag or a credential store. Keys
+// inside maps report the origin of the map.
+func (c *Config) Origin(key string) string {
+	for key != "" {
+		if origin, ok := c.origins[key]; ok {
+			return origin
+		}
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			break
+		}
+		key = key[:i]
+	}
+	return OriginDefault
+}
+
+// Files returns the config files that were merged, lowest priority first.
+func (c *Config) Files() []string {
+	return append([]string(nil), c.files...)
+}
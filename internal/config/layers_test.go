@@ -0,0 +1,171 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// isolate points the user and project config locations at empty temporary
+// directories and clears environment variables that would override them. It
+// returns the home and project directories.
+func isolate(t *testing.T) (string, string) {
+	t.Helper()
+	home, project := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	for _, binding := range envBindings {
+		for _, name := range binding.names {
+			t.Setenv(name, "")
+		}
+	}
+	for key := range Keys() {
+		t.Setenv(envName(key), "")
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(project); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	return home, project
+}
+
+func writeConfig(t *testing.T, path string, content string) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resolved
+}
+
+func TestLoadLayers(t *testing.T) {
+	home, project := isolate(t)
+	user := writeConfig(t, filepath.Join(home, ".config", "serena-cli", "serena-cli.yaml"), `
+llm:
+  model: user-model
+  timeout_seconds: 10
+  base_url: https://user.example/v1
+serena:
+  env:
+    a: "1"
+`)
+	projectFile := writeConfig(t, filepath.Join(project, ProjectConfigFile), `
+llm:
+  model: project-model
+redaction:
+  entropy: true
+`)
+	t.Setenv("LLM_TIMEOUT_SECONDS", "20")
+	t.Setenv("SERENA_AUTO_COMMIT_BRANCH", "env/branch")
+
+	cfg, err := LoadWithOptions(LoadOptions{
+		SkipValidation: true,
+		Overrides:      map[string]string{"llm.base_url": "https://flag.example/v1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key    string
+		value  interface{}
+		origin string
+	}{
+		{"llm.model", cfg.LLM.Model, projectFile},
+		{"llm.timeout_seconds", cfg.LLM.TimeoutSeconds, "env LLM_TIMEOUT_SECONDS"},
+		{"llm.base_url", cfg.LLM.BaseURL, OriginFlag},
+		{"auto_commit_branch", cfg.AutoCommitBranch, "env SERENA_AUTO_COMMIT_BRANCH"},
+		{"redaction.entropy", cfg.Redaction.Entropy, projectFile},
+		{"serena.env.a", cfg.Serena.Env["a"], user},
+		{"session_backend", cfg.SessionBackend, OriginDefault},
+	}
+	want := map[string]interface{}{
+		"llm.model":           "project-model",
+		"llm.timeout_seconds": 20,
+		"llm.base_url":        "https://flag.example/v1",
+		"auto_commit_branch":  "env/branch",
+		"redaction.entropy":   true,
+		"serena.env.a":        "1",
+		"session_backend":     "json",
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.value, want[tt.key]) {
+			t.Errorf("%s = %v, want %v", tt.key, tt.value, want[tt.key])
+		}
+		if got := cfg.Origin(tt.key); got != tt.origin {
+			t.Errorf("Origin(%s) = %q, want %q", tt.key, got, tt.origin)
+		}
+	}
+	if got := cfg.Files(); !reflect.DeepEqual(got, []string{user, projectFile}) {
+		t.Errorf("Files = %v", got)
+	}
+}
+
+func TestLoadExplicitFile(t *testing.T) {
+	home, project := isolate(t)
+	writeConfig(t, filepath.Join(home, ".serena-cli", "serena-cli.yaml"), "llm:\n  model: user-model\n")
+	writeConfig(t, filepath.Join(project, "serena-cli.yaml"), "llm:\n  compaction_model: project-model\n")
+	explicit := writeConfig(t, filepath.Join(t.TempDir(), "ci.yaml"), "llm:\n  timeout_seconds: 5\n")
+
+	cfg, err := LoadWithOptions(LoadOptions{SkipValidation: true, ConfigFile: explicit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LLM.Model != "zai-org/GLM-4.7-TEE" || cfg.LLM.CompactionModel != "Qwen/Qwen3-VL-235B-A22B-Instruct" {
+		t.Errorf("user or project file merged despite explicit file: %q, %q", cfg.LLM.Model, cfg.LLM.CompactionModel)
+	}
+	if cfg.LLM.TimeoutSeconds != 5 || cfg.Origin("llm.timeout_seconds") != explicit {
+		t.Errorf("timeout = %d from %q", cfg.LLM.TimeoutSeconds, cfg.Origin("llm.timeout_seconds"))
+	}
+	if _, err := LoadWithOptions(LoadOptions{SkipValidation: true, ConfigFile: filepath.Join(project, "missing.yaml")}); err == nil {
+		t.Error("missing explicit file accepted")
+	}
+}
+
+func TestParseSetting(t *testing.T) {
+	tests := []struct {
+		setting string
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{"llm.model=x", "llm.model", "x", false},
+		{" LLM.Model =a=b", "llm.model", "a=b", false},
+		{"debug=", "debug", "", false},
+		{"llm.model", "", "", true},
+		{"=x", "", "", true},
+		{"llm.nope=x", "", "", true},
+		{"serena.env=x", "", "", true},
+		{"redaction.patterns=x", "", "", true},
+	}
+	for _, tt := range tests {
+		key, value, err := ParseSetting(tt.setting)
+		if (err != nil) != tt.wantErr || key != tt.key || value != tt.value {
+			t.Errorf("ParseSetting(%q) = %q, %q, %v", tt.setting, key, value, err)
+		}
+	}
+}
+
+func TestOriginFallsBackToParent(t *testing.T) {
+	cfg := &Config{origins: map[string]string{"serena.env": "/etc/serena.yaml"}}
+	tests := map[string]string{
+		"serena.env.PATH": "/etc/serena.yaml",
+		"serena.env":      "/etc/serena.yaml",
+		"llm.model":       OriginDefault,
+	}
+	for key, want := range tests {
+		if got := cfg.Origin(key); got != want {
+			t.Errorf("Origin(%s) = %q, want %q", key, got, want)
+		}
+	}
+}
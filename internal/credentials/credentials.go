@@ -0,0 +1,311 @@
+// Package credentials stores LLM API keys per provider in the OS keyring,
+// or in a credentials file when no keyring is available.
+//
+// The file is encrypted with a passphrase from PassphraseEnv or from the
+// Store's passphrase function. Older versions encrypted it with a key file
+// generated next to it, which protects nothing against anyone who can read the
+// directory; such files are still read, and re-encrypted with a passphrase the
+// next time a key is stored. See UsesKeyFile.
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/zalando/go-keyring"
+
+	"github.com/unixsysdev/serena-cli-go/internal/crypt"
+	"github.com/unixsysdev/serena-cli-go/internal/fsutil"
+)
+
+// Service is the keyring service name keys are stored under.
+const Service = "serena-cli"
+
+// PassphraseEnv holds the passphrase of the credentials file.
+const PassphraseEnv = "SERENA_CREDENTIALS_PASSPHRASE"
+
+// Where keys are kept. StoreAuto uses the keyring when one is available and
+// the file otherwise.
+const (
+	StoreAuto    = "auto"
+	StoreKeyring = "keyring"
+	StoreFile    = "file"
+)
+
+const (
+	indexFileName = "credentials.json"
+	keyFileName   = "credentials.key"
+)
+
+// ErrNotFound reports a provider without stored credentials.
+var ErrNotFound = errors.New("no stored API key")
+
+// Entry records where the key of one provider is kept. Key holds the
+// encrypted key for StoreFile and is empty for StoreKeyring.
+type Entry struct {
+	Provider  string    `json:"-"`
+	Store     string    `json:"store"`
+	Key       string    `json:"key,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ErrNoPassphrase reports a credentials file that cannot be used because no
+// passphrase is available.
+var ErrNoPassphrase = fmt.Errorf("the credentials file needs a passphrase: set %s", PassphraseEnv)
+
+// Store reads and writes API keys. The index of providers lives in dir
+// next to the key derivation parameters of the credentials file.
+type Store struct {
+	dir        string
+	mode       string
+	passphrase func(creating bool) ([]byte, error)
+}
+
+// DefaultDir returns ~/.serena-cli/credentials.
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".serena-cli", "credentials"), nil
+}
+
+// Open returns the store in dir that saves new keys according to mode, one
+// of StoreAuto, StoreKeyring or StoreFile.
+func Open(dir string, mode string) (*Store, error) {
+	switch mode {
+	case "", StoreAuto:
+		mode = StoreAuto
+	case StoreKeyring, StoreFile:
+	default:
+		return nil, fmt.Errorf("unknown credential store %q (use auto, keyring or file)", mode)
+	}
+	return &Store{dir: dir, mode: mode}, nil
+}
+
+// SetPassphraseFunc sets how the passphrase of the credentials file is
+// obtained when PassphraseEnv is unset, such as by asking on the terminal.
+// creating is true when the file does not exist yet.
+func (s *Store) SetPassphraseFunc(fn func(creating bool) ([]byte, error)) {
+	s.passphrase = fn
+}
+
+// UsesKeyFile reports whether keys in the credentials file are still
+// encrypted with a key file written by an older version, and so readable by
+// anyone who can read the credentials directory.
+func (s *Store) UsesKeyFile() bool {
+	_, err := os.Stat(filepath.Join(s.dir, keyFileName))
+	return err == nil
+}
+
+// Get returns the stored key of provider and where it was found.
+func (s *Store) Get(provider string) (string, string, error) {
+	index, err := s.readIndex()
+	if err != nil {
+		return "", "", err
+	}
+	entry, ok := index[provider]
+	if !ok {
+		return "", "", ErrNotFound
+	}
+	if entry.Store == StoreKeyring {
+		key, err := keyring.Get(Service, provider)
+		if errors.Is(err, keyring.ErrNotFound) {
+			return "", "", ErrNotFound
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("read keyring: %w", err)
+		}
+		return key, StoreKeyring, nil
+	}
+	c, err := s.cipher()
+	if err != nil {
+		return "", "", err
+	}
+	key, err := c.Open([]byte(entry.Key))
+	if err != nil {
+		return "", "", fmt.Errorf("decrypt credentials: %w", err)
+	}
+	return string(key), StoreFile, nil
+}
+
+// Set stores key for provider, replacing any previous key, and returns where
+// it was stored.
+func (s *Store) Set(provider string, key string) (string, error) {
+	index, err := s.readIndex()
+	if err != nil {
+		return "", err
+	}
+	entry := Entry{UpdatedAt: time.Now()}
+	var keyringErr error
+	if s.mode != StoreFile {
+		if keyringErr = keyring.Set(Service, provider, key); keyringErr == nil {
+			entry.Store = StoreKeyring
+		} else if s.mode == StoreKeyring {
+			return "", fmt.Errorf("write keyring: %w", keyringErr)
+		}
+	}
+	upgrade := entry.Store == "" && s.UsesKeyFile()
+	if entry.Store == "" {
+		c, err := s.cipher()
+		if upgrade && err == nil {
+			c, err = s.upgradeKeyFile(c, index)
+		}
+		if err != nil {
+			return "", err
+		}
+		sealed, err := c.Seal([]byte(key))
+		if err != nil {
+			return "", err
+		}
+		entry.Store = StoreFile
+		entry.Key = string(sealed)
+	}
+	if old, ok := index[provider]; ok && old.Store == StoreKeyring && entry.Store != StoreKeyring {
+		_ = keyring.Delete(Service, provider)
+	}
+	index[provider] = entry
+	if err := s.writeIndex(index); err != nil {
+		return "", err
+	}
+	if upgrade {
+		if err := os.Remove(filepath.Join(s.dir, keyFileName)); err != nil {
+			return "", err
+		}
+	}
+	return entry.Store, nil
+}
+
+// Delete removes the stored key of provider.
+func (s *Store) Delete(provider string) error {
+	index, err := s.readIndex()
+	if err != nil {
+		return err
+	}
+	entry, ok := index[provider]
+	if !ok {
+		return ErrNotFound
+	}
+	if entry.Store == StoreKeyring {
+		if err := keyring.Delete(Service, provider); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+			return fmt.Errorf("delete from keyring: %w", err)
+		}
+	}
+	delete(index, provider)
+	return s.writeIndex(index)
+}
+
+// List returns the providers with stored keys, sorted by name.
+func (s *Store) List() ([]Entry, error) {
+	index, err := s.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(index))
+	for provider, entry := range index {
+		entry.Provider = provider
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Provider < entries[j].Provider })
+	return entries, nil
+}
+
+func (s *Store) readIndex() (map[string]Entry, error) {
+	index := make(map[string]Entry)
+	payload, err := os.ReadFile(filepath.Join(s.dir, indexFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(payload, &index); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", indexFileName, err)
+	}
+	return index, nil
+}
+
+func (s *Store) writeIndex(index map[string]Entry) error {
+	payload, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(filepath.Join(s.dir, indexFileName), payload, 0o600)
+}
+
+// cipher derives the credentials file key from the passphrase, or from the
+// key file of an older version while one exists.
+func (s *Store) cipher() (*crypt.Cipher, error) {
+	secret, err := os.ReadFile(filepath.Join(s.dir, keyFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		_, statErr := os.Stat(filepath.Join(s.dir, crypt.ParamsFileName))
+		secret, err = s.readPassphrase(errors.Is(statErr, os.ErrNotExist))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return nil, err
+	}
+	c, err := crypt.LoadCipher(s.dir, secret)
+	if errors.Is(err, crypt.ErrWrongKey) {
+		return nil, errors.New("wrong credentials passphrase")
+	}
+	return c, err
+}
+
+func (s *Store) readPassphrase(creating bool) ([]byte, error) {
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	if s.passphrase == nil {
+		return nil, ErrNoPassphrase
+	}
+	return s.passphrase(creating)
+}
+
+// upgradeKeyFile re-encrypts the file entries of index, readable with old,
+// under a new passphrase and returns the new cipher. The caller saves index
+// and removes the key file.
+func (s *Store) upgradeKeyFile(old *crypt.Cipher, index map[string]Entry) (*crypt.Cipher, error) {
+	keys := make(map[string][]byte)
+	for provider, entry := range index {
+		if entry.Store != StoreFile {
+			continue
+		}
+		key, err := old.Open([]byte(entry.Key))
+		if err != nil {
+			return nil, fmt.Errorf("decrypt credentials: %w", err)
+		}
+		keys[provider] = key
+	}
+	passphrase, err := s.readPassphrase(true)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(filepath.Join(s.dir, crypt.ParamsFileName)); err != nil {
+		return nil, err
+	}
+	c, err := crypt.LoadCipher(s.dir, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	for provider, key := range keys {
+		sealed, err := c.Seal(key)
+		if err != nil {
+			return nil, err
+		}
+		entry := index[provider]
+		entry.Key = string(sealed)
+		index[provider] = entry
+	}
+	return c, nil
+}
@@ -0,0 +1,163 @@
+package credentials
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+
+	"github.com/unixsysdev/serena-cli-go/internal/crypt"
+)
+
+func TestFileStore(t *testing.T) {
+	t.Setenv(PassphraseEnv, "correct horse")
+	dir := t.TempDir()
+	store, err := Open(dir, StoreFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := store.Get("openai"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get before Set: err = %v, want ErrNotFound", err)
+	}
+	for provider, key := range map[string]string{"openai": "sk-one", "chutes": "cpk-two"} {
+		where, err := store.Set(provider, key)
+		if err != nil || where != StoreFile {
+			t.Fatalf("Set(%s) = %q, %v", provider, where, err)
+		}
+	}
+	if _, err := store.Set("openai", "sk-three"); err != nil {
+		t.Fatal(err)
+	}
+
+	key, where, err := store.Get("openai")
+	if err != nil || key != "sk-three" || where != StoreFile {
+		t.Fatalf("Get = %q, %q, %v", key, where, err)
+	}
+	index, err := os.ReadFile(filepath.Join(dir, indexFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(index), "sk-three") {
+		t.Fatal("key stored in plaintext")
+	}
+	if store.UsesKeyFile() {
+		t.Fatal("UsesKeyFile with a passphrase")
+	}
+
+	if err := store.Delete("openai"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("openai"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("second Delete: err = %v, want ErrNotFound", err)
+	}
+	entries, err := store.List()
+	if err != nil || len(entries) != 1 || entries[0].Provider != "chutes" {
+		t.Fatalf("List = %+v, %v", entries, err)
+	}
+}
+
+func TestFilePassphrase(t *testing.T) {
+	t.Setenv(PassphraseEnv, "")
+	dir := t.TempDir()
+	store, err := Open(dir, StoreFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Set("openai", "sk-one"); !errors.Is(err, ErrNoPassphrase) {
+		t.Fatalf("Set without passphrase: err = %v, want ErrNoPassphrase", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, keyFileName)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("key file created without a passphrase")
+	}
+
+	var asked []bool
+	store.SetPassphraseFunc(func(creating bool) ([]byte, error) {
+		asked = append(asked, creating)
+		return []byte("typed"), nil
+	})
+	if _, err := store.Set("openai", "sk-one"); err != nil {
+		t.Fatal(err)
+	}
+	if key, _, err := store.Get("openai"); err != nil || key != "sk-one" {
+		t.Fatalf("Get = %q, %v", key, err)
+	}
+	if len(asked) != 2 || !asked[0] || asked[1] {
+		t.Fatalf("passphrase asked with creating = %v, want [true false]", asked)
+	}
+
+	t.Setenv(PassphraseEnv, "wrong")
+	if _, _, err := store.Get("openai"); err == nil || !strings.Contains(err.Error(), "wrong credentials passphrase") {
+		t.Fatalf("Get with wrong passphrase: err = %v", err)
+	}
+}
+
+func TestKeyFileUpgrade(t *testing.T) {
+	t.Setenv(PassphraseEnv, "")
+	dir := t.TempDir()
+	// A store written by a version that generated a key file.
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	if err := os.WriteFile(filepath.Join(dir, keyFileName), secret, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old, err := crypt.LoadCipher(dir, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := old.Seal([]byte("sk-old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := Open(dir, StoreFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.writeIndex(map[string]Entry{"openai": {Store: StoreFile, Key: string(sealed)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !store.UsesKeyFile() {
+		t.Fatal("UsesKeyFile = false for a key file store")
+	}
+	if key, _, err := store.Get("openai"); err != nil || key != "sk-old" {
+		t.Fatalf("Get from key file store = %q, %v", key, err)
+	}
+
+	t.Setenv(PassphraseEnv, "correct horse")
+	if _, err := store.Set("chutes", "cpk-new"); err != nil {
+		t.Fatal(err)
+	}
+	if store.UsesKeyFile() {
+		t.Fatal("key file kept after upgrade")
+	}
+	for provider, want := range map[string]string{"openai": "sk-old", "chutes": "cpk-new"} {
+		if key, _, err := store.Get(provider); err != nil || key != want {
+			t.Fatalf("Get(%s) after upgrade = %q, %v", provider, key, err)
+		}
+	}
+}
+
+func TestKeyringStore(t *testing.T) {
+	keyring.MockInit()
+	t.Setenv(PassphraseEnv, "")
+	store, err := Open(t.TempDir(), StoreAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	where, err := store.Set("openai", "sk-one")
+	if err != nil || where != StoreKeyring {
+		t.Fatalf("Set = %q, %v", where, err)
+	}
+	if key, where, err := store.Get("openai"); err != nil || key != "sk-one" || where != StoreKeyring {
+		t.Fatalf("Get = %q, %q, %v", key, where, err)
+	}
+	if err := store.Delete("openai"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keyring.Get(Service, "openai"); !errors.Is(err, keyring.ErrNotFound) {
+		t.Fatalf("keyring entry left after Delete: %v", err)
+	}
+}
@@ -0,0 +1,279 @@
+// Package crypt encrypts data at rest with AES-256-GCM under a key derived
+// with scrypt from a passphrase or key file. Session storage and the
+// credentials file use it.
+package crypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/unixsysdev/serena-cli-go/internal/fsutil"
+)
+
+// ParamsFileName holds the key derivation parameters in the directory given
+// to LoadCipher.
+const ParamsFileName = "encryption.json"
+
+// encryptedPrefix marks a line of encrypted data: the prefix followed by
+// base64 of nonce and AES-GCM ciphertext.
+const encryptedPrefix = "serena-enc1:"
+
+// keyCheck is encrypted into the parameters file to detect a wrong
+// passphrase. Existing files depend on its value.
+const keyCheck = "serena session key"
+
+// paramsVersion is written to new parameter files. Version 1 files encrypt
+// with the scrypt output itself; from version 2 on, encryption uses a subkey
+// derived from it like the MAC key.
+const paramsVersion = 2
+
+// ErrEncrypted reports encrypted data read without a key.
+var ErrEncrypted = errors.New("data is encrypted and no key was given")
+
+// ErrWrongKey reports a passphrase or key file that does not match the one
+// the data was encrypted with.
+var ErrWrongKey = errors.New("wrong passphrase or key file")
+
+// ErrMixed reports plaintext lines in encrypted data. Anyone able to write
+// the file could have added them, so they are not trusted.
+var ErrMixed = errors.New("encrypted data contains plaintext lines")
+
+// Cipher encrypts data with AES-256-GCM. A nil Cipher passes data through
+// unchanged.
+type Cipher struct {
+	aead   cipher.AEAD
+	macKey []byte
+}
+
+// keyParams is the content of ParamsFileName.
+type keyParams struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    string `json:"salt"`
+	N       int    `json:"n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Check   string `json:"check"`
+}
+
+// LoadCipher derives the key from secret, a passphrase or the
+// contents of a key file, using the scrypt parameters stored in root. The
+// first call creates them.
+func LoadCipher(root string, secret []byte) (*Cipher, error) {
+	secret = bytes.TrimSpace(secret)
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("passphrase or key file is empty")
+	}
+	path := filepath.Join(root, ParamsFileName)
+	payload, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return createCipher(path, secret)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var params keyParams
+	if err := json.Unmarshal(payload, &params); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ParamsFileName, err)
+	}
+	if params.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported key derivation %q in %s", params.KDF, path)
+	}
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ParamsFileName, err)
+	}
+	c, err := deriveCipher(secret, salt, params)
+	if err != nil {
+		return nil, err
+	}
+	check, err := c.Open([]byte(params.Check))
+	if err != nil || string(check) != keyCheck {
+		return nil, ErrWrongKey
+	}
+	return c, nil
+}
+
+func createCipher(path string, secret []byte) (*Cipher, error) {
+	params := keyParams{Version: paramsVersion, KDF: "scrypt", N: 1 << 15, R: 8, P: 1}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	params.Salt = hex.EncodeToString(salt)
+	c, err := deriveCipher(secret, salt, params)
+	if err != nil {
+		return nil, err
+	}
+	check, err := c.Seal([]byte(keyCheck))
+	if err != nil {
+		return nil, err
+	}
+	params.Check = string(check)
+	payload, err := json.MarshalIndent(params, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := fsutil.WriteFileAtomic(path, payload, 0o600); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// deriveCipher derives the master key with scrypt and separate encryption
+// and MAC subkeys from it with HKDF.
+func deriveCipher(secret []byte, salt []byte, params keyParams) (*Cipher, error) {
+	master, err := scrypt.Key(secret, salt, params.N, params.R, params.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %w", err)
+	}
+	encKey := master
+	if params.Version >= 2 {
+		if encKey, err = subkey(master, "serena encryption"); err != nil {
+			return nil, err
+		}
+	}
+	macKey, err := subkey(master, "serena mac")
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead, macKey: macKey}, nil
+}
+
+func subkey(master []byte, purpose string) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, nil, []byte(purpose)), key); err != nil {
+		return nil, fmt.Errorf("derive %s key: %w", purpose, err)
+	}
+	return key, nil
+}
+
+// Seal encrypts data into a single line.
+func (c *Cipher) Seal(data []byte) ([]byte, error) {
+	if c == nil {
+		return data, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, data, nil)
+	out := make([]byte, len(encryptedPrefix)+base64.StdEncoding.EncodedLen(len(sealed)))
+	copy(out, encryptedPrefix)
+	base64.StdEncoding.Encode(out[len(encryptedPrefix):], sealed)
+	return out, nil
+}
+
+// SealLines encrypts each non-empty line of data separately, so encrypted
+// JSONL can still be appended to.
+func (c *Cipher) SealLines(data []byte) ([]byte, error) {
+	if c == nil {
+		return data, nil
+	}
+	var out bytes.Buffer
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		body := bytes.TrimSuffix(line, []byte("\n"))
+		if len(body) > 0 {
+			sealed, err := c.Seal(body)
+			if err != nil {
+				return nil, err
+			}
+			out.Write(sealed)
+		}
+		if len(body) < len(line) {
+			out.WriteByte('\n')
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// Open decrypts every line of data. Data without encrypted lines, written
+// before encryption was enabled, is returned as it is; once data is
+// encrypted, a plaintext line among the encrypted ones fails with ErrMixed.
+func (c *Cipher) Open(data []byte) ([]byte, error) {
+	return c.open(data, false)
+}
+
+// OpenMixed is Open that passes plaintext lines between encrypted ones
+// through. It is only for migrating files that older versions appended
+// encrypted lines to; what it returns should be encrypted again.
+func (c *Cipher) OpenMixed(data []byte) ([]byte, error) {
+	return c.open(data, true)
+}
+
+func (c *Cipher) open(data []byte, mixed bool) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	var out bytes.Buffer
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		body := bytes.TrimSuffix(line, []byte("\n"))
+		if !bytes.HasPrefix(body, []byte(encryptedPrefix)) {
+			if !mixed && len(bytes.TrimSpace(body)) > 0 {
+				return nil, ErrMixed
+			}
+			out.Write(line)
+			continue
+		}
+		if c == nil {
+			return nil, ErrEncrypted
+		}
+		sealed, err := base64.StdEncoding.DecodeString(string(body[len(encryptedPrefix):]))
+		if err != nil || len(sealed) < c.aead.NonceSize() {
+			return nil, fmt.Errorf("corrupt encrypted data")
+		}
+		nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+		plain, err := c.aead.Open(nil, nonce, ciphertext, nil)
+		if err != nil {
+			return nil, ErrWrongKey
+		}
+		out.Write(plain)
+		if len(body) < len(line) {
+			out.WriteByte('\n')
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// Hash returns a content hash for change detection; with a key it is an
+// HMAC so stored hashes reveal nothing about encrypted content.
+func (c *Cipher) Hash(data []byte) string {
+	if c == nil {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, c.macKey)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsEncrypted reports whether data contains encrypted lines.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedPrefix)) || bytes.Contains(data, []byte("\n"+encryptedPrefix))
+}
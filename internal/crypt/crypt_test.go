@@ -0,0 +1,243 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+func TestCipherRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	c, err := LoadCipher(root, []byte("correct horse\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		plain string
+		lines bool
+	}{
+		{"empty", "", false},
+		{"text", "hello world", false},
+		{"multi-line sealed whole", "line one\nline two\n", false},
+		{"lines", "{\"a\":1}\n{\"b\":2}\n", true},
+		{"lines without final newline", "{\"a\":1}\n\n{\"b\":2}", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sealed []byte
+			if tt.lines {
+				sealed, err = c.SealLines([]byte(tt.plain))
+			} else {
+				sealed, err = c.Seal([]byte(tt.plain))
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.plain != "" && bytes.Contains(sealed, []byte(strings.Fields(tt.plain)[0])) {
+				t.Errorf("sealed data contains plaintext: %q", sealed)
+			}
+			if !tt.lines && bytes.Contains(sealed, []byte("\n")) {
+				t.Errorf("Seal output spans lines: %q", sealed)
+			}
+			opened, err := c.Open(sealed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.plain != "" && string(opened) != tt.plain {
+				t.Errorf("Open = %q, want %q", opened, tt.plain)
+			}
+		})
+	}
+
+	again, err := LoadCipher(root, []byte("correct horse"))
+	if err != nil {
+		t.Fatalf("reload with same secret: %v", err)
+	}
+	sealed, _ := c.Seal([]byte("shared"))
+	if opened, err := again.Open(sealed); err != nil || string(opened) != "shared" {
+		t.Errorf("reloaded cipher Open = %q, %v", opened, err)
+	}
+}
+
+func TestCipherOpenErrors(t *testing.T) {
+	root := t.TempDir()
+	c, err := LoadCipher(root, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := c.Seal([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(string(sealed[len(encryptedPrefix):]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[len(raw)-1] ^= 1
+	tampered := []byte(encryptedPrefix + base64.StdEncoding.EncodeToString(raw))
+
+	tests := []struct {
+		name   string
+		cipher *Cipher
+		data   []byte
+		want   error
+	}{
+		{"no key", nil, sealed, ErrEncrypted},
+		{"tampered", c, tampered, ErrWrongKey},
+		{"corrupt", c, []byte(encryptedPrefix + "!!!"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.cipher.Open(tt.data)
+			if err == nil {
+				t.Fatal("Open succeeded")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Open error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := LoadCipher(root, []byte("other")); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("LoadCipher with wrong secret = %v, want ErrWrongKey", err)
+	}
+	if _, err := LoadCipher(root, []byte("  \n")); err == nil {
+		t.Error("LoadCipher accepted an empty secret")
+	}
+}
+
+func TestNilCipherPassesThrough(t *testing.T) {
+	var c *Cipher
+	for _, plain := range []string{"", "plain text", "a\nb\n"} {
+		sealed, err := c.Seal([]byte(plain))
+		if err != nil || string(sealed) != plain {
+			t.Errorf("Seal(%q) = %q, %v", plain, sealed, err)
+		}
+		lines, err := c.SealLines([]byte(plain))
+		if err != nil || string(lines) != plain {
+			t.Errorf("SealLines(%q) = %q, %v", plain, lines, err)
+		}
+		opened, err := c.Open([]byte(plain))
+		if err != nil || string(opened) != plain {
+			t.Errorf("Open(%q) = %q, %v", plain, opened, err)
+		}
+	}
+}
+
+func TestOpenMixedPlaintext(t *testing.T) {
+	c, err := LoadCipher(t.TempDir(), []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := c.SealLines([]byte("{\"new\":1}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mixed := append([]byte("{\"old\":1}\n"), sealed...)
+	if !IsEncrypted(mixed) {
+		t.Fatal("IsEncrypted = false for appended encrypted line")
+	}
+	forged := append(append([]byte{}, sealed...), "{\"forged\":1}\n"...)
+	for _, data := range [][]byte{mixed, forged} {
+		if _, err := c.Open(data); !errors.Is(err, ErrMixed) {
+			t.Errorf("Open(%q): err = %v, want ErrMixed", data, err)
+		}
+	}
+	opened, err := c.OpenMixed(mixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"old\":1}\n{\"new\":1}\n"; string(opened) != want {
+		t.Errorf("OpenMixed = %q, want %q", opened, want)
+	}
+}
+
+func TestCipherHash(t *testing.T) {
+	c, err := LoadCipher(t.TempDir(), []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plain *Cipher
+	if c.Hash([]byte("a")) != c.Hash([]byte("a")) {
+		t.Error("keyed hash is not deterministic")
+	}
+	if c.Hash([]byte("a")) == c.Hash([]byte("b")) {
+		t.Error("keyed hash ignores content")
+	}
+	if c.Hash([]byte("a")) == plain.Hash([]byte("a")) {
+		t.Error("keyed hash equals plain SHA-256")
+	}
+}
+
+func TestVersion1Params(t *testing.T) {
+	root := t.TempDir()
+	secret := []byte("secret")
+	params := keyParams{Version: 1, KDF: "scrypt", N: 1 << 10, R: 8, P: 1, Salt: "00112233445566778899aabbccddeeff"}
+	salt, _ := hex.DecodeString(params.Salt)
+	legacy, err := deriveCipher(secret, salt, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check, err := legacy.Seal([]byte(keyCheck))
+	if err != nil {
+		t.Fatal(err)
+	}
+	params.Check = string(check)
+	payload, _ := json.Marshal(params)
+	if err := os.WriteFile(filepath.Join(root, ParamsFileName), payload, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := legacy.Seal([]byte("old data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := LoadCipher(root, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened, err := c.Open(sealed); err != nil || string(opened) != "old data" {
+		t.Fatalf("Open with version 1 params = %q, %v", opened, err)
+	}
+
+	// The MAC key differs from the encryption key, which for version 1 is
+	// the scrypt output.
+	master, err := scrypt.Key(secret, salt, params.N, params.R, params.P, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha256.New, master)
+	mac.Write([]byte("a"))
+	if c.Hash([]byte("a")) == hex.EncodeToString(mac.Sum(nil)) {
+		t.Error("Hash uses the encryption key")
+	}
+}
+
+func TestNewParamsVersion(t *testing.T) {
+	root := t.TempDir()
+	if _, err := LoadCipher(root, []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := os.ReadFile(filepath.Join(root, ParamsFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var params keyParams
+	if err := json.Unmarshal(payload, &params); err != nil {
+		t.Fatal(err)
+	}
+	if params.Version != paramsVersion {
+		t.Errorf("params version = %d, want %d", params.Version, paramsVersion)
+	}
+}
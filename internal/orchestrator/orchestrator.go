@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"path"
 	"regexp"
 	"strings"
 	"time"
@@ -14,6 +15,8 @@ import (
 	"github.com/unixsysdev/serena-cli-go/internal/MCP"
 	"github.com/unixsysdev/serena-cli-go/internal/config"
 	"github.com/unixsysdev/serena-cli-go/internal/llm"
+	"github.com/unixsysdev/serena-cli-go/internal/redact"
+	"github.com/unixsysdev/serena-cli-go/internal/workspace"
 )
 
 // Orchestrator manages the interaction between the LLM and Serena MCP.
@@ -22,9 +25,20 @@ type Orchestrator struct {
 	llm      *llm.Client
 	mcp      *MCP.Client
 	messages []openai.ChatCompletionMessage
+	contexts []ContextAttachment
+	pending  []ContextAttachment
+	changes  *workspace.Tracker
 	tools    []openai.Tool
 	events   *EventHandler
 	local    map[string]LocalToolHandler
+
+	// redactor removes secrets from everything sent to the LLM; nil when
+	// redaction is disabled. redactions collects what it replaced.
+	redactor   *redact.Redactor
+	redactions []Redaction
+
+	// lastModel is the model used by the most recent turn.
+	lastModel string
 }
 
 // EventHandler allows callers to observe progress and tool usage.
@@ -51,10 +65,16 @@ func New(cfg *config.Config) (*Orchestrator, error) {
 		return nil, fmt.Errorf("failed to create MCP client: %w", err)
 	}
 
+	redactor, err := newRedactor(cfg.Redaction)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Orchestrator{
-		config: cfg,
-		llm:    llmClient,
-		mcp:    mcpClient,
+		config:   cfg,
+		llm:      llmClient,
+		mcp:      mcpClient,
+		redactor: redactor,
 	}, nil
 }
 
@@ -168,19 +188,60 @@ func toolingGuidance() string {
 - When a tool is needed, respond with tool calls and wait for results before final answers.`
 }
 
+// TurnOptions adjusts a single Chat call without changing orchestrator state.
+type TurnOptions struct {
+	// Model overrides the active model for this turn only.
+	Model string
+	// AllowedTools restricts the tools offered to the model. Entries may be
+	// path.Match patterns such as "find_*". Empty means all tools.
+	AllowedTools []string
+	// Attachments are included with the user message for this turn.
+	Attachments []ContextAttachment
+}
+
+// ContextAttachment is labelled content attached to a single user message.
+type ContextAttachment struct {
+	Label   string
+	Content string
+}
+
 // Chat processes a user message and returns the response
 func (o *Orchestrator) Chat(ctx context.Context, userMsg string) (string, error) {
+	return o.ChatWithOptions(ctx, userMsg, TurnOptions{})
+}
+
+// ChatWithOptions processes a user message using per-turn options.
+func (o *Orchestrator) ChatWithOptions(ctx context.Context, userMsg string, opts TurnOptions) (string, error) {
+	model := o.llm.Model()
+	if opts.Model != "" {
+		model = opts.Model
+	}
+	o.lastModel = model
+	tools := o.turnTools(opts.AllowedTools)
+	if o.changes != nil {
+		if err := o.changes.BeginTurn(); err != nil && o.config.Debug {
+			fmt.Printf("Working tree snapshot failed: %v\n", err)
+		}
+		defer func() {
+			if err := o.changes.EndTurn(); err != nil && o.config.Debug {
+				fmt.Printf("Working tree snapshot failed: %v\n", err)
+			}
+		}()
+	}
+	attachments := o.redactAttachments(append(o.pending, opts.Attachments...), "attachment")
+	o.pending = nil
+
 	// Add user message
 	o.messages = append(o.messages, openai.ChatCompletionMessage{
 		Role:    openai.ChatMessageRoleUser,
-		Content: wrapUserTask(userMsg),
+		Content: wrapUserTask(o.Redact(userMsg, "prompt"), attachments),
 	})
 
-	o.emitStatus(fmt.Sprintf("thinking (model=%s)", o.llm.Model()))
+	o.emitStatus(fmt.Sprintf("thinking (model=%s)", model))
 
 	if o.config.Debug {
 		fmt.Printf("\n=== Sending to LLM ===\nUser: %s\n=====================\n\n", userMsg)
-		fmt.Printf("LLM request start (messages=%d, tools=%d)\n", len(o.messages), len(o.tools))
+		fmt.Printf("LLM request start (messages=%d, tools=%d)\n", len(o.messages), len(tools))
 	}
 
 	// Call LLM with tools
@@ -188,7 +249,7 @@ func (o *Orchestrator) Chat(ctx context.Context, userMsg string) (string, error)
 	if cancel != nil {
 		defer cancel()
 	}
-	content, toolCalls, err := o.llm.Chat(llmCtx, o.messages, o.tools)
+	content, toolCalls, err := o.llm.ChatWithModel(llmCtx, model, o.requestMessages(), tools)
 	if err != nil {
 		return "", fmt.Errorf("LLM chat failed: %w", err)
 	}
@@ -224,10 +285,18 @@ func (o *Orchestrator) Chat(ctx context.Context, userMsg string) (string, error)
 				fmt.Printf("Waiting for tool response: %s\n", toolCall.Function.Name)
 			}
 
-			result, isError, err := o.executeToolCall(ctx, toolCall)
-			if err != nil {
-				return "", fmt.Errorf("tool execution failed: %w", err)
+			var result string
+			var isError bool
+			if toolAllowed(toolCall.Function.Name, opts.AllowedTools) {
+				result, isError, err = o.executeToolCall(ctx, toolCall)
+				if err != nil {
+					return "", fmt.Errorf("tool execution failed: %w", err)
+				}
+			} else {
+				result = fmt.Sprintf("Error: tool %q is not allowed for this request.", toolCall.Function.Name)
+				isError = true
 			}
+			result = o.Redact(result, "tool "+toolCall.Function.Name)
 
 			o.emitToolEnd(toolCall.Function.Name, result, isError)
 
@@ -245,17 +314,17 @@ func (o *Orchestrator) Chat(ctx context.Context, userMsg string) (string, error)
 
 		if o.config.Debug {
 			fmt.Printf("=== Calling LLM Again with Tool Results ===\n")
-			fmt.Printf("LLM request start (messages=%d, tools=%d)\n", len(o.messages), len(o.tools))
+			fmt.Printf("LLM request start (messages=%d, tools=%d)\n", len(o.messages), len(tools))
 		}
 
-		o.emitStatus(fmt.Sprintf("thinking (model=%s)", o.llm.Model()))
+		o.emitStatus(fmt.Sprintf("thinking (model=%s)", model))
 
 		// Call LLM again with tool results
 		llmCtx, cancel := o.llmCallContext(ctx)
 		if cancel != nil {
 			defer cancel()
 		}
-		content, toolCalls, err = o.llm.ChatWithOptions(llmCtx, o.llm.Model(), o.messages, o.tools, "auto")
+		content, toolCalls, err = o.llm.ChatWithOptions(llmCtx, model, o.requestMessages(), tools, "auto")
 		if err != nil {
 			return "", fmt.Errorf("LLM chat with tool results failed: %w", err)
 		}
@@ -278,11 +347,49 @@ func (o *Orchestrator) Chat(ctx context.Context, userMsg string) (string, error)
 	return content, nil
 }
 
+// turnTools returns the tool definitions permitted by allowed.
+func (o *Orchestrator) turnTools(allowed []string) []openai.Tool {
+	if len(allowed) == 0 {
+		return o.tools
+	}
+	tools := make([]openai.Tool, 0, len(o.tools))
+	for _, tool := range o.tools {
+		if tool.Function != nil && toolAllowed(tool.Function.Name, allowed) {
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
+
+func toolAllowed(name string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, pattern := range allowed {
+		if pattern == name {
+			return true
+		}
+		if ok, err := path.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
 // Model returns the active model name.
 func (o *Orchestrator) Model() string {
 	return o.llm.Model()
 }
 
+// LastTurnModel returns the model used by the most recent turn, which differs
+// from Model when the turn overrode it.
+func (o *Orchestrator) LastTurnModel() string {
+	if o.lastModel != "" {
+		return o.lastModel
+	}
+	return o.Model()
+}
+
 // SetModel updates the active model.
 func (o *Orchestrator) SetModel(model string) {
 	o.config.LLM.Model = model
@@ -311,21 +418,58 @@ func (o *Orchestrator) Messages() []openai.ChatCompletionMessage {
 	return messages
 }
 
-// ReplaceMessages replaces the current conversation messages.
+// ReplaceMessages replaces the current conversation messages, redacting
+// any secrets they still contain.
 func (o *Orchestrator) ReplaceMessages(messages []openai.ChatCompletionMessage) {
-	o.messages = messages
+	o.messages = o.redactMessages(messages, "history")
 }
 
-// AddContext appends extra context as a system message.
-func (o *Orchestrator) AddContext(label string, content string) {
-	if strings.TrimSpace(content) == "" {
-		return
+// SetContexts replaces the attached context sources. They are sent as system
+// messages after the system prompt and survive resets and compaction.
+func (o *Orchestrator) SetContexts(contexts []ContextAttachment) {
+	o.contexts = o.redactAttachments(contexts, "context")
+}
+
+// SetChangeTracker enables working tree snapshots before and after each turn.
+func (o *Orchestrator) SetChangeTracker(tracker *workspace.Tracker) {
+	o.changes = tracker
+}
+
+// ChangeTracker returns the working tree tracker, or nil when disabled.
+func (o *Orchestrator) ChangeTracker() *workspace.Tracker {
+	return o.changes
+}
+
+// AttachNext queues an attachment to be sent with the next chat turn.
+func (o *Orchestrator) AttachNext(attachment ContextAttachment) {
+	o.pending = append(o.pending, attachment)
+}
+
+// PendingAttachments returns the attachments queued for the next turn.
+func (o *Orchestrator) PendingAttachments() []ContextAttachment {
+	return append([]ContextAttachment(nil), o.pending...)
+}
+
+// requestMessages returns the conversation with attached context inserted
+// after the system prompt.
+func (o *Orchestrator) requestMessages() []openai.ChatCompletionMessage {
+	if len(o.contexts) == 0 || len(o.messages) == 0 {
+		return o.messages
 	}
-	msg := fmt.Sprintf("<context source=%q>\n%s\n</context>", label, strings.TrimSpace(content))
-	o.messages = append(o.messages, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleSystem,
-		Content: msg,
-	})
+	messages := make([]openai.ChatCompletionMessage, 0, len(o.messages)+len(o.contexts))
+	messages = append(messages, o.messages[0])
+	for _, item := range o.contexts {
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: formatContext(item.Label, item.Content),
+		})
+	}
+	return append(messages, o.messages[1:]...)
+}
+
+// formatContext wraps content in a labelled context block.
+func formatContext(label string, content string) string {
+	return fmt.Sprintf("<context source=%q>\n%s\n</context>", label, strings.TrimSpace(content))
 }
 
 // Tools returns the currently loaded tool definitions.
@@ -345,11 +489,12 @@ type ConversationStats struct {
 
 // ConversationStats returns approximate context usage based on messages and tool calls.
 func (o *Orchestrator) ConversationStats() ConversationStats {
+	messages := o.requestMessages()
 	stats := ConversationStats{
-		MessageCount: len(o.messages),
+		MessageCount: len(messages),
 	}
 
-	for _, msg := range o.messages {
+	for _, msg := range messages {
 		stats.CharCount += len(msg.Content)
 		if len(msg.ToolCalls) > 0 {
 			stats.ToolCallCount += len(msg.ToolCalls)
@@ -372,6 +517,19 @@ func (o *Orchestrator) Summarize(ctx context.Context, text string) (string, erro
 	system := "Summarize the conversation content into a concise, structured summary. " +
 		"Preserve key requirements, decisions, file paths, commands, and open questions. " +
 		"Use bullets where helpful."
+	return o.compactionChat(ctx, "summarize", system, text)
+}
+
+// CommitMessage writes a git commit message for a turn using the compaction model.
+func (o *Orchestrator) CommitMessage(ctx context.Context, text string) (string, error) {
+	system := "Write a git commit message for the changes described below. " +
+		"Use an imperative subject line of at most 72 characters, then a blank line " +
+		"and a few short bullet points if the change needs explaining. " +
+		"Reply with the commit message only."
+	return o.compactionChat(ctx, "commit message", system, text)
+}
+
+func (o *Orchestrator) compactionChat(ctx context.Context, label string, system string, text string) (string, error) {
 	messages := []openai.ChatCompletionMessage{
 		{
 			Role:    openai.ChatMessageRoleSystem,
@@ -379,13 +537,13 @@ func (o *Orchestrator) Summarize(ctx context.Context, text string) (string, erro
 		},
 		{
 			Role:    openai.ChatMessageRoleUser,
-			Content: text,
+			Content: o.Redact(text, label),
 		},
 	}
 
 	model := o.config.LLM.CompactionModel
 	if o.config.Debug {
-		fmt.Printf("Compaction summarize start (model=%s, chars=%d)\n", model, len(text))
+		fmt.Printf("Compaction %s start (model=%s, chars=%d)\n", label, model, len(text))
 	}
 	llmCtx, cancel := o.llmCallContext(ctx)
 	if cancel != nil {
@@ -396,7 +554,7 @@ func (o *Orchestrator) Summarize(ctx context.Context, text string) (string, erro
 		return "", err
 	}
 	if o.config.Debug {
-		fmt.Printf("Compaction summarize done (chars=%d)\n", len(content))
+		fmt.Printf("Compaction %s done (chars=%d)\n", label, len(content))
 	}
 
 	return stripThinkTags(content), nil
@@ -438,15 +596,43 @@ func formatToolArgs(raw string) string {
 	return truncateString(oneLine, 160)
 }
 
-func wrapUserTask(userMsg string) string {
+// UserRequest returns the prompt typed by the user from a user message built
+// by Chat, without the task wrapper and attachments.
+func UserRequest(content string) string {
+	const open, close = "<request>\n", "\n</request>"
+	start := strings.Index(content, open)
+	if !strings.HasPrefix(content, "<task>") || start < 0 {
+		return content
+	}
+	rest := content[start+len(open):]
+	end := strings.LastIndex(rest, close)
+	if end < 0 {
+		return content
+	}
+	return rest[:end]
+}
+
+func wrapUserTask(userMsg string, attachments []ContextAttachment) string {
 	trimmed := strings.TrimSpace(userMsg)
-	if trimmed == "" {
+	if trimmed == "" && len(attachments) == 0 {
 		return "<task></task>"
 	}
 
+	if len(attachments) == 0 {
+		return fmt.Sprintf(
+			"<task>\n<request>\n%s\n</request>\n</task>",
+			trimmed,
+		)
+	}
+
+	var b strings.Builder
+	for _, attachment := range attachments {
+		b.WriteString(formatContext(attachment.Label, attachment.Content) + "\n")
+	}
 	return fmt.Sprintf(
-		"<task>\n<request>\n%s\n</request>\n</task>",
+		"<task>\n<request>\n%s\n</request>\n<attachments>\n%s</attachments>\n</task>",
 		trimmed,
+		b.String(),
 	)
 }
 
@@ -0,0 +1,91 @@
+package orchestrator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/unixsysdev/serena-cli-go/internal/config"
+	"github.com/unixsysdev/serena-cli-go/internal/redact"
+)
+
+// Redaction reports secrets one detector replaced in content from source,
+// such as "prompt" or "tool read_file".
+type Redaction struct {
+	Time     time.Time
+	Detector string
+	Source   string
+	Count    int
+}
+
+// newRedactor builds the redactor configured by cfg, or nil when redaction
+// is disabled.
+func newRedactor(cfg config.RedactionConfig) (*redact.Redactor, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	opts := redact.Options{Entropy: cfg.Entropy}
+	for _, pattern := range cfg.Patterns {
+		opts.Patterns = append(opts.Patterns, redact.Pattern{Name: pattern.Name, Regex: pattern.Regex})
+	}
+	return redact.New(opts)
+}
+
+// Redact replaces secrets in text and records what was replaced for
+// TakeRedactions. It returns text unchanged when redaction is disabled.
+func (o *Orchestrator) Redact(text string, source string) string {
+	redacted, matches := o.redactor.Redact(text)
+	now := time.Now()
+	for _, match := range matches {
+		o.redactions = append(o.redactions, Redaction{
+			Time:     now,
+			Detector: match.Detector,
+			Source:   source,
+			Count:    match.Count,
+		})
+	}
+	return redacted
+}
+
+// TakeRedactions returns the redactions made since the last call.
+func (o *Orchestrator) TakeRedactions() []Redaction {
+	redactions := o.redactions
+	o.redactions = nil
+	return redactions
+}
+
+// redactAttachments redacts the content of each attachment, recording the
+// source as kind followed by the label.
+func (o *Orchestrator) redactAttachments(attachments []ContextAttachment, kind string) []ContextAttachment {
+	redacted := make([]ContextAttachment, len(attachments))
+	for i, attachment := range attachments {
+		redacted[i] = ContextAttachment{
+			Label:   attachment.Label,
+			Content: o.Redact(attachment.Content, kind+" "+attachment.Label),
+		}
+	}
+	return redacted
+}
+
+// redactMessages returns a copy of messages with content and tool call
+// arguments redacted, for conversations loaded from sessions saved before
+// redaction. The caller's slice is left untouched.
+func (o *Orchestrator) redactMessages(messages []openai.ChatCompletionMessage, source string) []openai.ChatCompletionMessage {
+	messages = append([]openai.ChatCompletionMessage(nil), messages...)
+	for i := range messages {
+		if messages[i].Role == openai.ChatMessageRoleSystem && i == 0 {
+			continue
+		}
+		messages[i].Content = o.Redact(messages[i].Content, fmt.Sprintf("%s %s", source, messages[i].Role))
+		if len(messages[i].ToolCalls) == 0 {
+			continue
+		}
+		calls := make([]openai.ToolCall, len(messages[i].ToolCalls))
+		copy(calls, messages[i].ToolCalls)
+		for j := range calls {
+			calls[j].Function.Arguments = o.Redact(calls[j].Function.Arguments, source+" tool call "+calls[j].Function.Name)
+		}
+		messages[i].ToolCalls = calls
+	}
+	return messages
+}
@@ -0,0 +1,257 @@
+// Package redact finds secrets such as API keys, private keys and tokens in
+// text and replaces them with placeholders.
+package redact
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Detector finds one kind of secret. When Pattern has a group named
+// "secret" only that group is replaced, so "API_KEY=..." keeps its name.
+// Valid, when set, rejects matches that are not secrets after all.
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Valid   func(secret string) bool
+}
+
+// Builtin detectors, applied in order.
+var Builtin = []Detector{
+	{Name: "private-key", Pattern: regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----(?:.*?-----END [A-Z ]*PRIVATE KEY-----|[A-Za-z0-9+/=\s\\]*)`)},
+	{Name: "jwt", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{Name: "openai-key", Pattern: regexp.MustCompile(`\bsk-(?:proj-|ant-|live-)?[A-Za-z0-9_-]{20,}`)},
+	{Name: "github-token", Pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{30,}|github_pat_[A-Za-z0-9_]{22,})`)},
+	{Name: "slack-token", Pattern: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{Name: "google-api-key", Pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`)},
+	{Name: "aws-access-key", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{Name: "aws-secret-key", Pattern: regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?(?P<secret>[A-Za-z0-9/+=]{40})`)},
+	{Name: "bearer-token", Pattern: regexp.MustCompile(`(?i)\bbearer\s+(?P<secret>[A-Za-z0-9._~+/-]{16,}=*)`)},
+	// Assignments to secret-looking names. Code such as "token := getToken()"
+	// or "password = cfg.Password" is left alone.
+	{Name: "secret-assignment", Pattern: regexp.MustCompile(`(?im)\b[\w.-]*(?:api[_-]?key|secret|token|passw(?:or)?d|credentials?)[\w.-]*["']?\s*[:=]\s*(?:"(?P<secret>[^"\s\[\]]{8,})"|'(?P<secret>[^'\s\[\]]{8,})'|(?P<secret>[^\s"'(){}\[\],;]{8,})(?:\s|$))`), Valid: assignedSecret},
+}
+
+// notSecret matches values that are assigned to secret-looking names all the
+// time without being secrets: identifiers and field references such as
+// cfg.Password, variables, numbers such as token counts, and URLs and paths
+// without credentials.
+var notSecret = regexp.MustCompile(`^(?:\$\S*|[A-Za-z_][A-Za-z_.-]*|[0-9][0-9.,_:]*|[A-Za-z][A-Za-z0-9+.-]*://[^@\s]*|~?\.{0,2}/\S*)$`)
+
+func assignedSecret(secret string) bool {
+	return !notSecret.MatchString(secret)
+}
+
+// High-entropy detection considers runs of token characters at least
+// minEntropyLength long, with upper case, lower case and digits, whose
+// Shannon entropy reaches minEntropy bits per character. Hex strings such as
+// commit hashes stay below it. Path and URL segments, which candidates next
+// to a "/" are, and checksums such as the h1: hashes in go.sum are skipped.
+const (
+	entropyDetector  = "high-entropy"
+	minEntropyLength = 32
+	minEntropy       = 4.3
+)
+
+var (
+	entropyCandidate = regexp.MustCompile(`[A-Za-z0-9+_=-]{32,}`)
+	checksum         = regexp.MustCompile(`(?i)\b(?:h1:|sha(?:1|224|256|384|512)[:-])[A-Za-z0-9+/=]+`)
+)
+
+// Pattern is a custom detector given as a regular expression.
+type Pattern struct {
+	Name  string
+	Regex string
+}
+
+// Options configures a Redactor.
+type Options struct {
+	// Patterns are custom detectors applied after the built-in ones.
+	Patterns []Pattern
+	// Entropy enables high-entropy string detection. It catches secrets of
+	// unknown formats but also some random-looking identifiers, so it is off
+	// by default.
+	Entropy bool
+}
+
+// Redactor replaces secrets found by its detectors.
+type Redactor struct {
+	detectors []Detector
+	entropy   bool
+}
+
+// Match counts the secrets one detector replaced.
+type Match struct {
+	Detector string
+	Count    int
+}
+
+// New returns a redactor using the built-in detectors and opts.
+func New(opts Options) (*Redactor, error) {
+	r := &Redactor{detectors: append([]Detector(nil), Builtin...), entropy: opts.Entropy}
+	for i, pattern := range opts.Patterns {
+		re, err := regexp.Compile(pattern.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("redaction pattern %q: %w", pattern.Name, err)
+		}
+		name := pattern.Name
+		if name == "" {
+			name = fmt.Sprintf("custom-%d", i+1)
+		}
+		r.detectors = append(r.detectors, Detector{Name: name, Pattern: re})
+	}
+	return r, nil
+}
+
+// Default returns a redactor with the built-in detectors.
+func Default() *Redactor {
+	r, _ := New(Options{})
+	return r
+}
+
+// Placeholder is the text that replaces a secret found by detector.
+func Placeholder(detector string) string {
+	return "[REDACTED:" + detector + "]"
+}
+
+// Redact replaces every secret in text and reports what was replaced, one
+// Match per detector that fired. A nil Redactor returns text unchanged.
+func (r *Redactor) Redact(text string) (string, []Match) {
+	if r == nil || text == "" {
+		return text, nil
+	}
+	counts := make(map[string]int)
+	for _, detector := range r.detectors {
+		var n int
+		text, n = replace(text, detector)
+		counts[detector.Name] += n
+	}
+	if r.entropy {
+		var n int
+		text, n = replaceEntropy(text)
+		counts[entropyDetector] += n
+	}
+
+	var matches []Match
+	for name, count := range counts {
+		if count > 0 {
+			matches = append(matches, Match{Detector: name, Count: count})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Detector < matches[j].Detector })
+	return text, matches
+}
+
+// Contains reports whether text holds anything Redact would replace.
+func (r *Redactor) Contains(text string) bool {
+	_, matches := r.Redact(text)
+	return len(matches) > 0
+}
+
+// replace substitutes detector matches in text, or only their "secret"
+// groups, returning the number replaced.
+func replace(text string, detector Detector) (string, int) {
+	locs := detector.Pattern.FindAllStringSubmatchIndex(text, -1)
+	if len(locs) == 0 {
+		return text, 0
+	}
+	var groups []int
+	for i, name := range detector.Pattern.SubexpNames() {
+		if name == "secret" {
+			groups = append(groups, i)
+		}
+	}
+
+	var b strings.Builder
+	last, count := 0, 0
+	for _, loc := range locs {
+		start, end := loc[0], loc[1]
+		for _, group := range groups {
+			if loc[2*group] >= 0 {
+				start, end = loc[2*group], loc[2*group+1]
+				break
+			}
+		}
+		if detector.Valid != nil && !detector.Valid(text[start:end]) {
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(Placeholder(detector.Name))
+		last = end
+		count++
+	}
+	b.WriteString(text[last:])
+	return b.String(), count
+}
+
+// replaceEntropy substitutes high-entropy candidates in text, returning the
+// number replaced.
+func replaceEntropy(text string) (string, int) {
+	checksums := checksum.FindAllStringIndex(text, -1)
+	var b strings.Builder
+	last, count := 0, 0
+	for _, loc := range entropyCandidate.FindAllStringIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		if !highEntropy(text[start:end]) || pathSegment(text, start, end) || overlaps(checksums, start, end) {
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(Placeholder(entropyDetector))
+		last = end
+		count++
+	}
+	if count == 0 {
+		return text, 0
+	}
+	b.WriteString(text[last:])
+	return b.String(), count
+}
+
+func pathSegment(text string, start int, end int) bool {
+	return (start > 0 && text[start-1] == '/') || (end < len(text) && text[end] == '/')
+}
+
+func overlaps(ranges [][]int, start int, end int) bool {
+	for _, r := range ranges {
+		if r[0] < end && start < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func highEntropy(candidate string) bool {
+	if len(candidate) < minEntropyLength {
+		return false
+	}
+	var upper, lower, digit bool
+	for _, r := range candidate {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= '0' && r <= '9':
+			digit = true
+		}
+	}
+	return upper && lower && digit && entropy(candidate) >= minEntropy
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	freq := make(map[rune]int)
+	for _, r := range s {
+		freq[r]++
+	}
+	n := float64(len(s))
+	var bits float64
+	for _, count := range freq {
+		p := float64(count) / n
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
@@ -0,0 +1,296 @@
+// Package search ranks session messages and archive text for a query with
+// BM25 over an in-memory inverted index.
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Source values for Document.
+const (
+	SourceMessage = "message"
+	SourceArchive = "archive"
+)
+
+// Document is one searchable piece of a session.
+type Document struct {
+	Session string
+	// Turn is the 1-based user turn the text belongs to, or 0 when unknown.
+	Turn   int
+	Source string
+	Role   string
+	// Tools names the tools called by an assistant message, or the tool that
+	// produced a tool result.
+	Tools []string
+	// Epoch is the archive epoch of archived messages.
+	Epoch int
+	// Label describes where archived text came from.
+	Label string
+	Text  string
+}
+
+// Filter restricts search results. Empty fields match everything.
+type Filter struct {
+	Session string
+	Role    string
+	Tool    string
+}
+
+func (f Filter) matches(doc Document) bool {
+	if f.Session != "" && !strings.EqualFold(doc.Session, f.Session) {
+		return false
+	}
+	if f.Role != "" && !strings.EqualFold(doc.Role, f.Role) {
+		return false
+	}
+	if f.Tool == "" {
+		return true
+	}
+	for _, tool := range doc.Tools {
+		if strings.EqualFold(tool, f.Tool) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseQuery splits "session:", "role:" and "tool:" filters out of query.
+func ParseQuery(query string) (string, Filter) {
+	var filter Filter
+	var words []string
+	for _, word := range strings.Fields(query) {
+		key, value, ok := strings.Cut(word, ":")
+		if ok && value != "" {
+			switch strings.ToLower(key) {
+			case "session":
+				filter.Session = value
+				continue
+			case "role":
+				filter.Role = value
+				continue
+			case "tool":
+				filter.Tool = value
+				continue
+			}
+		}
+		words = append(words, word)
+	}
+	return strings.Join(words, " "), filter
+}
+
+// Hit is a ranked search result.
+type Hit struct {
+	Document
+	Score   float64
+	Snippet string
+}
+
+type posting struct {
+	doc  int
+	freq int
+}
+
+// Index is an inverted index over documents.
+type Index struct {
+	docs     []Document
+	lengths  []int
+	total    int
+	postings map[string][]posting
+}
+
+// NewIndex returns an empty index.
+func NewIndex() *Index {
+	return &Index{postings: make(map[string][]posting)}
+}
+
+// Len returns the number of indexed documents.
+func (ix *Index) Len() int {
+	return len(ix.docs)
+}
+
+// Add indexes doc.
+func (ix *Index) Add(doc Document) {
+	terms := Tokenize(doc.Text)
+	if len(terms) == 0 {
+		return
+	}
+	id := len(ix.docs)
+	ix.docs = append(ix.docs, doc)
+	ix.lengths = append(ix.lengths, len(terms))
+	ix.total += len(terms)
+
+	freqs := make(map[string]int)
+	for _, term := range terms {
+		freqs[term]++
+	}
+	for term, freq := range freqs {
+		ix.postings[term] = append(ix.postings[term], posting{doc: id, freq: freq})
+	}
+}
+
+// Search returns up to limit documents ranked by BM25 score for query, which
+// may contain filters understood by ParseQuery. A query with only filters
+// returns matching documents in index order.
+func (ix *Index) Search(query string, limit int) []Hit {
+	text, filter := ParseQuery(query)
+	terms := uniqueTerms(Tokenize(text))
+	if len(ix.docs) == 0 {
+		return nil
+	}
+	if len(terms) == 0 {
+		if filter == (Filter{}) {
+			return nil
+		}
+		var hits []Hit
+		for _, doc := range ix.docs {
+			if filter.matches(doc) {
+				hits = append(hits, Hit{Document: doc, Snippet: Snippet(doc.Text, nil, 160)})
+				if limit > 0 && len(hits) >= limit {
+					break
+				}
+			}
+		}
+		return hits
+	}
+	n := float64(len(ix.docs))
+	avg := float64(ix.total) / n
+
+	scores := make(map[int]float64)
+	for _, term := range terms {
+		list := ix.postings[term]
+		if len(list) == 0 {
+			continue
+		}
+		df := float64(len(list))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, p := range list {
+			if !filter.matches(ix.docs[p.doc]) {
+				continue
+			}
+			tf := float64(p.freq)
+			norm := tf * (k1 + 1) / (tf + k1*(1-b+b*float64(ix.lengths[p.doc])/avg))
+			scores[p.doc] += idf * norm
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		doc := ix.docs[id]
+		hits = append(hits, Hit{Document: doc, Score: score, Snippet: Snippet(doc.Text, terms, 160)})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if hits[i].Session != hits[j].Session {
+			return hits[i].Session < hits[j].Session
+		}
+		return hits[i].Turn < hits[j].Turn
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// Tokenize lowercases text and splits it into words of letters, digits and
+// underscores, dropping single characters.
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	terms := fields[:0]
+	for _, field := range fields {
+		if len([]rune(field)) > 1 {
+			terms = append(terms, field)
+		}
+	}
+	return terms
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	var unique []string
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
+
+// Snippet returns about width characters of text around the first query
+// term, on a single line.
+func Snippet(text string, terms []string, width int) string {
+	flat := strings.Join(strings.Fields(text), " ")
+	lower := strings.ToLower(flat)
+	start := -1
+	for _, term := range terms {
+		if idx := indexWord(lower, term); idx >= 0 && (start < 0 || idx < start) {
+			start = idx
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+	from := start - width/3
+	if from < 0 {
+		from = 0
+	}
+	to := from + width
+	if to > len(flat) {
+		to = len(flat)
+	}
+	for from > 0 && !isRuneStart(flat[from]) {
+		from--
+	}
+	for to < len(flat) && !isRuneStart(flat[to]) {
+		to++
+	}
+	snippet := flat[from:to]
+	if from > 0 {
+		snippet = "…" + snippet
+	}
+	if to < len(flat) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+// indexWord finds term in text, preferring a match at a word start.
+func indexWord(text string, term string) int {
+	first := -1
+	for offset := 0; offset < len(text); {
+		idx := strings.Index(text[offset:], term)
+		if idx < 0 {
+			break
+		}
+		idx += offset
+		if first < 0 {
+			first = idx
+		}
+		if idx == 0 || !isWordByte(text[idx-1]) {
+			return idx
+		}
+		offset = idx + len(term)
+	}
+	return first
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 0x80 || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9')
+}
+
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
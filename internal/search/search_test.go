@@ -0,0 +1,119 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query  string
+		text   string
+		filter Filter
+	}{
+		{"retry backoff", "retry backoff", Filter{}},
+		{"session:main retry", "retry", Filter{Session: "main"}},
+		{"ROLE:user tool:read_file parser", "parser", Filter{Role: "user", Tool: "read_file"}},
+		{"session: retry", "session: retry", Filter{}},
+		{"url:http://x retry", "url:http://x retry", Filter{}},
+		{"tool:grep", "", Filter{Tool: "grep"}},
+	}
+	for _, tt := range tests {
+		text, filter := ParseQuery(tt.query)
+		if text != tt.text || filter != tt.filter {
+			t.Errorf("ParseQuery(%q) = %q, %+v; want %q, %+v", tt.query, text, filter, tt.text, tt.filter)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Fix the HTTP_client: a 404 in café.go")
+	want := []string{"fix", "the", "http_client", "404", "in", "café", "go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize = %q, want %q", got, want)
+	}
+}
+
+func testIndex() *Index {
+	ix := NewIndex()
+	for _, doc := range []Document{
+		{Session: "a", Turn: 1, Role: "user", Text: "the parser fails on nested lists"},
+		{Session: "a", Turn: 1, Role: "assistant", Tools: []string{"read_file"}, Text: "reading parser.go to check the nested list handling in the parser"},
+		{Session: "b", Turn: 2, Role: "user", Text: "add retry with exponential backoff to the http client"},
+		{Session: "b", Turn: 2, Role: "tool", Tools: []string{"grep"}, Text: "client.go:12: func retry()"},
+		{Session: "c", Turn: 1, Role: "user", Text: "   "},
+	} {
+		ix.Add(doc)
+	}
+	return ix
+}
+
+type hitKey struct {
+	Session string
+	Role    string
+}
+
+func keys(hits []Hit) []hitKey {
+	var out []hitKey
+	for _, hit := range hits {
+		out = append(out, hitKey{hit.Session, hit.Role})
+	}
+	return out
+}
+
+func TestSearch(t *testing.T) {
+	ix := testIndex()
+	if ix.Len() != 4 {
+		t.Fatalf("Len = %d, want 4 (blank documents are skipped)", ix.Len())
+	}
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []hitKey
+	}{
+		{"term frequency ranks first", "parser", 0, []hitKey{{"a", "assistant"}, {"a", "user"}}},
+		{"rare term outweighs common", "backoff client", 0, []hitKey{{"b", "user"}, {"b", "tool"}}},
+		{"limit", "parser", 1, []hitKey{{"a", "assistant"}}},
+		{"role filter", "parser role:user", 0, []hitKey{{"a", "user"}}},
+		{"tool filter", "retry tool:GREP", 0, []hitKey{{"b", "tool"}}},
+		{"session filter", "the session:b", 0, []hitKey{{"b", "user"}}},
+		{"filter only", "session:a", 0, []hitKey{{"a", "user"}, {"a", "assistant"}}},
+		{"no match", "kubernetes", 0, nil},
+		{"empty query", "", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keys(ix.Search(tt.query, tt.limit)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchScoresDescending(t *testing.T) {
+	hits := testIndex().Search("parser nested retry", 0)
+	for i := 1; i < len(hits); i++ {
+		if hits[i].Score > hits[i-1].Score {
+			t.Fatalf("hit %d scores %f above hit %d (%f)", i, hits[i].Score, i-1, hits[i-1].Score)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := "alpha beta gamma delta epsilon zeta eta theta iota kappa"
+	tests := []struct {
+		terms []string
+		width int
+		want  string
+	}{
+		{nil, 100, text},
+		{[]string{"kappa"}, 12, "…ota kappa"},
+		{[]string{"beta"}, 10, "…ha beta ga…"},
+	}
+	for _, tt := range tests {
+		if got := Snippet(text, tt.terms, tt.width); got != tt.want {
+			t.Errorf("Snippet(%v, %d) = %q, want %q", tt.terms, tt.width, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/unixsysdev/serena-cli-go/internal/orchestrator"
+	"github.com/unixsysdev/serena-cli-go/internal/session"
+)
+
+// cache holds the index returned by CachedIndex and the session generation
+// it was built at.
+var cache struct {
+	sync.Mutex
+	store      *session.Store
+	generation uint64
+	index      *Index
+}
+
+// CachedIndex returns the index of store, reusing the one built by the
+// previous call until a Store in this process writes session data.
+func CachedIndex(store *session.Store) (*Index, error) {
+	cache.Lock()
+	defer cache.Unlock()
+	generation := session.Generation()
+	if cache.index != nil && cache.store == store && cache.generation == generation {
+		return cache.index, nil
+	}
+	ix, err := BuildIndex(store)
+	if err != nil {
+		return nil, err
+	}
+	cache.store, cache.generation, cache.index = store, generation, ix
+	return ix, nil
+}
+
+// BuildIndex indexes the messages and archives of every session in store.
+func BuildIndex(store *session.Store) (*Index, error) {
+	all, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	ix := NewIndex()
+	for _, entry := range all {
+		data, err := store.Load(entry.Name)
+		if err != nil {
+			continue
+		}
+		var epochs []session.Epoch
+		if data.ArchiveFile != "" {
+			if epochs, err = store.ReadArchive(data.ArchiveFile); err != nil {
+				return nil, err
+			}
+		}
+		for _, doc := range SessionDocuments(data, epochs) {
+			ix.Add(doc)
+		}
+	}
+	return ix, nil
+}
+
+// SessionDocuments splits a session into one document per message, both in
+// the live conversation and in archived epochs.
+func SessionDocuments(data *session.SessionData, epochs []session.Epoch) []Document {
+	docs := messageDocuments(data.Name, data.Messages)
+	for _, epoch := range epochs {
+		for _, doc := range messageDocuments(data.Name, epoch.Messages) {
+			doc.Source = SourceArchive
+			doc.Epoch = epoch.Number
+			doc.Turn = 0
+			doc.Label = fmt.Sprintf("epoch %d: %s", epoch.Number, epoch.Reason)
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+func messageDocuments(name string, messages []session.StoredMessage) []Document {
+	var docs []Document
+	turn := 0
+	callNames := make(map[string]string)
+	for _, msg := range messages {
+		text := msg.Content
+		var tools []string
+		switch msg.Role {
+		case openai.ChatMessageRoleUser:
+			turn++
+			text = orchestrator.UserRequest(text)
+		case openai.ChatMessageRoleAssistant:
+			for _, call := range msg.ToolCalls {
+				callNames[call.ID] = call.Name
+				tools = append(tools, call.Name)
+				text += "\n" + call.Name + " " + call.Arguments
+			}
+		case openai.ChatMessageRoleTool:
+			if tool := callNames[msg.ToolCallID]; tool != "" {
+				tools = append(tools, tool)
+			}
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		docs = append(docs, Document{
+			Session: name,
+			Turn:    turn,
+			Source:  SourceMessage,
+			Role:    msg.Role,
+			Tools:   tools,
+			Text:    text,
+		})
+	}
+	return docs
+}
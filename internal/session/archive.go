@@ -0,0 +1,305 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/unixsysdev/serena-cli-go/internal/crypt"
+	"github.com/unixsysdev/serena-cli-go/internal/fsutil"
+)
+
+// ArchiveEntry is one line of a session archive: either an epoch marker,
+// written when a compaction or rewind starts archiving messages, or one
+// archived message belonging to that epoch.
+type ArchiveEntry struct {
+	Epoch  int    `json:"epoch"`
+	Reason string `json:"reason,omitempty"`
+	// Index is the conversation position the epoch's messages were taken
+	// from, counting the system prompt as 0.
+	Index   int            `json:"index,omitempty"`
+	Time    time.Time      `json:"time"`
+	Message *StoredMessage `json:"message,omitempty"`
+}
+
+// Epoch groups the messages archived together.
+type Epoch struct {
+	Number   int
+	Reason   string
+	Index    int
+	Time     time.Time
+	Messages []StoredMessage
+}
+
+// ArchiveFileName returns the archive file name for a session.
+func ArchiveFileName(name string) string {
+	return sanitizeName(name) + "_archive.jsonl"
+}
+
+// SummaryFileName returns the summary file name for a session.
+func SummaryFileName(name string) string {
+	return sanitizeName(name) + "_summary.md"
+}
+
+// AppendArchive appends messages, taken from conversation position index, to
+// the archive file as a new epoch and returns its number.
+func (s *Store) AppendArchive(file string, reason string, index int, messages []StoredMessage) (int, error) {
+	if len(messages) == 0 || file == "" {
+		return 0, nil
+	}
+	if err := s.sealPlaintext(file); err != nil {
+		return 0, err
+	}
+	epochs, err := s.ReadArchive(file)
+	if err != nil {
+		return 0, err
+	}
+	path := s.companionPath(file)
+	number := 1
+	if len(epochs) > 0 {
+		number = epochs[len(epochs)-1].Number + 1
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	if err := encoder.Encode(ArchiveEntry{Epoch: number, Reason: reason, Index: index, Time: now}); err != nil {
+		return 0, err
+	}
+	for i := range messages {
+		if err := encoder.Encode(ArchiveEntry{Epoch: number, Time: now, Message: &messages[i]}); err != nil {
+			return 0, err
+		}
+	}
+
+	sealed, err := s.cipher.SealLines([]byte(b.String()))
+	if err != nil {
+		return 0, err
+	}
+	defer changed()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.Write(sealed); err != nil {
+		f.Close()
+		return 0, err
+	}
+	return number, f.Close()
+}
+
+// sealPlaintext encrypts a file written before the store had a cipher, so
+// encrypted lines are never appended to plaintext ones.
+func (s *Store) sealPlaintext(file string) error {
+	if s.cipher == nil {
+		return nil
+	}
+	data, err := os.ReadFile(s.companionPath(file))
+	if errors.Is(err, os.ErrNotExist) || len(bytes.TrimSpace(data)) == 0 || crypt.IsEncrypted(data) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.WriteFile(file, string(data))
+}
+
+// ReadArchive returns the epochs stored in an archive file, oldest first. A
+// missing file has no epochs, unreadable lines are skipped and old free-text
+// archives are converted on the fly.
+func (s *Store) ReadArchive(file string) ([]Epoch, error) {
+	content, err := s.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if IsTextArchive(content) {
+		return ConvertTextArchive(content), nil
+	}
+	return ParseArchive(content), nil
+}
+
+// ReadFile returns the decrypted content of an archive or summary file in
+// the store's directory; a missing file is empty.
+func (s *Store) ReadFile(file string) (string, error) {
+	return s.readFile(file, false)
+}
+
+// readFile is ReadFile that, with mixed, also accepts plaintext lines that
+// older versions left in front of encrypted ones.
+func (s *Store) readFile(file string, mixed bool) (string, error) {
+	path := s.companionPath(file)
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if mixed {
+		data, err = s.cipher.OpenMixed(data)
+		return string(data), sessionCryptError(err)
+	}
+	data, err = decrypt(s.cipher, data)
+	return string(data), err
+}
+
+// WriteFile atomically replaces an archive or summary file, encrypting it
+// when the store has a cipher.
+func (s *Store) WriteFile(file string, content string) error {
+	path := s.companionPath(file)
+	if path == "" {
+		return fmt.Errorf("file name is required")
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	data := []byte(content)
+	if s.cipher != nil {
+		sealed, err := s.cipher.Seal(data)
+		if err != nil {
+			return err
+		}
+		// End the encrypted line so archive entries can be appended after it.
+		data = append(sealed, '\n')
+	}
+	defer changed()
+	return fsutil.WriteFileAtomic(path, data, 0o600)
+}
+
+// ParseArchive parses JSONL archive content.
+func ParseArchive(content string) []Epoch {
+	var epochs []Epoch
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBundleEntrySize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry ArchiveEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			continue
+		}
+		if entry.Message == nil || len(epochs) == 0 || epochs[len(epochs)-1].Number != entry.Epoch {
+			epochs = append(epochs, Epoch{Number: entry.Epoch, Reason: entry.Reason, Index: entry.Index, Time: entry.Time})
+		}
+		if entry.Message != nil {
+			last := &epochs[len(epochs)-1]
+			last.Messages = append(last.Messages, *entry.Message)
+		}
+	}
+	return epochs
+}
+
+// EncodeArchive renders epochs as JSONL archive content.
+func EncodeArchive(epochs []Epoch) (string, error) {
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	for _, epoch := range epochs {
+		if err := encoder.Encode(ArchiveEntry{Epoch: epoch.Number, Reason: epoch.Reason, Index: epoch.Index, Time: epoch.Time}); err != nil {
+			return "", err
+		}
+		for i := range epoch.Messages {
+			if err := encoder.Encode(ArchiveEntry{Epoch: epoch.Number, Time: epoch.Time, Message: &epoch.Messages[i]}); err != nil {
+				return "", err
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+var (
+	textArchiveHeader = regexp.MustCompile(`(?m)^---\n(.+) at (\S+)\n---$`)
+	textArchiveRole   = regexp.MustCompile(`(?m)^\[(user|assistant|tool|system|unknown)\]$`)
+)
+
+// IsTextArchive reports whether decrypted content uses the old free-text
+// archive format.
+func IsTextArchive(content string) bool {
+	trimmed := strings.TrimSpace(content)
+	return trimmed != "" && !strings.HasPrefix(trimmed, "{")
+}
+
+// ConvertTextArchive parses the old free-text archive format into epochs.
+// Roles and content survive; tool call IDs were never recorded, so tool calls
+// stay part of the message text.
+func ConvertTextArchive(content string) []Epoch {
+	var epochs []Epoch
+	addSection := func(reason string, stamp time.Time, text string) {
+		epoch := Epoch{Number: len(epochs) + 1, Reason: reason, Time: stamp}
+		roles := textArchiveRole.FindAllStringSubmatchIndex(text, -1)
+		if len(roles) == 0 {
+			if body := strings.TrimSpace(text); body != "" {
+				epoch.Messages = append(epoch.Messages, StoredMessage{Role: "unknown", Content: body})
+			}
+		}
+		for i, match := range roles {
+			end := len(text)
+			if i+1 < len(roles) {
+				end = roles[i+1][0]
+			}
+			body := strings.TrimSpace(text[match[1]:end])
+			if body == "" {
+				continue
+			}
+			epoch.Messages = append(epoch.Messages, StoredMessage{Role: text[match[2]:match[3]], Content: body})
+		}
+		if len(epoch.Messages) > 0 {
+			epochs = append(epochs, epoch)
+		}
+	}
+
+	headers := textArchiveHeader.FindAllStringSubmatchIndex(content, -1)
+	if len(headers) == 0 {
+		addSection("Archive", time.Time{}, content)
+		return epochs
+	}
+	addSection("Archive", time.Time{}, content[:headers[0][0]])
+	for i, header := range headers {
+		end := len(content)
+		if i+1 < len(headers) {
+			end = headers[i+1][0]
+		}
+		stamp, _ := time.Parse(time.RFC3339, content[header[4]:header[5]])
+		addSection(content[header[2]:header[3]], stamp, content[header[1]:end])
+	}
+	return epochs
+}
+
+// MigrateTextArchive converts a session's free-text archive file to JSONL,
+// pointing ArchiveFile at the new file and keeping the old one. It reports
+// whether data changed.
+func (s *Store) MigrateTextArchive(data *SessionData) (bool, error) {
+	if data.ArchiveFile == "" || strings.HasSuffix(data.ArchiveFile, ".jsonl") {
+		return false, nil
+	}
+	newFile := ArchiveFileName(data.Name)
+	content, err := s.ReadFile(data.ArchiveFile)
+	if err != nil {
+		return false, err
+	}
+	if content != "" {
+		encoded, err := EncodeArchive(ConvertTextArchive(content))
+		if err != nil {
+			return false, err
+		}
+		if err := s.WriteFile(newFile, encoded); err != nil {
+			return false, fmt.Errorf("convert archive: %w", err)
+		}
+	}
+	data.ArchiveFile = newFile
+	return true, nil
+}
@@ -0,0 +1,239 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/unixsysdev/serena-cli-go/internal/crypt"
+)
+
+func TestParseArchive(t *testing.T) {
+	stamp := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		content string
+		want    []Epoch
+	}{
+		{"empty", "", nil},
+		{
+			"epochs",
+			`{"epoch":1,"reason":"compact","index":1,"time":"2025-03-01T10:00:00Z"}
+{"epoch":1,"time":"2025-03-01T10:00:00Z","message":{"role":"user","content":"hi"}}
+{"epoch":1,"time":"2025-03-01T10:00:00Z","message":{"role":"assistant","content":"hello"}}
+{"epoch":2,"reason":"rewind","index":3,"time":"2025-03-01T10:00:00Z"}
+{"epoch":2,"time":"2025-03-01T10:00:00Z","message":{"role":"user","content":"again"}}
+`,
+			[]Epoch{
+				{Number: 1, Reason: "compact", Index: 1, Time: stamp, Messages: []StoredMessage{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}}},
+				{Number: 2, Reason: "rewind", Index: 3, Time: stamp, Messages: []StoredMessage{{Role: "user", Content: "again"}}},
+			},
+		},
+		{
+			"unreadable lines skipped",
+			`{"epoch":1,"reason":"compact","time":"2025-03-01T10:00:00Z"}
+not json
+
+{"epoch":1,"time":"2025-03-01T10:00:00Z","message":{"role":"user","content":"kept"}}
+{"epoch":1,"time":"2025-03-01T10:00:00Z","message":{"role":"user","content":"trunc`,
+			[]Epoch{{Number: 1, Reason: "compact", Time: stamp, Messages: []StoredMessage{{Role: "user", Content: "kept"}}}},
+		},
+		{
+			"message without marker starts an epoch",
+			`{"epoch":4,"time":"2025-03-01T10:00:00Z","message":{"role":"user","content":"orphan"}}`,
+			[]Epoch{{Number: 4, Time: stamp, Messages: []StoredMessage{{Role: "user", Content: "orphan"}}}},
+		},
+		{
+			"marker without messages",
+			`{"epoch":1,"reason":"compact","time":"2025-03-01T10:00:00Z"}`,
+			[]Epoch{{Number: 1, Reason: "compact", Time: stamp}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseArchive(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseArchive = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeArchiveRoundTrip(t *testing.T) {
+	stamp := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	epochs := []Epoch{
+		{Number: 1, Reason: "compact", Index: 1, Time: stamp, Messages: []StoredMessage{{Role: "user", Content: "line one\nline two"}}},
+		{Number: 2, Reason: "rewind", Index: 5, Time: stamp, Messages: []StoredMessage{{Role: "tool", Content: "{\"ok\":true}", ToolCallID: "call_1"}}},
+	}
+	content, err := EncodeArchive(epochs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsTextArchive(content) {
+		t.Fatalf("encoded archive detected as text: %q", content)
+	}
+	if got := ParseArchive(content); !reflect.DeepEqual(got, epochs) {
+		t.Errorf("round trip = %+v, want %+v", got, epochs)
+	}
+}
+
+func TestIsTextArchive(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{"", false},
+		{"  \n", false},
+		{`{"epoch":1}`, false},
+		{"\n{\"epoch\":1}", false},
+		{"---\nCompaction at 2025-03-01T10:00:00Z\n---\n[user]\nhi", true},
+		{"[user]\nhi", true},
+	}
+	for _, tt := range tests {
+		if got := IsTextArchive(tt.content); got != tt.want {
+			t.Errorf("IsTextArchive(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestConvertTextArchive(t *testing.T) {
+	stamp := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		content string
+		want    []Epoch
+	}{
+		{"empty", "", nil},
+		{
+			"no headers",
+			"[user]\nhello\n[assistant]\nhi there\n",
+			[]Epoch{{Number: 1, Reason: "Archive", Messages: []StoredMessage{{Role: "user", Content: "hello"}, {Role: "assistant", Content: "hi there"}}}},
+		},
+		{
+			"no roles",
+			"free text notes\n",
+			[]Epoch{{Number: 1, Reason: "Archive", Messages: []StoredMessage{{Role: "unknown", Content: "free text notes"}}}},
+		},
+		{
+			"headers",
+			"---\nCompaction at 2025-03-01T10:00:00Z\n---\n[user]\nfirst\n\n[tool]\nresult\n" +
+				"---\nRewind at not-a-time\n---\n[assistant]\nsecond\n[user]\n\n",
+			[]Epoch{
+				{Number: 1, Reason: "Compaction", Time: stamp, Messages: []StoredMessage{{Role: "user", Content: "first"}, {Role: "tool", Content: "result"}}},
+				{Number: 2, Reason: "Rewind", Messages: []StoredMessage{{Role: "assistant", Content: "second"}}},
+			},
+		},
+		{
+			"text before first header",
+			"[user]\nearly\n---\nCompaction at 2025-03-01T10:00:00Z\n---\n[user]\nlate\n",
+			[]Epoch{
+				{Number: 1, Reason: "Archive", Messages: []StoredMessage{{Role: "user", Content: "early"}}},
+				{Number: 2, Reason: "Compaction", Time: stamp, Messages: []StoredMessage{{Role: "user", Content: "late"}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ConvertTextArchive(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConvertTextArchive = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendAndMigrateArchive(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := ArchiveFileName("main")
+	for i, content := range []string{"one", "two"} {
+		number, err := store.AppendArchive(file, "compact", i+1, []StoredMessage{{Role: "user", Content: content}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if number != i+1 {
+			t.Errorf("epoch number = %d, want %d", number, i+1)
+		}
+	}
+	epochs, err := store.ReadArchive(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(epochs) != 2 || epochs[1].Messages[0].Content != "two" || epochs[1].Index != 2 {
+		t.Errorf("ReadArchive = %+v", epochs)
+	}
+
+	if err := store.WriteFile("old_archive.txt", "[user]\nlegacy\n"); err != nil {
+		t.Fatal(err)
+	}
+	data := &SessionData{Name: "old", ArchiveFile: "old_archive.txt"}
+	migrated, err := store.MigrateTextArchive(data)
+	if err != nil || !migrated {
+		t.Fatalf("MigrateTextArchive = %v, %v", migrated, err)
+	}
+	if data.ArchiveFile != ArchiveFileName("old") {
+		t.Errorf("ArchiveFile = %q", data.ArchiveFile)
+	}
+	epochs, err = store.ReadArchive(data.ArchiveFile)
+	if err != nil || len(epochs) != 1 || epochs[0].Messages[0].Content != "legacy" {
+		t.Errorf("migrated archive = %+v, %v", epochs, err)
+	}
+	if kept, _ := store.ReadFile("old_archive.txt"); kept == "" {
+		t.Error("old archive was not kept")
+	}
+	if migrated, err := store.MigrateTextArchive(data); err != nil || migrated {
+		t.Errorf("second MigrateTextArchive = %v, %v", migrated, err)
+	}
+}
+
+func TestEncryptedArchivePlaintextLines(t *testing.T) {
+	dir := t.TempDir()
+	plain, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := ArchiveFileName("main")
+	if _, err := plain.AppendArchive(file, "compact", 1, []StoredMessage{{Role: "user", Content: "before"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := crypt.LoadCipher(dir, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := OpenEncryptedStore(dir, BackendJSON, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.AppendArchive(file, "compact", 2, []StoredMessage{{Role: "user", Content: "after"}}); err != nil {
+		t.Fatal(err)
+	}
+	epochs, err := store.ReadArchive(file)
+	if err != nil || len(epochs) != 2 || epochs[0].Messages[0].Content != "before" {
+		t.Fatalf("ReadArchive after enabling encryption = %+v, %v", epochs, err)
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "before") {
+		t.Fatal("plaintext archive was not encrypted before appending")
+	}
+
+	forged := `{"epoch":2,"time":"2025-03-01T10:00:00Z","message":{"role":"user","content":"forged"}}` + "\n"
+	f, err := os.OpenFile(filepath.Join(dir, file), os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(forged); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if _, err := store.ReadArchive(file); !errors.Is(err, crypt.ErrMixed) {
+		t.Fatalf("ReadArchive with a forged plaintext line: err = %v, want ErrMixed", err)
+	}
+}
@@ -0,0 +1,351 @@
+package session
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BundleVersion is the layout version written to bundle manifests.
+const BundleVersion = 1
+
+// Bundle file names inside the archive.
+const (
+	bundleManifest = "manifest.json"
+	bundleSession  = "session.json"
+	bundleSummary  = "summary.md"
+	bundleArchive  = "archive.jsonl"
+	// bundleTextArchive is the archive entry of bundles packed before
+	// archives were JSONL.
+	bundleTextArchive = "archive.txt"
+	bundleUsage       = "usage.json"
+
+	maxBundleEntrySize = 256 << 20
+)
+
+// BundleManifest describes a packed session.
+type BundleManifest struct {
+	Version  int       `json:"version"`
+	Session  string    `json:"session"`
+	Project  string    `json:"project"`
+	PackedAt time.Time `json:"packed_at"`
+	Redacted bool      `json:"redacted"`
+}
+
+// Usage summarizes the size of a session's conversation.
+type Usage struct {
+	Messages     int `json:"messages"`
+	ToolCalls    int `json:"tool_calls"`
+	Characters   int `json:"characters"`
+	ApproxTokens int `json:"approx_tokens"`
+}
+
+// Bundle is a session with its summary and archive, ready to move between
+// machines.
+type Bundle struct {
+	Manifest BundleManifest
+	Session  *SessionData
+	Summary  string
+	Archive  string
+	Usage    Usage
+}
+
+// PackOptions controls LoadBundle.
+type PackOptions struct {
+	// Project is the project directory the session belongs to, recorded so
+	// paths can be remapped on import.
+	Project string
+	// Redact, when set, is applied to every piece of text in the bundle.
+	Redact func(string) string
+}
+
+// ImportOptions controls Import.
+type ImportOptions struct {
+	// Name overrides the session name from the bundle.
+	Name string
+	// Overwrite replaces an existing session instead of picking a free name.
+	Overwrite bool
+}
+
+// SessionUsage counts messages, tool calls and characters in data.
+func SessionUsage(data *SessionData) Usage {
+	usage := Usage{Messages: len(data.Messages)}
+	for _, msg := range data.Messages {
+		usage.Characters += len(msg.Content)
+		usage.ToolCalls += len(msg.ToolCalls)
+		for _, call := range msg.ToolCalls {
+			usage.Characters += len(call.Name) + len(call.Arguments)
+		}
+	}
+	usage.ApproxTokens = usage.Characters / 4
+	return usage
+}
+
+// LoadBundle collects session name with its summary and archive. Git
+// checkpoints and auto-commit records are dropped since they refer to the
+// local repository.
+func (s *Store) LoadBundle(name string, opts PackOptions) (*Bundle, error) {
+	data, err := s.Load(name)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &Bundle{
+		Manifest: BundleManifest{
+			Version:  BundleVersion,
+			Session:  data.Name,
+			Project:  opts.Project,
+			PackedAt: time.Now(),
+			Redacted: opts.Redact != nil,
+		},
+		Session: data,
+		Usage:   SessionUsage(data),
+	}
+	if bundle.Summary, err = s.ReadFile(data.SummaryFile); err != nil {
+		return nil, err
+	}
+	if bundle.Archive, err = s.ReadFile(data.ArchiveFile); err != nil {
+		return nil, err
+	}
+	data.Checkpoints = nil
+	data.Commits = nil
+	if opts.Redact != nil {
+		bundle.rewrite(opts.Redact)
+	}
+	return bundle, nil
+}
+
+// RemapProject rewrites occurrences of the packing project's path to project.
+// Paths that only share a prefix with it, like a sibling project2, are kept.
+func (b *Bundle) RemapProject(project string) {
+	from := b.Manifest.Project
+	if from == "" || project == "" || from == project {
+		return
+	}
+	b.rewrite(func(text string) string {
+		return replacePath(text, from, project)
+	})
+	b.Manifest.Project = project
+}
+
+// replacePath replaces from with to wherever the match ends at a path
+// boundary.
+func replacePath(text, from, to string) string {
+	if !strings.Contains(text, from) {
+		return text
+	}
+	var out strings.Builder
+	rest := text
+	for {
+		i := strings.Index(rest, from)
+		if i < 0 {
+			break
+		}
+		end := i + len(from)
+		out.WriteString(rest[:i])
+		if end == len(rest) || strings.ContainsRune("/\\\"'` \t\r\n", rune(rest[end])) {
+			out.WriteString(to)
+		} else {
+			out.WriteString(from)
+		}
+		rest = rest[end:]
+	}
+	out.WriteString(rest)
+	return out.String()
+}
+
+// rewrite applies fn to every piece of text in the bundle.
+func (b *Bundle) rewrite(fn func(string) string) {
+	data := b.Session
+	data.SystemPrompt = fn(data.SystemPrompt)
+	for i := range data.Messages {
+		msg := &data.Messages[i]
+		msg.Content = fn(msg.Content)
+		for j := range msg.ToolCalls {
+			msg.ToolCalls[j].Arguments = fn(msg.ToolCalls[j].Arguments)
+		}
+	}
+	for i := range data.Context {
+		source := &data.Context[i]
+		source.Label = fn(source.Label)
+		source.Path = fn(source.Path)
+		source.Content = fn(source.Content)
+	}
+	b.Summary = fn(b.Summary)
+	if IsTextArchive(b.Archive) {
+		b.Archive = fn(b.Archive)
+		return
+	}
+	// Rewrite archived messages individually so the JSON stays valid.
+	epochs := ParseArchive(b.Archive)
+	for i := range epochs {
+		for j := range epochs[i].Messages {
+			msg := &epochs[i].Messages[j]
+			msg.Content = fn(msg.Content)
+			for k := range msg.ToolCalls {
+				msg.ToolCalls[k].Arguments = fn(msg.ToolCalls[k].Arguments)
+			}
+		}
+	}
+	encoded, err := EncodeArchive(epochs)
+	if err != nil {
+		encoded = fn(b.Archive)
+	}
+	b.Archive = encoded
+}
+
+// Write stores the bundle as a gzipped tar archive.
+func (b *Bundle) Write(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	entries := []struct {
+		name  string
+		value interface{}
+	}{
+		{bundleManifest, b.Manifest},
+		{bundleSession, b.Session},
+		{bundleUsage, b.Usage},
+	}
+	for _, entry := range entries {
+		payload, err := json.MarshalIndent(entry.value, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeTarFile(tw, entry.name, payload, b.Manifest.PackedAt); err != nil {
+			return err
+		}
+	}
+	for _, file := range [][2]string{{bundleSummary, b.Summary}, {bundleArchive, b.Archive}} {
+		if file[1] == "" {
+			continue
+		}
+		if err := writeTarFile(tw, file[0], []byte(file[1]), b.Manifest.PackedAt); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeTarFile(tw *tar.Writer, name string, payload []byte, modTime time.Time) error {
+	header := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(payload)), ModTime: modTime}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(payload)
+	return err
+}
+
+// ReadBundle parses a bundle written by Write.
+func ReadBundle(r io.Reader) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("read bundle: %w", err)
+	}
+	defer gz.Close()
+
+	bundle := &Bundle{}
+	var haveManifest bool
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read bundle: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		payload, err := io.ReadAll(io.LimitReader(tr, maxBundleEntrySize))
+		if err != nil {
+			return nil, fmt.Errorf("read bundle: %w", err)
+		}
+		switch header.Name {
+		case bundleManifest:
+			if err := json.Unmarshal(payload, &bundle.Manifest); err != nil {
+				return nil, fmt.Errorf("parse bundle manifest: %w", err)
+			}
+			haveManifest = true
+		case bundleSession:
+			bundle.Session = &SessionData{}
+			if err := json.Unmarshal(payload, bundle.Session); err != nil {
+				return nil, fmt.Errorf("parse bundle session: %w", err)
+			}
+		case bundleUsage:
+			_ = json.Unmarshal(payload, &bundle.Usage)
+		case bundleSummary:
+			bundle.Summary = string(payload)
+		case bundleArchive, bundleTextArchive:
+			bundle.Archive = string(payload)
+		}
+	}
+	if !haveManifest || bundle.Session == nil {
+		return nil, fmt.Errorf("not a session bundle")
+	}
+	if bundle.Manifest.Version > BundleVersion {
+		return nil, fmt.Errorf("bundle version %d is newer than supported (%d)", bundle.Manifest.Version, BundleVersion)
+	}
+	return bundle, nil
+}
+
+// Import saves the bundle into the store and returns the session name used.
+// Without Overwrite, a name already in use gets a numeric suffix.
+func (s *Store) Import(bundle *Bundle, opts ImportOptions) (string, error) {
+	var err error
+	name := opts.Name
+	if name == "" {
+		name = bundle.Session.Name
+	}
+	name = sanitizeName(name)
+	if !opts.Overwrite {
+		base := name
+		for i := 2; s.exists(name); i++ {
+			name = fmt.Sprintf("%s-%d", base, i)
+		}
+	}
+
+	data := *bundle.Session
+	data.Name = name
+	if data.Version, err = s.backend.Version(name); err != nil {
+		return "", err
+	}
+	data.ArchiveFile = ArchiveFileName(name)
+	if IsTextArchive(bundle.Archive) {
+		if bundle.Archive, err = EncodeArchive(ConvertTextArchive(bundle.Archive)); err != nil {
+			return "", err
+		}
+	}
+	data.SummaryFile = SummaryFileName(name)
+	for file, text := range map[string]string{data.ArchiveFile: bundle.Archive, data.SummaryFile: bundle.Summary} {
+		if text == "" {
+			if err := os.Remove(filepath.Join(s.dir, file)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return "", err
+			}
+			changed()
+			continue
+		}
+		if err := s.WriteFile(file, text); err != nil {
+			return "", err
+		}
+	}
+	if err := s.Save(&data); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func (s *Store) exists(name string) bool {
+	_, err := s.Load(name)
+	return err == nil
+}
@@ -0,0 +1,39 @@
+package session
+
+import "testing"
+
+func TestRemapProject(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"exact", "/home/a/proj", "/srv/proj"},
+		{"file inside", "see /home/a/proj/main.go", "see /srv/proj/main.go"},
+		{"sibling prefix", "/home/a/project2/x", "/home/a/project2/x"},
+		{"sibling then match", "/home/a/projx and /home/a/proj", "/home/a/projx and /srv/proj"},
+		{"quoted", `{"path":"/home/a/proj"}`, `{"path":"/srv/proj"}`},
+		{"windows separator", `/home/a/proj\src`, `/srv/proj\src`},
+		{"whitespace", "cd /home/a/proj\nls", "cd /srv/proj\nls"},
+		{"no match", "nothing here", "nothing here"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundle := &Bundle{
+				Manifest: BundleManifest{Project: "/home/a/proj"},
+				Session:  &SessionData{Messages: []StoredMessage{{Role: "user", Content: tt.text}}},
+				Summary:  tt.text,
+			}
+			bundle.RemapProject("/srv/proj")
+			if got := bundle.Session.Messages[0].Content; got != tt.want {
+				t.Errorf("message = %q, want %q", got, tt.want)
+			}
+			if bundle.Summary != tt.want {
+				t.Errorf("summary = %q, want %q", bundle.Summary, tt.want)
+			}
+			if bundle.Manifest.Project != "/srv/proj" {
+				t.Errorf("manifest project = %q", bundle.Manifest.Project)
+			}
+		})
+	}
+}
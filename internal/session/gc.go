@@ -0,0 +1,240 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// staleTempAge is how old a temporary file left behind by
+// fsutil.WriteFileAtomic must be before garbage collection removes it.
+const staleTempAge = time.Hour
+
+// DiskUsage is the space a session takes on disk, in bytes.
+type DiskUsage struct {
+	Session int64
+	Archive int64
+	Summary int64
+}
+
+// Total returns the combined size.
+func (u DiskUsage) Total() int64 {
+	return u.Session + u.Archive + u.Summary
+}
+
+// sizer is implemented by backends that can report how much space a stored
+// session takes.
+type sizer interface {
+	Size(name string) (int64, error)
+}
+
+// DiskUsage returns the size of a session's stored data, archive and
+// summary. Missing files count as empty.
+func (s *Store) DiskUsage(data *SessionData) (DiskUsage, error) {
+	var usage DiskUsage
+	if backend, ok := s.backend.(sizer); ok {
+		size, err := backend.Size(sanitizeName(data.Name))
+		if err != nil {
+			return usage, err
+		}
+		usage.Session = size
+	}
+	var err error
+	if usage.Archive, err = fileSize(s.companionPath(data.ArchiveFile)); err != nil {
+		return usage, err
+	}
+	if usage.Summary, err = fileSize(s.companionPath(data.SummaryFile)); err != nil {
+		return usage, err
+	}
+	return usage, nil
+}
+
+// legacyArchiveFileName returns the name of the free-text archive sessions
+// used before archives were stored as JSONL.
+func legacyArchiveFileName(name string) string {
+	return sanitizeName(name) + "_archive.txt"
+}
+
+func (s *Store) companionPath(file string) string {
+	if file == "" {
+		return ""
+	}
+	return filepath.Join(s.dir, filepath.Base(file))
+}
+
+func fileSize(path string) (int64, error) {
+	if path == "" {
+		return 0, nil
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// RetentionPolicy decides which sessions garbage collection removes. Zero
+// limits are disabled.
+type RetentionPolicy struct {
+	// MaxAge removes sessions not updated for longer than this.
+	MaxAge time.Duration
+	// MaxCount keeps only the most recently updated sessions.
+	MaxCount int
+	// MaxSize keeps the most recently updated sessions that fit in this many
+	// bytes in total.
+	MaxSize int64
+	// Keep names sessions that are never removed, in addition to pinned
+	// ones.
+	Keep []string
+}
+
+// GCCandidate is a session selected for removal.
+type GCCandidate struct {
+	Name      string
+	UpdatedAt time.Time
+	Size      int64
+	Reason    string
+}
+
+// OrphanFile is an archive, summary or temporary file that no session refers
+// to.
+type OrphanFile struct {
+	Path string
+	Size int64
+}
+
+// GCPlan lists what garbage collection would remove from a store.
+type GCPlan struct {
+	Sessions []GCCandidate
+	Orphans  []OrphanFile
+	// Kept and KeptSize describe the sessions that stay.
+	Kept     int
+	KeptSize int64
+}
+
+// Size returns the bytes the plan frees.
+func (p *GCPlan) Size() int64 {
+	var total int64
+	for _, candidate := range p.Sessions {
+		total += candidate.Size
+	}
+	for _, orphan := range p.Orphans {
+		total += orphan.Size
+	}
+	return total
+}
+
+// PlanGC selects the sessions policy removes, newest sessions winning, and
+// finds orphaned files. Nothing is deleted.
+func (s *Store) PlanGC(policy RetentionPolicy, now time.Time) (*GCPlan, error) {
+	all, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].UpdatedAt.After(all[j].UpdatedAt)
+	})
+	keep := make(map[string]bool, len(policy.Keep))
+	for _, name := range policy.Keep {
+		keep[sanitizeName(name)] = true
+	}
+
+	// Companion files are referenced by every stored session, including ones
+	// that fail to parse and so are missing from all.
+	names, err := s.backend.Names()
+	if err != nil {
+		return nil, err
+	}
+	referenced := make(map[string]bool)
+	for _, name := range names {
+		referenced[ArchiveFileName(name)] = true
+		referenced[SummaryFileName(name)] = true
+		// The text archive MigrateTextArchive keeps as a backup.
+		referenced[legacyArchiveFileName(name)] = true
+	}
+
+	plan := &GCPlan{}
+	for _, data := range all {
+		referenced[filepath.Base(data.ArchiveFile)] = true
+		referenced[filepath.Base(data.SummaryFile)] = true
+		usage, err := s.DiskUsage(&data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", data.Name, err)
+		}
+		size := usage.Total()
+
+		reason := ""
+		switch {
+		case keep[data.Name] || data.Pinned:
+		case policy.MaxAge > 0 && now.Sub(data.UpdatedAt) > policy.MaxAge:
+			reason = fmt.Sprintf("not updated for %d days", int(now.Sub(data.UpdatedAt).Hours()/24))
+		case policy.MaxCount > 0 && plan.Kept >= policy.MaxCount:
+			reason = fmt.Sprintf("more than %d sessions", policy.MaxCount)
+		case policy.MaxSize > 0 && plan.KeptSize+size > policy.MaxSize:
+			reason = "over the size limit"
+		}
+		if reason == "" {
+			plan.Kept++
+			plan.KeptSize += size
+			continue
+		}
+		plan.Sessions = append(plan.Sessions, GCCandidate{Name: data.Name, UpdatedAt: data.UpdatedAt, Size: size, Reason: reason})
+	}
+
+	orphans, err := s.orphans(referenced, now)
+	if err != nil {
+		return nil, err
+	}
+	plan.Orphans = orphans
+	return plan, nil
+}
+
+// orphans lists archive and summary files no session refers to, and
+// temporary files left behind by interrupted writes.
+func (s *Store) orphans(referenced map[string]bool, now time.Time) ([]OrphanFile, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var orphans []OrphanFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || referenced[name] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		companion := strings.HasSuffix(name, "_archive.jsonl") || strings.HasSuffix(name, "_archive.txt") ||
+			strings.HasSuffix(name, "_summary.md")
+		staleTemp := strings.HasPrefix(name, ".") && strings.Contains(name, ".tmp-") &&
+			now.Sub(info.ModTime()) > staleTempAge
+		if companion || staleTemp {
+			orphans = append(orphans, OrphanFile{Path: filepath.Join(s.dir, name), Size: info.Size()})
+		}
+	}
+	return orphans, nil
+}
+
+// ApplyGC deletes what plan lists.
+func (s *Store) ApplyGC(plan *GCPlan) error {
+	for _, candidate := range plan.Sessions {
+		if err := s.Delete(candidate.Name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("delete %s: %w", candidate.Name, err)
+		}
+	}
+	for _, orphan := range plan.Orphans {
+		if err := os.Remove(orphan.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// gcStore returns a store holding sessions updated the given number of days
+// before now. Every session has a 100-byte summary.
+func gcStore(t *testing.T, now time.Time, ages map[string]int, pinned ...string) *Store {
+	t.Helper()
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, days := range ages {
+		data := &SessionData{
+			Name:        name,
+			UpdatedAt:   now.Add(-time.Duration(days) * 24 * time.Hour),
+			SummaryFile: SummaryFileName(name),
+		}
+		for _, p := range pinned {
+			data.Pinned = data.Pinned || p == name
+		}
+		if err := store.backend.Save(data); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, store, data.SummaryFile, string(make([]byte, 100)))
+	}
+	return store
+}
+
+func writeFile(t *testing.T, store *Store, name string, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(store.Dir(), name), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlanGCPolicy(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	ages := map[string]int{"a": 1, "b": 10, "c": 40, "d": 90}
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		pinned []string
+		want   []string
+	}{
+		{"no limits", RetentionPolicy{}, nil, nil},
+		{"max age", RetentionPolicy{MaxAge: 30 * 24 * time.Hour}, nil, []string{"c", "d"}},
+		{"max count", RetentionPolicy{MaxCount: 2}, nil, []string{"c", "d"}},
+		{"max count keeps pinned", RetentionPolicy{MaxCount: 1}, []string{"d"}, []string{"b", "c"}},
+		{"keep list", RetentionPolicy{MaxAge: 5 * 24 * time.Hour, Keep: []string{"C"}}, nil, []string{"b", "d"}},
+		{"pinned never removed", RetentionPolicy{MaxAge: time.Hour}, []string{"a", "b", "c", "d"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := gcStore(t, now, ages, tt.pinned...)
+			plan, err := store.PlanGC(tt.policy, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, candidate := range plan.Sessions {
+				got = append(got, candidate.Name)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("removed %v, want %v", got, tt.want)
+			}
+			if plan.Kept+len(plan.Sessions) != len(ages) {
+				t.Errorf("kept %d + removed %d != %d sessions", plan.Kept, len(plan.Sessions), len(ages))
+			}
+		})
+	}
+}
+
+func TestPlanGCMaxSize(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := gcStore(t, now, map[string]int{"new": 1, "old": 2})
+	usage, err := store.DiskUsage(&SessionData{Name: "new", SummaryFile: SummaryFileName("new")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := store.PlanGC(RetentionPolicy{MaxSize: usage.Total() + 1}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Sessions) != 1 || plan.Sessions[0].Name != "old" {
+		t.Fatalf("removed %+v, want old", plan.Sessions)
+	}
+	if plan.KeptSize != usage.Total() || plan.Size() != plan.Sessions[0].Size {
+		t.Errorf("KeptSize = %d, Size = %d", plan.KeptSize, plan.Size())
+	}
+}
+
+func TestPlanGCOrphans(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := gcStore(t, now, map[string]int{"live": 1})
+	files := []string{
+		// A session file that does not parse still owns its companions.
+		"broken.json",
+		"broken_archive.jsonl",
+		"broken_summary.md",
+		// The text archive kept after migration to JSONL.
+		"live_archive.txt",
+		"live_archive.jsonl",
+		"gone_archive.jsonl",
+		"gone_archive.txt",
+		"gone_summary.md",
+		"notes.md",
+		".live.json.tmp-old",
+		".live.json.tmp-new",
+	}
+	for _, name := range files {
+		writeFile(t, store, name, "{")
+	}
+	old := now.Add(-2 * staleTempAge)
+	if err := os.Chtimes(filepath.Join(store.Dir(), ".live.json.tmp-old"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(store.Dir(), ".live.json.tmp-new"), now, now); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := store.PlanGC(RetentionPolicy{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, orphan := range plan.Orphans {
+		got = append(got, filepath.Base(orphan.Path))
+	}
+	sort.Strings(got)
+	want := []string{".live.json.tmp-old", "gone_archive.jsonl", "gone_archive.txt", "gone_summary.md"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("orphans %v, want %v", got, want)
+	}
+
+	if err := store.ApplyGC(plan); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"broken_archive.jsonl", "broken_summary.md", "live_archive.txt", "live_summary.md"} {
+		if _, err := os.Stat(filepath.Join(store.Dir(), name)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
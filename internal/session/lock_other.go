@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package session
+
+// lockFile is a no-op on platforms without file locking.
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,194 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/unixsysdev/serena-cli-go/internal/crypt"
+)
+
+// Rename renames a session, moving its archive and summary files and
+// updating the parent of sessions forked from it. Another process still
+// holding the old name gets ErrConflict on its next save.
+func (s *Store) Rename(oldName string, newName string) (*SessionData, error) {
+	oldName, newName = sanitizeName(oldName), sanitizeName(newName)
+	if oldName == newName {
+		return nil, fmt.Errorf("session is already named %s", newName)
+	}
+	unlockOld, err := lockFile(s.lockPath(oldName))
+	if err != nil {
+		return nil, err
+	}
+	defer unlockOld()
+	unlockNew, err := lockFile(s.lockPath(newName))
+	if err != nil {
+		return nil, err
+	}
+	defer unlockNew()
+
+	data, err := s.backend.Load(oldName)
+	if err != nil {
+		return nil, err
+	}
+	if version, err := s.backend.Version(newName); err != nil {
+		return nil, err
+	} else if version != 0 {
+		return nil, fmt.Errorf("session %s already exists", newName)
+	}
+
+	moves := [][2]string{
+		{s.companionPath(data.ArchiveFile), s.companionPath(ArchiveFileName(newName))},
+		{s.companionPath(data.SummaryFile), s.companionPath(SummaryFileName(newName))},
+	}
+	defer changed()
+	for _, move := range moves {
+		if move[0] == "" {
+			continue
+		}
+		if err := os.Rename(move[0], move[1]); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	data.Name = newName
+	data.ArchiveFile = ArchiveFileName(newName)
+	data.SummaryFile = SummaryFileName(newName)
+	if err := s.backend.Save(data); err != nil {
+		for _, move := range moves {
+			if move[0] != "" {
+				os.Rename(move[1], move[0])
+			}
+		}
+		return nil, err
+	}
+	if err := s.backend.Delete(oldName); err != nil {
+		return nil, err
+	}
+	return data, s.reparent(oldName, newName)
+}
+
+// reparent points sessions forked from oldName at newName.
+func (s *Store) reparent(oldName string, newName string) error {
+	all, err := s.backend.List()
+	if err != nil {
+		return err
+	}
+	for _, entry := range all {
+		if entry.Parent != oldName {
+			continue
+		}
+		child, err := s.Load(entry.Name)
+		if err != nil {
+			return err
+		}
+		child.Parent = newName
+		if err := s.Save(child); err != nil {
+			return fmt.Errorf("update fork %s: %w", entry.Name, err)
+		}
+	}
+	return nil
+}
+
+// NormalizeTags lowercases tags, strips a leading '#', and drops blanks and
+// duplicates, returning them sorted.
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+		tag = strings.Join(strings.Fields(tag), "-")
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// HasTag reports whether data carries tag.
+func (data *SessionData) HasTag(tag string) bool {
+	tags := NormalizeTags([]string{tag})
+	if len(tags) == 0 {
+		return false
+	}
+	for _, existing := range data.Tags {
+		if existing == tags[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// cipherBackend is implemented by backends that can return a copy of
+// themselves encrypting with another cipher.
+type cipherBackend interface {
+	withCipher(c *crypt.Cipher) Backend
+}
+
+func (b *JSONBackend) withCipher(c *crypt.Cipher) Backend {
+	return &JSONBackend{dir: b.dir, cipher: c}
+}
+
+func (b *SQLiteBackend) withCipher(c *crypt.Cipher) Backend {
+	return &SQLiteBackend{db: b.db, project: b.project, cipher: c}
+}
+
+// Reencrypt rewrites every session with its archive and summary using
+// target, or as plaintext when target is nil, and returns the number of
+// sessions rewritten. Data is read with the store's own cipher, which also
+// reads plaintext and the plaintext lines older versions left in front of
+// encrypted archive entries.
+func (s *Store) Reencrypt(target *crypt.Cipher) (int, error) {
+	backend, ok := s.backend.(cipherBackend)
+	if !ok {
+		return 0, fmt.Errorf("session backend does not support encryption")
+	}
+	dst := &Store{dir: s.dir, backend: backend.withCipher(target), cipher: target}
+	all, err := s.backend.List()
+	if err != nil {
+		return 0, err
+	}
+	for i, entry := range all {
+		if err := s.reencryptSession(dst, entry.Name); err != nil {
+			return i, fmt.Errorf("%s: %w", entry.Name, err)
+		}
+	}
+	if db, ok := s.backend.(*SQLiteBackend); ok {
+		// Old plaintext rows linger in free pages and the WAL until the
+		// database is rebuilt.
+		if err := db.vacuum(); err != nil {
+			return len(all), err
+		}
+	}
+	return len(all), nil
+}
+
+func (s *Store) reencryptSession(dst *Store, name string) error {
+	unlock, err := lockFile(s.lockPath(name))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	data, err := s.backend.Load(name)
+	if err != nil {
+		return err
+	}
+	for _, file := range []string{data.ArchiveFile, data.SummaryFile} {
+		content, err := s.readFile(file, true)
+		if err != nil {
+			return err
+		}
+		if content == "" {
+			continue
+		}
+		if err := dst.WriteFile(file, content); err != nil {
+			return err
+		}
+	}
+	defer changed()
+	return dst.backend.Save(data)
+}
@@ -0,0 +1,303 @@
+package session
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/unixsysdev/serena-cli-go/internal/crypt"
+)
+
+// SQLiteFileName is the database file shared by all projects.
+const SQLiteFileName = "sessions.db"
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	project    TEXT    NOT NULL,
+	name       TEXT    NOT NULL,
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL,
+	data       TEXT    NOT NULL,
+	PRIMARY KEY (project, name)
+);
+CREATE INDEX IF NOT EXISTS sessions_updated ON sessions (project, updated_at);
+CREATE TABLE IF NOT EXISTS messages (
+	project TEXT    NOT NULL,
+	session TEXT    NOT NULL,
+	seq     INTEGER NOT NULL,
+	hash    TEXT    NOT NULL,
+	time    INTEGER,
+	data    TEXT    NOT NULL,
+	PRIMARY KEY (project, session, seq)
+);
+CREATE INDEX IF NOT EXISTS messages_time ON messages (project, time);
+`
+
+// SQLiteBackend stores sessions in an SQLite database. Messages are kept in
+// their own table and only the changed tail is rewritten on Save.
+type SQLiteBackend struct {
+	db      *sql.DB
+	project string
+	// cipher encrypts the data columns; hashes become keyed so they reveal
+	// nothing either.
+	cipher *crypt.Cipher
+}
+
+// OpenSQLiteBackend opens (creating if needed) the database at path and
+// returns a backend scoped to project.
+func OpenSQLiteBackend(path string, project string) (*SQLiteBackend, error) {
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open session database: %w", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init session database: %w", err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil && !errors.Is(err, os.ErrNotExist) {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteBackend{db: db, project: project}, nil
+}
+
+// Load reads a session and its messages.
+func (b *SQLiteBackend) Load(name string) (*SessionData, error) {
+	var payload string
+	err := b.db.QueryRow(`SELECT data FROM sessions WHERE project = ? AND name = ?`, b.project, name).Scan(&payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("session %s: %w", name, os.ErrNotExist)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var session SessionData
+	if err := b.decode(payload, &session); err != nil {
+		return nil, fmt.Errorf("parse session: %w", err)
+	}
+
+	rows, err := b.db.Query(`SELECT data FROM messages WHERE project = ? AND session = ? ORDER BY seq`, b.project, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var msg StoredMessage
+		if err := b.decode(data, &msg); err != nil {
+			return nil, fmt.Errorf("parse message: %w", err)
+		}
+		session.Messages = append(session.Messages, msg)
+	}
+	return &session, rows.Err()
+}
+
+// Save writes session metadata and replaces messages from the first one
+// that differs from what is stored.
+func (b *SQLiteBackend) Save(session *SessionData) error {
+	meta := *session
+	meta.Messages = nil
+	payload, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("encode session: %w", err)
+	}
+
+	sealed, err := b.cipher.Seal(payload)
+	if err != nil {
+		return err
+	}
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO sessions (project, name, created_at, updated_at, data) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (project, name) DO UPDATE SET created_at = excluded.created_at, updated_at = excluded.updated_at, data = excluded.data`,
+		b.project, session.Name, session.CreatedAt.UnixNano(), session.UpdatedAt.UnixNano(), string(sealed)); err != nil {
+		return err
+	}
+
+	stored, err := b.messageHashes(tx, session.Name)
+	if err != nil {
+		return err
+	}
+	encoded := make([]string, len(session.Messages))
+	hashes := make([]string, len(session.Messages))
+	for i, msg := range session.Messages {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("encode message: %w", err)
+		}
+		sealed, err := b.cipher.Seal(data)
+		if err != nil {
+			return err
+		}
+		encoded[i] = string(sealed)
+		hashes[i] = b.cipher.Hash(data)
+	}
+	keep := 0
+	for keep < len(stored) && keep < len(hashes) && stored[keep] == hashes[keep] {
+		keep++
+	}
+	if keep < len(stored) {
+		if _, err := tx.Exec(`DELETE FROM messages WHERE project = ? AND session = ? AND seq >= ?`, b.project, session.Name, keep); err != nil {
+			return err
+		}
+	}
+	if keep < len(hashes) {
+		insert, err := tx.Prepare(`INSERT INTO messages (project, session, seq, hash, time, data) VALUES (?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer insert.Close()
+		for i := keep; i < len(hashes); i++ {
+			var stamp interface{}
+			if t := session.Messages[i].Time; t != nil {
+				stamp = t.UnixNano()
+			}
+			if _, err := insert.Exec(b.project, session.Name, i, hashes[i], stamp, encoded[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func (b *SQLiteBackend) messageHashes(tx *sql.Tx, name string) ([]string, error) {
+	rows, err := tx.Query(`SELECT hash FROM messages WHERE project = ? AND session = ? ORDER BY seq`, b.project, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+// Names returns the name of every session row of the project.
+func (b *SQLiteBackend) Names() ([]string, error) {
+	rows, err := b.db.Query(`SELECT name FROM sessions WHERE project = ?`, b.project)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// Delete removes a session and its messages.
+func (b *SQLiteBackend) Delete(name string) error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec(`DELETE FROM sessions WHERE project = ? AND name = ?`, b.project, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("session %s: %w", name, os.ErrNotExist)
+	}
+	if _, err := tx.Exec(`DELETE FROM messages WHERE project = ? AND session = ?`, b.project, name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// List returns session metadata without messages, newest first.
+func (b *SQLiteBackend) List() ([]SessionData, error) {
+	rows, err := b.db.Query(`SELECT data FROM sessions WHERE project = ? ORDER BY updated_at DESC`, b.project)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var sessions []SessionData
+	for rows.Next() {
+		var payload string
+		if err := rows.Scan(&payload); err != nil {
+			return nil, err
+		}
+		var session SessionData
+		if err := b.decode(payload, &session); err != nil {
+			if errors.Is(err, ErrEncrypted) || errors.Is(err, ErrWrongKey) {
+				return nil, err
+			}
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// Version returns the stored version of a session.
+func (b *SQLiteBackend) Version(name string) (int64, error) {
+	var payload string
+	err := b.db.QueryRow(`SELECT data FROM sessions WHERE project = ? AND name = ?`, b.project, name).Scan(&payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var header struct {
+		Version int64 `json:"version"`
+	}
+	if err := b.decode(payload, &header); err != nil {
+		return 0, fmt.Errorf("parse session: %w", err)
+	}
+	return header.Version, nil
+}
+
+// Size returns the bytes of session and message data stored for a session.
+func (b *SQLiteBackend) Size(name string) (int64, error) {
+	var size int64
+	err := b.db.QueryRow(`SELECT
+		COALESCE((SELECT length(data) FROM sessions WHERE project = ? AND name = ?), 0) +
+		COALESCE((SELECT SUM(length(data)) FROM messages WHERE project = ? AND session = ?), 0)`,
+		b.project, name, b.project, name).Scan(&size)
+	return size, err
+}
+
+func (b *SQLiteBackend) decode(payload string, v interface{}) error {
+	data, err := decrypt(b.cipher, []byte(payload))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (b *SQLiteBackend) vacuum() error {
+	if _, err := b.db.Exec(`VACUUM`); err != nil {
+		return err
+	}
+	_, err := b.db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`)
+	return err
+}
+
+// Close closes the database.
+func (b *SQLiteBackend) Close() error {
+	return b.db.Close()
+}
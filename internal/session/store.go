@@ -2,14 +2,19 @@ package session
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/sashabaranov/go-openai"
+
+	"github.com/unixsysdev/serena-cli-go/internal/crypt"
+	"github.com/unixsysdev/serena-cli-go/internal/fsutil"
 )
 
 // StoredToolCall captures the minimal data needed to rebuild a tool call.
@@ -25,6 +30,47 @@ type StoredMessage struct {
 	Content    string           `json:"content"`
 	ToolCalls  []StoredToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string           `json:"tool_call_id,omitempty"`
+	Time       *time.Time       `json:"time,omitempty"`
+	Model      string           `json:"model,omitempty"`
+}
+
+// ContextSource is a file attached to the session context with @context.
+type ContextSource struct {
+	Label   string    `json:"label"`
+	Path    string    `json:"path"`
+	Content string    `json:"content"`
+	Hash    string    `json:"hash"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// Checkpoint records the working tree and conversation length before a turn.
+type Checkpoint struct {
+	ID     int    `json:"id"`
+	Commit string `json:"commit"`
+	Tree   string `json:"tree"`
+	// MessageCount is the number of conversation messages before the turn, or
+	// 0 when the turn has since been compacted away.
+	MessageCount int       `json:"message_count"`
+	Prompt       string    `json:"prompt"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// CommitRecord is a commit made by auto-commit for a turn.
+type CommitRecord struct {
+	Hash      string    `json:"hash"`
+	Branch    string    `json:"branch"`
+	Subject   string    `json:"subject"`
+	Files     int       `json:"files"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Redaction records secrets replaced in content sent to the LLM or stored in
+// the session. The secrets themselves are never kept.
+type Redaction struct {
+	Time     time.Time `json:"time"`
+	Detector string    `json:"detector"`
+	Source   string    `json:"source"`
+	Count    int       `json:"count"`
 }
 
 // SessionData persists a conversation session.
@@ -37,29 +83,258 @@ type SessionData struct {
 	Messages     []StoredMessage `json:"messages"`
 	ArchiveFile  string          `json:"archive_file,omitempty"`
 	SummaryFile  string          `json:"summary_file,omitempty"`
+	Context      []ContextSource `json:"context,omitempty"`
+	Checkpoints  []Checkpoint    `json:"checkpoints,omitempty"`
+	Commits      []CommitRecord  `json:"commits,omitempty"`
+	// Parent is the session this one was forked from, and ForkIndex the number
+	// of messages copied from it.
+	Parent    string `json:"parent,omitempty"`
+	ForkIndex int    `json:"fork_index,omitempty"`
+	// Version counts saves and detects writes by another process.
+	Version int64 `json:"version"`
+	// Description, Tags and Pinned are set by the user to organize sessions.
+	// Pinned sessions are listed first and never garbage collected.
+	Description string      `json:"description,omitempty"`
+	Tags        []string    `json:"tags,omitempty"`
+	Pinned      bool        `json:"pinned,omitempty"`
+	Redactions  []Redaction `json:"redactions,omitempty"`
 }
 
-// Store manages session persistence in a directory.
+// ErrConflict reports that a session changed on disk since it was loaded.
+var ErrConflict = errors.New("session was modified by another process")
+
+// ErrEncrypted reports encrypted session data read without a key.
+var ErrEncrypted = errors.New("session data is encrypted; enable session_encryption and provide the passphrase or key file")
+
+// ErrWrongKey reports a passphrase or key file that does not match the one
+// sessions were encrypted with.
+var ErrWrongKey = errors.New("wrong session passphrase or key file")
+
+// decrypt opens data read from storage, reporting a missing or wrong key
+// with ErrEncrypted or ErrWrongKey.
+func decrypt(c *crypt.Cipher, data []byte) ([]byte, error) {
+	data, err := c.Open(data)
+	return data, sessionCryptError(err)
+}
+
+func sessionCryptError(err error) error {
+	switch {
+	case errors.Is(err, crypt.ErrEncrypted):
+		return ErrEncrypted
+	case errors.Is(err, crypt.ErrWrongKey):
+		return ErrWrongKey
+	case errors.Is(err, crypt.ErrMixed):
+		return fmt.Errorf("%w; run serena session encrypt to rewrite it", err)
+	}
+	return err
+}
+
+// generation counts writes made through any Store in this process.
+var generation atomic.Uint64
+
+// Generation returns a number that changes whenever a Store in this process
+// writes or deletes a session, archive or summary, so caches built from
+// session data know when to rebuild.
+func Generation() uint64 {
+	return generation.Load()
+}
+
+func changed() {
+	generation.Add(1)
+}
+
+// Backend persists session data for one project.
+type Backend interface {
+	// Load returns the named session, or an error wrapping os.ErrNotExist.
+	Load(name string) (*SessionData, error)
+	// Save writes session as given, without touching its timestamps.
+	Save(session *SessionData) error
+	Delete(name string) error
+	// List returns all sessions, most recently updated first. Backends may
+	// leave Messages empty.
+	List() ([]SessionData, error)
+	// Names returns the names of all stored sessions, including ones that
+	// cannot be parsed.
+	Names() ([]string, error)
+	// Version returns the stored version of a session, or 0 if it does not
+	// exist.
+	Version(name string) (int64, error)
+	Close() error
+}
+
+// Backend names accepted by OpenStore.
+const (
+	BackendJSON   = "json"
+	BackendSQLite = "sqlite"
+)
+
+// Store manages session persistence for a project. Session data goes through
+// a Backend; archive and summary files live in dir. With a Cipher, session
+// data, archives and summaries are encrypted at rest.
 type Store struct {
-	dir string
+	dir     string
+	backend Backend
+	cipher  *crypt.Cipher
 }
 
-// NewStore creates a new session store rooted at dir.
+// NewStore creates a new session store rooted at dir using JSON files.
 func NewStore(dir string) (*Store, error) {
+	return OpenStore(dir, BackendJSON)
+}
+
+// OpenStore creates a session store rooted at dir using the named backend.
+// The SQLite database is shared by all projects and lives next to dir.
+func OpenStore(dir string, backend string) (*Store, error) {
+	return OpenEncryptedStore(dir, backend, nil)
+}
+
+// OpenEncryptedStore is OpenStore with encryption by c. Plaintext data is
+// still read, and encrypted when next written.
+func OpenEncryptedStore(dir string, backend string, c *crypt.Cipher) (*Store, error) {
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return nil, fmt.Errorf("create session dir: %w", err)
 	}
-	return &Store{dir: dir}, nil
+	switch backend {
+	case "", BackendJSON:
+		return &Store{dir: dir, backend: &JSONBackend{dir: dir, cipher: c}, cipher: c}, nil
+	case BackendSQLite:
+		db, err := OpenSQLiteBackend(filepath.Join(filepath.Dir(dir), SQLiteFileName), filepath.Base(dir))
+		if err != nil {
+			return nil, err
+		}
+		db.cipher = c
+		return &Store{dir: dir, backend: db, cipher: c}, nil
+	default:
+		return nil, fmt.Errorf("unknown session backend %q (use %s or %s)", backend, BackendJSON, BackendSQLite)
+	}
 }
 
-// Path returns the session file path for name.
-func (s *Store) Path(name string) string {
-	return filepath.Join(s.dir, sanitizeName(name)+".json")
+// Dir returns the directory holding archive and summary files.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Backend returns the underlying storage backend.
+func (s *Store) Backend() Backend {
+	return s.backend
 }
 
 // Load reads a session by name.
 func (s *Store) Load(name string) (*SessionData, error) {
-	data, err := os.ReadFile(s.Path(name))
+	return s.backend.Load(sanitizeName(name))
+}
+
+// Save writes a session, stamping its creation and update times and bumping
+// its version. It fails with ErrConflict when another process saved the
+// session since it was loaded.
+func (s *Store) Save(session *SessionData) error {
+	if session.Name == "" {
+		return fmt.Errorf("session name is required")
+	}
+	unlock, err := lockFile(s.lockPath(session.Name))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stored, err := s.backend.Version(session.Name)
+	if err != nil {
+		return err
+	}
+	if stored != session.Version {
+		return fmt.Errorf("%w: %s is at version %d, this process loaded version %d", ErrConflict, session.Name, stored, session.Version)
+	}
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+	session.UpdatedAt = time.Now()
+	session.Version++
+	defer changed()
+	if err := s.backend.Save(session); err != nil {
+		session.Version--
+		return err
+	}
+	return nil
+}
+
+// Delete removes a session together with its archive and summary files.
+func (s *Store) Delete(name string) error {
+	unlock, err := lockFile(s.lockPath(name))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	defer changed()
+	data, loadErr := s.backend.Load(sanitizeName(name))
+	if err := s.backend.Delete(sanitizeName(name)); err != nil {
+		return err
+	}
+	if loadErr != nil {
+		return nil
+	}
+	for _, file := range []string{data.ArchiveFile, data.SummaryFile} {
+		if path := s.companionPath(file); path != "" {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) lockPath(name string) string {
+	return filepath.Join(s.dir, ".locks", sanitizeName(name)+".lock")
+}
+
+// List returns all stored sessions, most recently updated first.
+func (s *Store) List() ([]SessionData, error) {
+	return s.backend.List()
+}
+
+// Close releases the backend.
+func (s *Store) Close() error {
+	return s.backend.Close()
+}
+
+// Migrate copies every session from one backend to another, keeping
+// timestamps, and returns the number copied.
+func Migrate(from Backend, to Backend) (int, error) {
+	all, err := from.List()
+	if err != nil {
+		return 0, err
+	}
+	for i, entry := range all {
+		data, err := from.Load(entry.Name)
+		if err != nil {
+			return i, fmt.Errorf("load %s: %w", entry.Name, err)
+		}
+		if err := to.Save(data); err != nil {
+			return i, fmt.Errorf("save %s: %w", entry.Name, err)
+		}
+	}
+	return len(all), nil
+}
+
+// JSONBackend stores each session as a pretty-printed JSON file, or as one
+// encrypted line when it has a cipher.
+type JSONBackend struct {
+	dir    string
+	cipher *crypt.Cipher
+}
+
+// NewJSONBackend returns a backend storing sessions in dir.
+func NewJSONBackend(dir string) *JSONBackend {
+	return &JSONBackend{dir: dir}
+}
+
+// Path returns the session file path for name.
+func (b *JSONBackend) Path(name string) string {
+	return filepath.Join(b.dir, sanitizeName(name)+".json")
+}
+
+// Load reads a session by name.
+func (b *JSONBackend) Load(name string) (*SessionData, error) {
+	data, err := b.read(b.Path(name))
 	if err != nil {
 		return nil, err
 	}
@@ -72,31 +347,73 @@ func (s *Store) Load(name string) (*SessionData, error) {
 }
 
 // Save writes a session to disk.
-func (s *Store) Save(session *SessionData) error {
-	if session.Name == "" {
-		return fmt.Errorf("session name is required")
+func (b *JSONBackend) Save(session *SessionData) error {
+	payload, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode session: %w", err)
 	}
-	if session.CreatedAt.IsZero() {
-		session.CreatedAt = time.Now()
+
+	sealed, err := b.cipher.Seal(payload)
+	if err != nil {
+		return err
 	}
-	session.UpdatedAt = time.Now()
+	return fsutil.WriteFileAtomic(b.Path(session.Name), sealed, 0o600)
+}
 
-	payload, err := json.MarshalIndent(session, "", "  ")
+func (b *JSONBackend) read(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("encode session: %w", err)
+		return nil, err
 	}
+	return decrypt(b.cipher, data)
+}
 
-	return os.WriteFile(s.Path(session.Name), payload, 0o600)
+// Version reads the version field of a session file.
+func (b *JSONBackend) Version(name string) (int64, error) {
+	data, err := b.read(b.Path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var header struct {
+		Version int64 `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return 0, fmt.Errorf("parse session: %w", err)
+	}
+	return header.Version, nil
+}
+
+// Size returns the size of a session file.
+func (b *JSONBackend) Size(name string) (int64, error) {
+	return fileSize(b.Path(name))
 }
 
 // Delete removes a session file.
-func (s *Store) Delete(name string) error {
-	return os.Remove(s.Path(name))
+func (b *JSONBackend) Delete(name string) error {
+	return os.Remove(b.Path(name))
+}
+
+// Names returns the name of every session file.
+func (b *JSONBackend) Names() ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".json"); ok && !entry.IsDir() {
+			names = append(names, name)
+		}
+	}
+	return names, nil
 }
 
 // List returns all stored sessions.
-func (s *Store) List() ([]SessionData, error) {
-	entries, err := os.ReadDir(s.dir)
+func (b *JSONBackend) List() ([]SessionData, error) {
+	entries, err := os.ReadDir(b.dir)
 	if err != nil {
 		return nil, err
 	}
@@ -106,7 +423,10 @@ func (s *Store) List() ([]SessionData, error) {
 		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
 			continue
 		}
-		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		data, err := b.read(filepath.Join(b.dir, entry.Name()))
+		if errors.Is(err, ErrEncrypted) || errors.Is(err, ErrWrongKey) {
+			return nil, err
+		}
 		if err != nil {
 			continue
 		}
@@ -124,6 +444,11 @@ func (s *Store) List() ([]SessionData, error) {
 	return sessions, nil
 }
 
+// Close is a no-op for JSON files.
+func (b *JSONBackend) Close() error {
+	return nil
+}
+
 // FromOpenAIMessages converts OpenAI messages into stored messages, skipping the system prompt.
 func FromOpenAIMessages(messages []openai.ChatCompletionMessage) []StoredMessage {
 	if len(messages) == 0 {
@@ -159,6 +484,43 @@ func FromOpenAIMessages(messages []openai.ChatCompletionMessage) []StoredMessage
 	return stored
 }
 
+// CarryMetadata copies timestamps and models from previous onto the matching
+// messages in stored. Messages without a match are stamped with now, and
+// assistant messages with model.
+func CarryMetadata(stored []StoredMessage, previous []StoredMessage, now time.Time, model string) {
+	type meta struct {
+		time  *time.Time
+		model string
+	}
+	known := make(map[string][]meta)
+	for _, msg := range previous {
+		key := messageKey(msg)
+		known[key] = append(known[key], meta{time: msg.Time, model: msg.Model})
+	}
+	for i := range stored {
+		key := messageKey(stored[i])
+		if queue := known[key]; len(queue) > 0 {
+			stored[i].Time = queue[0].time
+			stored[i].Model = queue[0].model
+			known[key] = queue[1:]
+			continue
+		}
+		stamp := now
+		stored[i].Time = &stamp
+		if stored[i].Role == openai.ChatMessageRoleAssistant {
+			stored[i].Model = model
+		}
+	}
+}
+
+func messageKey(msg StoredMessage) string {
+	key := msg.Role + "\x00" + msg.ToolCallID + "\x00" + msg.Content
+	for _, call := range msg.ToolCalls {
+		key += "\x00" + call.ID
+	}
+	return key
+}
+
 // ToOpenAIMessages rebuilds OpenAI messages using the provided system prompt.
 func ToOpenAIMessages(systemPrompt string, messages []StoredMessage) []openai.ChatCompletionMessage {
 	result := []openai.ChatCompletionMessage{
@@ -219,3 +581,10 @@ func sanitizeName(name string) string {
 	}
 	return trimmed
 }
+
+// LockFile takes an exclusive lock on path, creating it if needed, and
+// returns the function that releases it. It guards read-modify-write cycles
+// of files shared between processes.
+func LockFile(path string) (func(), error) {
+	return lockFile(path)
+}
@@ -0,0 +1,190 @@
+package transcript
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// WriteMarkdown renders doc as Markdown. Tool calls are wrapped in <details>
+// blocks so they collapse on renderers that support it.
+func WriteMarkdown(w io.Writer, doc *Document, opts Options) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "# Session: %s\n\n", doc.Session)
+	fmt.Fprintf(b, "- Model: `%s`\n", doc.Model)
+	fmt.Fprintf(b, "- Created: %s\n", formatTime(doc.CreatedAt))
+	fmt.Fprintf(b, "- Updated: %s\n", formatTime(doc.UpdatedAt))
+	if doc.Parent != "" {
+		fmt.Fprintf(b, "- Forked from: %s\n", doc.Parent)
+	}
+	for _, ref := range doc.Context {
+		fmt.Fprintf(b, "- Context: `%s`\n", ref.Label)
+	}
+	b.WriteString("\n")
+
+	if strings.TrimSpace(doc.SystemPrompt) != "" {
+		b.WriteString("<details>\n<summary>System prompt</summary>\n\n")
+		writeFence(b, "text", doc.SystemPrompt)
+		b.WriteString("</details>\n\n")
+	}
+
+	for _, turn := range doc.Turns {
+		heading := fmt.Sprintf("## Turn %d", turn.Index)
+		if turn.Prompt == "" {
+			heading = fmt.Sprintf("## Turn %d (earlier context)", turn.Index)
+		}
+		var meta []string
+		if turn.Time != nil {
+			meta = append(meta, formatTime(*turn.Time))
+		}
+		if turn.Model != "" {
+			meta = append(meta, "`"+turn.Model+"`")
+		}
+		if len(meta) > 0 {
+			heading += " · " + strings.Join(meta, " · ")
+		}
+		b.WriteString(heading + "\n\n")
+
+		if turn.Prompt != "" {
+			b.WriteString("**User**\n\n")
+			b.WriteString(quote(turn.Prompt) + "\n\n")
+		}
+		if len(turn.Attachments) > 0 {
+			fmt.Fprintf(b, "Attachments: `%s`\n\n", strings.Join(turn.Attachments, "`, `"))
+		}
+		for _, step := range turn.Steps {
+			if step.Tool == "" {
+				if step.Note != "" {
+					b.WriteString("_" + singleLine(step.Note) + "_\n\n")
+				}
+				if step.Result != "" {
+					writeDetails(b, "Tool result", "", step.Result, opts)
+				}
+				continue
+			}
+			writeDetails(b, "Tool: "+step.Tool, prettyArguments(step.Arguments), step.Result, opts)
+		}
+		if turn.Response != "" {
+			b.WriteString("**Assistant**\n\n")
+			b.WriteString(turn.Response + "\n\n")
+		}
+	}
+	return b.Flush()
+}
+
+func writeDetails(b *bufio.Writer, title string, arguments string, result string, opts Options) {
+	fmt.Fprintf(b, "<details>\n<summary>%s</summary>\n\n", html.EscapeString(title))
+	if arguments != "" {
+		writeFence(b, "json", arguments)
+	}
+	if result != "" {
+		writeFence(b, "text", truncate(result, opts.MaxResultChars))
+	}
+	b.WriteString("</details>\n\n")
+}
+
+// writeFence writes a fenced block long enough not to clash with backticks
+// in text.
+func writeFence(b *bufio.Writer, lang string, text string) {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	fmt.Fprintf(b, "%s%s\n%s\n%s\n\n", fence, lang, strings.TrimRight(text, "\n"), fence)
+}
+
+func quote(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = "> " + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+func singleLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+const htmlStyle = `body{font-family:system-ui,sans-serif;max-width:60rem;margin:2rem auto;padding:0 1rem;line-height:1.5;color:#222}
+h1{font-size:1.6rem}h2{font-size:1.15rem;border-bottom:1px solid #ddd;padding-bottom:.2rem;margin-top:2rem}
+.meta{color:#666;font-size:.9rem}.meta code{color:#444}
+.user{background:#eef4ff;border-left:4px solid #4a7bd0;padding:.5rem .8rem;white-space:pre-wrap}
+.assistant{white-space:pre-wrap;padding:.5rem 0}
+.note{color:#666;font-style:italic;white-space:pre-wrap}
+details{margin:.4rem 0;border:1px solid #ddd;border-radius:4px;padding:.2rem .6rem;background:#fafafa}
+summary{cursor:pointer;font-family:ui-monospace,monospace;font-size:.9rem}
+pre{overflow-x:auto;background:#f4f4f4;padding:.5rem;font-size:.85rem}`
+
+// WriteHTML renders doc as a standalone HTML page with collapsible tool calls.
+func WriteHTML(w io.Writer, doc *Document, opts Options) error {
+	b := bufio.NewWriter(w)
+	esc := html.EscapeString
+	fmt.Fprintf(b, "<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n<title>Session: %s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n", esc(doc.Session), htmlStyle)
+	fmt.Fprintf(b, "<h1>Session: %s</h1>\n<p class=\"meta\">Model <code>%s</code> · created %s · updated %s", esc(doc.Session), esc(doc.Model), esc(formatTime(doc.CreatedAt)), esc(formatTime(doc.UpdatedAt)))
+	if doc.Parent != "" {
+		fmt.Fprintf(b, " · forked from %s", esc(doc.Parent))
+	}
+	b.WriteString("</p>\n")
+	if len(doc.Context) > 0 {
+		b.WriteString("<p class=\"meta\">Context:")
+		for _, ref := range doc.Context {
+			fmt.Fprintf(b, " <code>%s</code>", esc(ref.Label))
+		}
+		b.WriteString("</p>\n")
+	}
+	if strings.TrimSpace(doc.SystemPrompt) != "" {
+		fmt.Fprintf(b, "<details><summary>System prompt</summary><pre>%s</pre></details>\n", esc(doc.SystemPrompt))
+	}
+
+	for _, turn := range doc.Turns {
+		title := fmt.Sprintf("Turn %d", turn.Index)
+		if turn.Prompt == "" {
+			title += " (earlier context)"
+		}
+		fmt.Fprintf(b, "<section>\n<h2>%s</h2>\n", esc(title))
+		var meta []string
+		if turn.Time != nil {
+			meta = append(meta, esc(formatTime(*turn.Time)))
+		}
+		if turn.Model != "" {
+			meta = append(meta, "<code>"+esc(turn.Model)+"</code>")
+		}
+		if len(meta) > 0 {
+			fmt.Fprintf(b, "<p class=\"meta\">%s</p>\n", strings.Join(meta, " · "))
+		}
+		if turn.Prompt != "" {
+			fmt.Fprintf(b, "<div class=\"user\">%s</div>\n", esc(strings.TrimSpace(turn.Prompt)))
+		}
+		if len(turn.Attachments) > 0 {
+			b.WriteString("<p class=\"meta\">Attachments:")
+			for _, label := range turn.Attachments {
+				fmt.Fprintf(b, " <code>%s</code>", esc(label))
+			}
+			b.WriteString("</p>\n")
+		}
+		for _, step := range turn.Steps {
+			if step.Tool == "" {
+				if step.Note != "" {
+					fmt.Fprintf(b, "<p class=\"note\">%s</p>\n", esc(step.Note))
+				}
+				if step.Result != "" {
+					fmt.Fprintf(b, "<details><summary>Tool result</summary><pre>%s</pre></details>\n", esc(truncate(step.Result, opts.MaxResultChars)))
+				}
+				continue
+			}
+			fmt.Fprintf(b, "<details><summary>Tool: %s</summary><pre>%s</pre>", esc(step.Tool), esc(prettyArguments(step.Arguments)))
+			if step.Result != "" {
+				fmt.Fprintf(b, "<pre>%s</pre>", esc(truncate(step.Result, opts.MaxResultChars)))
+			}
+			b.WriteString("</details>\n")
+		}
+		if turn.Response != "" {
+			fmt.Fprintf(b, "<div class=\"assistant\">%s</div>\n", esc(turn.Response))
+		}
+		b.WriteString("</section>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.Flush()
+}
@@ -0,0 +1,248 @@
+// Package transcript renders stored sessions as readable Markdown or HTML
+// transcripts and as a versioned JSON document for archival.
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/unixsysdev/serena-cli-go/internal/orchestrator"
+	"github.com/unixsysdev/serena-cli-go/internal/session"
+)
+
+// SchemaVersion identifies the JSON export layout. It changes only when
+// fields are removed or change meaning.
+const SchemaVersion = "serena.transcript/v1"
+
+// DefaultMaxResultChars limits tool results in Markdown and HTML output.
+const DefaultMaxResultChars = 2000
+
+// Formats lists the supported export formats.
+var Formats = []string{"md", "html", "json"}
+
+// Document is the archival form of a session.
+type Document struct {
+	Schema       string       `json:"schema"`
+	Session      string       `json:"session"`
+	Parent       string       `json:"parent,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	Model        string       `json:"model"`
+	SystemPrompt string       `json:"system_prompt"`
+	Context      []ContextRef `json:"context,omitempty"`
+	Turns        []Turn       `json:"turns"`
+	ExportedAt   time.Time    `json:"exported_at"`
+}
+
+// ContextRef names a context source attached to the session.
+type ContextRef struct {
+	Label string `json:"label"`
+	Path  string `json:"path"`
+	Hash  string `json:"hash"`
+}
+
+// Turn is one user prompt and everything the model did in response. A turn
+// without a prompt holds messages that precede the first prompt, such as a
+// compaction summary.
+type Turn struct {
+	Index       int        `json:"index"`
+	Time        *time.Time `json:"time,omitempty"`
+	Model       string     `json:"model,omitempty"`
+	Prompt      string     `json:"prompt,omitempty"`
+	Attachments []string   `json:"attachments,omitempty"`
+	Steps       []Step     `json:"steps,omitempty"`
+	Response    string     `json:"response,omitempty"`
+}
+
+// Step is a tool call with its result, or interim text the model wrote
+// alongside tool calls.
+type Step struct {
+	Tool      string          `json:"tool,omitempty"`
+	CallID    string          `json:"call_id,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Result    string          `json:"result,omitempty"`
+	Note      string          `json:"note,omitempty"`
+}
+
+// Options controls rendering.
+type Options struct {
+	MaxResultChars int
+}
+
+var attachmentPattern = regexp.MustCompile(`<context source=("(?:[^"\\]|\\.)*")>`)
+
+// NormalizeFormat maps format aliases to one of Formats.
+func NormalizeFormat(format string) (string, error) {
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "md", "markdown":
+		return "md", nil
+	case "html", "htm":
+		return "html", nil
+	case "json":
+		return "json", nil
+	default:
+		return "", fmt.Errorf("unknown export format %q (use %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// Build converts stored session data into a Document.
+func Build(data *session.SessionData) *Document {
+	doc := &Document{
+		Schema:       SchemaVersion,
+		Session:      data.Name,
+		Parent:       data.Parent,
+		CreatedAt:    data.CreatedAt,
+		UpdatedAt:    data.UpdatedAt,
+		Model:        data.Model,
+		SystemPrompt: data.SystemPrompt,
+		ExportedAt:   time.Now(),
+	}
+	for _, source := range data.Context {
+		doc.Context = append(doc.Context, ContextRef{Label: source.Label, Path: source.Path, Hash: source.Hash})
+	}
+
+	var current *Turn
+	steps := make(map[string]int)
+	startTurn := func(msg session.StoredMessage) {
+		doc.Turns = append(doc.Turns, Turn{Index: len(doc.Turns) + 1})
+		current = &doc.Turns[len(doc.Turns)-1]
+		current.Time = msg.Time
+	}
+
+	for _, msg := range data.Messages {
+		switch msg.Role {
+		case openai.ChatMessageRoleUser:
+			startTurn(msg)
+			current.Prompt = orchestrator.UserRequest(msg.Content)
+			for _, match := range attachmentPattern.FindAllStringSubmatch(msg.Content, -1) {
+				if label, err := strconv.Unquote(match[1]); err == nil {
+					current.Attachments = append(current.Attachments, label)
+				}
+			}
+		case openai.ChatMessageRoleAssistant:
+			if current == nil {
+				startTurn(msg)
+			}
+			if msg.Model != "" {
+				current.Model = msg.Model
+			}
+			if len(msg.ToolCalls) == 0 {
+				current.Response = strings.TrimSpace(msg.Content)
+				continue
+			}
+			if note := strings.TrimSpace(msg.Content); note != "" {
+				current.Steps = append(current.Steps, Step{Note: note})
+			}
+			for _, call := range msg.ToolCalls {
+				steps[call.ID] = len(current.Steps)
+				current.Steps = append(current.Steps, Step{
+					Tool:      call.Name,
+					CallID:    call.ID,
+					Arguments: rawArguments(call.Arguments),
+				})
+			}
+		case openai.ChatMessageRoleTool:
+			if current == nil {
+				startTurn(msg)
+			}
+			if idx, ok := steps[msg.ToolCallID]; ok && idx < len(current.Steps) {
+				current.Steps[idx].Result = msg.Content
+			} else {
+				current.Steps = append(current.Steps, Step{CallID: msg.ToolCallID, Result: msg.Content})
+			}
+		default:
+			if current == nil {
+				startTurn(msg)
+			}
+			current.Steps = append(current.Steps, Step{Note: strings.TrimSpace(msg.Content)})
+		}
+	}
+	return doc
+}
+
+// Write renders data in format to w.
+func Write(w io.Writer, format string, data *session.SessionData, opts Options) error {
+	format, err := NormalizeFormat(format)
+	if err != nil {
+		return err
+	}
+	if opts.MaxResultChars <= 0 {
+		opts.MaxResultChars = DefaultMaxResultChars
+	}
+	doc := Build(data)
+	switch format {
+	case "md":
+		return WriteMarkdown(w, doc, opts)
+	case "html":
+		return WriteHTML(w, doc, opts)
+	default:
+		return WriteJSON(w, doc)
+	}
+}
+
+// WriteJSON writes the full, untruncated document.
+func WriteJSON(w io.Writer, doc *Document) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+func rawArguments(arguments string) json.RawMessage {
+	arguments = strings.TrimSpace(arguments)
+	if arguments == "" {
+		return nil
+	}
+	if json.Valid([]byte(arguments)) {
+		return json.RawMessage(arguments)
+	}
+	quoted, _ := json.Marshal(arguments)
+	return quoted
+}
+
+// prettyArguments formats tool arguments for display.
+func prettyArguments(arguments json.RawMessage) string {
+	if len(arguments) == 0 {
+		return "{}"
+	}
+	var value interface{}
+	if err := json.Unmarshal(arguments, &value); err != nil {
+		return string(arguments)
+	}
+	if text, ok := value.(string); ok {
+		return text
+	}
+	pretty, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return string(arguments)
+	}
+	return string(pretty)
+}
+
+func truncate(text string, limit int) string {
+	text = strings.TrimSpace(text)
+	if limit <= 0 || len(text) <= limit {
+		return text
+	}
+	cut := limit
+	for cut > 0 && !isRuneStart(text[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s\n… (%d more characters)", text[:cut], len(text)-cut)
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,455 @@
+package workspace
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// snapshotIdentity is used for checkpoint commits so they work without a
+// configured git user.
+var snapshotIdentity = []string{
+	"GIT_AUTHOR_NAME=serena", "GIT_AUTHOR_EMAIL=serena@localhost",
+	"GIT_COMMITTER_NAME=serena", "GIT_COMMITTER_EMAIL=serena@localhost",
+}
+
+// Repo runs git commands against a working tree.
+type Repo struct {
+	root string
+}
+
+// FileStat summarizes the change to one file between two snapshots.
+type FileStat struct {
+	Path    string
+	Status  string // A, M or D
+	Added   int
+	Deleted int
+	Binary  bool
+}
+
+// Open returns the repository containing dir, or an error when dir is not
+// inside a git work tree or git is unavailable.
+func Open(dir string) (*Repo, error) {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, fmt.Errorf("not a git repository: %s", dir)
+	}
+	return &Repo{root: strings.TrimSpace(string(out))}, nil
+}
+
+// Root returns the top-level directory of the work tree.
+func (r *Repo) Root() string {
+	return r.root
+}
+
+// Snapshot records the current working tree, including untracked files that
+// are not ignored, as a git tree object and returns its hash. The real index
+// is left untouched.
+func (r *Repo) Snapshot() (string, error) {
+	tmpDir, err := os.MkdirTemp("", "serena-index-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+	indexPath := filepath.Join(tmpDir, "index")
+
+	// Start from a copy of the real index so unchanged files are not rehashed.
+	if real, err := r.git(nil, "rev-parse", "--git-path", "index"); err == nil {
+		path := strings.TrimSpace(real)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(r.root, path)
+		}
+		if err := copyFile(path, indexPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	env := []string{"GIT_INDEX_FILE=" + indexPath}
+	if _, err := r.git(env, "add", "-A", "--", "."); err != nil {
+		return "", err
+	}
+	tree, err := r.git(env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(tree), nil
+}
+
+// DiffStat returns per-file changes between two snapshots.
+func (r *Repo) DiffStat(from string, to string) ([]FileStat, error) {
+	if from == to {
+		return nil, nil
+	}
+	numstat, err := r.git(nil, "diff", "--no-renames", "--numstat", "-z", from, to)
+	if err != nil {
+		return nil, err
+	}
+	status, err := r.git(nil, "diff", "--no-renames", "--name-status", "-z", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[string]string)
+	fields := strings.Split(status, "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		statuses[fields[i+1]] = fields[i]
+	}
+
+	var stats []FileStat
+	for _, record := range strings.Split(numstat, "\x00") {
+		parts := strings.SplitN(record, "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		stat := FileStat{Path: parts[2], Status: statuses[parts[2]]}
+		if parts[0] == "-" {
+			stat.Binary = true
+		} else {
+			stat.Added, _ = strconv.Atoi(parts[0])
+			stat.Deleted, _ = strconv.Atoi(parts[1])
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+// Diff returns a unified diff between two snapshots, optionally limited to
+// paths and colored for a terminal.
+func (r *Repo) Diff(from string, to string, color bool, paths ...string) (string, error) {
+	args := []string{"diff", "--no-renames", "--color=never"}
+	if color {
+		args[2] = "--color=always"
+	}
+	args = append(args, from, to)
+	if len(paths) > 0 {
+		args = append(args, "--")
+		args = append(args, paths...)
+	}
+	return r.git(nil, args...)
+}
+
+// SaveTree records tree as a commit on ref, chained to the ref's previous
+// commit, so the snapshot is not garbage collected. It returns the commit hash.
+func (r *Repo) SaveTree(ref string, tree string, message string) (string, error) {
+	parent, _ := r.resolve(ref)
+	return r.commitTree(ref, tree, parent, message, snapshotIdentity)
+}
+
+// CommitToBranch commits the changes between the snapshots from and to onto
+// branch without touching HEAD, the index or the working tree. Only that diff
+// is applied to the branch tip, so edits made outside the range are not
+// committed. A new branch starts from HEAD. It returns "" when the diff is
+// empty and an error when it does not apply to the branch. The user's git
+// identity is used when configured.
+func (r *Repo) CommitToBranch(branch string, from string, to string, message string) (string, error) {
+	ref := "refs/heads/" + branch
+	if _, err := r.git(nil, "check-ref-format", ref); err != nil {
+		return "", fmt.Errorf("invalid branch name: %s", branch)
+	}
+	parent, err := r.resolve(ref)
+	if err != nil {
+		parent, _ = r.resolve("HEAD")
+	}
+	tree, err := r.applyToTree(parent, from, to)
+	if err != nil {
+		return "", fmt.Errorf("changes do not apply to %s: %w", branch, err)
+	}
+	if tree == "" {
+		return "", nil
+	}
+	var env []string
+	if _, err := r.git(nil, "var", "GIT_AUTHOR_IDENT"); err != nil {
+		env = snapshotIdentity
+	}
+	return r.commitTree(ref, tree, parent, message, env)
+}
+
+// applyToTree applies the diff between from and to to the tree of commit
+// parent, or to an empty tree when parent is "", using a temporary index. It
+// returns the resulting tree, or "" when nothing changes.
+func (r *Repo) applyToTree(parent string, from string, to string) (string, error) {
+	patch, err := r.git(nil, "diff", "--binary", "--full-index", "--no-renames", from, to)
+	if err != nil || patch == "" {
+		return "", err
+	}
+	tmpDir, err := os.MkdirTemp("", "serena-index-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+	patchPath := filepath.Join(tmpDir, "turn.patch")
+	if err := os.WriteFile(patchPath, []byte(patch), 0o600); err != nil {
+		return "", err
+	}
+
+	env := []string{"GIT_INDEX_FILE=" + filepath.Join(tmpDir, "index")}
+	readTree := []string{"read-tree", "--empty"}
+	if parent != "" {
+		readTree = []string{"read-tree", parent}
+	}
+	if _, err := r.git(env, readTree...); err != nil {
+		return "", err
+	}
+	if _, err := r.git(env, "apply", "--cached", patchPath); err != nil {
+		return "", err
+	}
+	tree, err := r.git(env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	tree = strings.TrimSpace(tree)
+	if parent != "" {
+		if parentTree, err := r.resolve(parent + "^{tree}"); err == nil && parentTree == tree {
+			return "", nil
+		}
+	}
+	return tree, nil
+}
+
+func (r *Repo) commitTree(ref string, tree string, parent string, message string, env []string) (string, error) {
+	args := []string{"commit-tree", tree, "-m", message}
+	if parent != "" {
+		args = append(args, "-p", parent)
+	}
+	commit, err := r.git(env, args...)
+	if err != nil {
+		return "", err
+	}
+	commit = strings.TrimSpace(commit)
+	if _, err := r.git(nil, "update-ref", ref, commit); err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+func (r *Repo) resolve(rev string) (string, error) {
+	out, err := r.git(nil, "rev-parse", "--verify", "--quiet", rev)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// DeleteRef removes ref if it exists.
+func (r *Repo) DeleteRef(ref string) error {
+	if _, err := r.resolve(ref); err != nil {
+		return nil
+	}
+	_, err := r.git(nil, "update-ref", "-d", ref)
+	return err
+}
+
+// RenameRef moves ref to newRef, replacing newRef. It does nothing when ref
+// does not exist.
+func (r *Repo) RenameRef(ref string, newRef string) error {
+	commit, err := r.resolve(ref)
+	if err != nil {
+		return nil
+	}
+	if _, err := r.git(nil, "update-ref", newRef, commit); err != nil {
+		return err
+	}
+	_, err = r.git(nil, "update-ref", "-d", ref, commit)
+	return err
+}
+
+// Refs lists the refs under prefix, such as "refs/serena/".
+func (r *Repo) Refs(prefix string) ([]string, error) {
+	out, err := r.git(nil, "for-each-ref", "--format=%(refname)", prefix)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// Restore makes the working tree match tree for every file tracked by either
+// snapshot: changed files are rewritten and files absent from tree are
+// removed. Ignored files are left alone. It returns the files it touched.
+func (r *Repo) Restore(tree string) ([]FileStat, error) {
+	current, err := r.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	stats, err := r.DiffStat(current, tree)
+	if err != nil || len(stats) == 0 {
+		return stats, err
+	}
+
+	var checkout []string
+	for _, stat := range stats {
+		if stat.Status == "D" {
+			if err := os.Remove(filepath.Join(r.root, filepath.FromSlash(stat.Path))); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+			continue
+		}
+		checkout = append(checkout, stat.Path)
+	}
+	if len(checkout) == 0 {
+		return stats, nil
+	}
+
+	tmpDir, err := os.MkdirTemp("", "serena-index-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	env := []string{"GIT_INDEX_FILE=" + filepath.Join(tmpDir, "index")}
+	if _, err := r.git(env, "read-tree", tree); err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("git", "-C", r.root, "checkout-index", "-f", "-z", "--stdin")
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = strings.NewReader(strings.Join(checkout, "\x00") + "\x00")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("git checkout-index: %s", strings.TrimSpace(string(out)))
+	}
+	return stats, nil
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (r *Repo) git(env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", r.root}, args...)...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return stdout.String(), nil
+}
+
+// Tracker snapshots the working tree around chat turns so changes made during
+// the last turn or the whole session can be reported.
+type Tracker struct {
+	repo        *Repo
+	mu          sync.Mutex
+	sessionBase string
+	turnBase    string
+	turnHead    string
+}
+
+// NewTracker creates a tracker for the repository containing dir.
+func NewTracker(dir string) (*Tracker, error) {
+	repo, err := Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Tracker{repo: repo}, nil
+}
+
+// Repo returns the underlying repository.
+func (t *Tracker) Repo() *Repo {
+	return t.repo
+}
+
+// BeginTurn snapshots the tree before a turn starts.
+func (t *Tracker) BeginTurn() error {
+	t.mu.Lock()
+	t.turnBase = ""
+	t.turnHead = ""
+	t.mu.Unlock()
+
+	tree, err := t.repo.Snapshot()
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sessionBase == "" {
+		t.sessionBase = tree
+	}
+	t.turnBase = tree
+	return nil
+}
+
+// TurnBase returns the snapshot taken before the last turn, or "" when none
+// was recorded.
+func (t *Tracker) TurnBase() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.turnBase
+}
+
+// EndTurn snapshots the tree after a turn finishes.
+func (t *Tracker) EndTurn() error {
+	tree, err := t.repo.Snapshot()
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.turnHead = tree
+	return nil
+}
+
+// ResetSession forgets the session baseline, e.g. after switching sessions.
+func (t *Tracker) ResetSession() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sessionBase = ""
+	t.turnBase = ""
+	t.turnHead = ""
+}
+
+// TurnStats returns the files changed during the last completed turn.
+func (t *Tracker) TurnStats() ([]FileStat, error) {
+	from, to, err := t.Range(false)
+	if err != nil {
+		return nil, err
+	}
+	return t.repo.DiffStat(from, to)
+}
+
+// Range returns the snapshots bounding the last turn, or the session when
+// session is true. The session range ends at the current working tree.
+func (t *Tracker) Range(session bool) (string, string, error) {
+	t.mu.Lock()
+	from, to := t.turnBase, t.turnHead
+	if session {
+		from = t.sessionBase
+	}
+	t.mu.Unlock()
+
+	if from == "" {
+		return "", "", fmt.Errorf("no turns recorded yet")
+	}
+	if session || to == "" {
+		current, err := t.repo.Snapshot()
+		if err != nil {
+			return "", "", err
+		}
+		to = current
+	}
+	return from, to, nil
+}